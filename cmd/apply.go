@@ -1,20 +1,42 @@
 package cmd
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/vpukhanov/cascade/internal/codemod"
 	"github.com/vpukhanov/cascade/internal/git"
+	"github.com/vpukhanov/cascade/internal/keyedit"
 	applog "github.com/vpukhanov/cascade/internal/log"
+	"github.com/vpukhanov/cascade/internal/recipe"
+	"github.com/vpukhanov/cascade/internal/tmpl"
+	"github.com/vpukhanov/cascade/internal/transform"
 	"github.com/vpukhanov/cascade/internal/validation"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
 	patchFile     string
 	scriptFile    string
 	command       string
+	starlarkFile  string
+	wasmFile      string
+	scriptArgs    []string
+	interpreter   string
+	streamOutput  bool
+	keepOutput    bool
+	logDir        string
+	logFormat     string
 	branch        string
 	message       string
 	baseBranch    string
@@ -23,81 +45,277 @@ var (
 	noVerify      bool
 	stash         bool
 	openRemoteURL bool
+	browser       string
+	goOptions     codemod.GoOptions
+	setSpecs      []string
+	assignments   []keyedit.Assignment
+	recipeFile    string
+	loadedRecipe  *recipe.Recipe
+	varSpecs      []string
+	templateVars  map[string]string
+	updateMode    string
+	onExisting    string
+	prURLTemplate string
+	reviewSystem  string
+	reviewTopic   string
+	reviewers     []string
+	hashtags      []string
+
+	transformTimeout time.Duration
+	rawTemplates     bool
 
 	gitCheckoutBranch         = git.CheckoutBranch
 	gitCheckoutExistingBranch = git.CheckoutExistingBranch
 	gitApplyPatch             = git.ApplyPatch
 	gitCommitChanges          = git.CommitChanges
+	gitAmendCommit            = git.AmendCommit
 	gitExecuteCommand         = git.ExecuteCommand
 	gitExecuteScript          = git.ExecuteScript
 	gitPullLatest             = git.PullLatest
 	gitPushChanges            = git.PushChanges
+	gitForcePushChanges       = git.ForcePushChanges
 	gitStashChanges           = git.StashChanges
+	gitHasChanges             = git.HasChanges
+	gitCurrentBranch          = git.CurrentBranch
+	gitRemoteURL              = git.RemoteURL
+	gitHeadCommit             = git.HeadCommit
+	gitRefCommit              = git.RefCommit
+	gitDiffStat               = git.DiffStat
+	codemodApplyGo            = codemod.ApplyGo
+	keyeditApply              = keyedit.Apply
+	codemodReplace            = codemod.Replace
+	transformRunStarlark      = transform.RunStarlark
+	transformRunWasm          = transform.RunWasm
 )
 
 var applyCmd = &cobra.Command{
-	Use:     "apply [repositories...]",
-	Short:   "Apply changes across multiple repositories",
-	Long:    "Apply changes across multiple git repositories using either a patch file, a script, or a command.",
-	Example: `cascade apply --patch ./changes.patch --branch update-logging --message "Update logging" ./repo1 ./repo2`,
+	Use:   "apply [repositories...]",
+	Short: "Apply changes across multiple repositories",
+	Long:  "Apply changes across multiple git repositories using either a patch file, a script, a command, a Starlark script, a WebAssembly module, Go codemod rewrites, structured key edits, or a recipe file with several steps.",
+	Example: `cascade apply --patch ./changes.patch --branch update-logging --message "Update logging" ./repo1 ./repo2
+cascade apply --recipe ./upgrade.yaml ./repo1 ./repo2`,
 	Args:    cobra.MinimumNArgs(1),
 	RunE:    runApply,
-	PreRunE: func(cmd *cobra.Command, args []string) error {
-		modeCount := 0
-		if patchFile != "" {
-			modeCount++
+	PreRunE: validateApply,
+}
+
+func validateApply(cmd *cobra.Command, args []string) error {
+	modeCount := 0
+	if patchFile != "" {
+		modeCount++
+	}
+	if scriptFile != "" {
+		modeCount++
+	}
+	if command != "" {
+		modeCount++
+	}
+	if starlarkFile != "" {
+		modeCount++
+	}
+	if wasmFile != "" {
+		modeCount++
+	}
+	if !goOptions.IsZero() {
+		modeCount++
+	}
+	if len(setSpecs) > 0 {
+		modeCount++
+	}
+
+	if recipeFile != "" {
+		if modeCount > 0 {
+			return fmt.Errorf("--recipe cannot be combined with --patch, --script, --command, --starlark, --wasm, --go-* rewrites, or --set")
 		}
-		if scriptFile != "" {
-			modeCount++
+		if rawTemplates {
+			return fmt.Errorf("--raw cannot be combined with --recipe, set raw on the recipe steps instead")
 		}
-		if command != "" {
-			modeCount++
+		r, err := recipe.Load(recipeFile)
+		if err != nil {
+			return err
 		}
+		loadedRecipe = r
+		applyRecipeOptions(cmd, r)
+	} else {
 		if modeCount == 0 {
-			return fmt.Errorf("one of --patch, --script, or --command must be specified")
+			return fmt.Errorf("one of --patch, --script, --command, --starlark, --wasm, --go-* rewrites, --set, or --recipe must be specified")
 		}
 		if modeCount > 1 {
-			return fmt.Errorf("--patch, --script, and --command cannot be used together")
+			return fmt.Errorf("--patch, --script, --command, --starlark, --wasm, --go-* rewrites, and --set cannot be used together, use --recipe to combine them")
 		}
-		if branch == "" {
-			return fmt.Errorf("--branch is required")
+	}
+
+	if branch == "" {
+		return fmt.Errorf("--branch is required")
+	}
+	if message == "" && (loadedRecipe == nil || !loadedRecipe.CommitsEveryStep()) {
+		return fmt.Errorf("--message is required")
+	}
+	if err := tmpl.Validate("commit message", message); err != nil {
+		return err
+	}
+	if len(scriptArgs) > 0 && scriptFile == "" && command == "" {
+		return fmt.Errorf("--script-arg requires --script or --command")
+	}
+
+	vars, err := tmpl.ParseVars(varSpecs)
+	if err != nil {
+		return fmt.Errorf("invalid --var: %w", err)
+	}
+	if loadedRecipe != nil {
+		if vars, err = loadedRecipe.ResolveVars(vars); err != nil {
+			return fmt.Errorf("invalid recipe variables:\n%w", err)
 		}
-		if message == "" {
-			return fmt.Errorf("--message is required")
+	}
+	templateVars = vars
+	if err := validateExistingBranchOptions(); err != nil {
+		return err
+	}
+	if openRemoteURL && !push {
+		return fmt.Errorf("--open-remote-url requires --push")
+	}
+	if err := validateRemoteOptions(); err != nil {
+		return err
+	}
+	if err := validateReviewOptions(); err != nil {
+		return err
+	}
+	if err := validateRetryFlags(); err != nil {
+		return err
+	}
+	if transformTimeout < 0 {
+		return fmt.Errorf("--transform-timeout must not be negative")
+	}
+	if _, err := applog.ParseFormat(logFormat); err != nil {
+		return fmt.Errorf("invalid --log-format: %w", err)
+	}
+
+	if patchFile != "" {
+		if err := validation.ValidateFile(patchFile, "patch"); err != nil {
+			return err
 		}
-		if openRemoteURL && !push {
-			return fmt.Errorf("--open-remote-url requires --push")
+	}
+	if interpreter != "" && scriptFile == "" {
+		return fmt.Errorf("--interpreter requires --script")
+	}
+	if scriptFile != "" {
+		if err := validation.ValidateScript(scriptFile, interpreter); err != nil {
+			return err
 		}
-
-		if patchFile != "" {
-			if err := validation.ValidateFile(patchFile, "patch"); err != nil {
-				return err
-			}
+	}
+	if starlarkFile != "" {
+		if err := validation.ValidateFile(starlarkFile, "starlark"); err != nil {
+			return err
 		}
-		if scriptFile != "" {
-			if err := validation.ValidateFile(scriptFile, "script"); err != nil {
-				return err
-			}
+		if err := transform.ValidateStarlark(starlarkFile); err != nil {
+			return err
+		}
+	}
+	if wasmFile != "" {
+		if err := validation.ValidateFile(wasmFile, "wasm"); err != nil {
+			return err
+		}
+	}
+	if err := goOptions.Validate(); err != nil {
+		return err
+	}
+	assignments = assignments[:0]
+	for _, spec := range setSpecs {
+		a, err := keyedit.ParseAssignment(spec)
+		if err != nil {
+			return fmt.Errorf("invalid --set: %w", err)
 		}
+		assignments = append(assignments, a)
+	}
+	if loadedRecipe == nil {
+		steps, err := applySteps()
+		if err != nil {
+			return err
+		}
+		if err := steps[0].ValidateTemplates(); err != nil {
+			return err
+		}
+	}
 
-		for _, repo := range args {
-			if err := validation.ValidateGitRepo(repo); err != nil {
-				return err
-			}
+	for _, repo := range args {
+		if err := validation.ValidateGitRepo(repo); err != nil {
+			return err
 		}
+	}
 
-		if err := validation.ValidateBranchName(branch); err != nil {
-			return fmt.Errorf("invalid target branch name: %w", err)
+	// Templated branch names are validated once rendered for each repository
+	if strings.Contains(branch, "{{") {
+		if err := tmpl.Validate("branch", branch); err != nil {
+			return err
 		}
+	} else if err := validation.ValidateBranchName(branch); err != nil {
+		return fmt.Errorf("invalid target branch name: %w", err)
+	}
 
-		if baseBranch != "" {
-			if err := validation.ValidateBranchName(baseBranch); err != nil {
-				return fmt.Errorf("invalid base branch name: %w", err)
-			}
+	if baseBranch != "" {
+		if err := validation.ValidateBranchName(baseBranch); err != nil {
+			return fmt.Errorf("invalid base branch name: %w", err)
 		}
+	}
 
-		return nil
-	},
+	return nil
+}
+
+// applyRecipeOptions fills options from the recipe unless they were set
+// explicitly on the command line.
+func applyRecipeOptions(cmd *cobra.Command, r *recipe.Recipe) {
+	flags := cmd.Flags()
+	if !flags.Changed("branch") {
+		branch = r.Branch
+	}
+	if !flags.Changed("message") {
+		message = r.Message
+	}
+	if !flags.Changed("base-branch") {
+		baseBranch = r.BaseBranch
+	}
+	if !flags.Changed("pull") {
+		pullLatest = r.Pull
+	}
+	if !flags.Changed("push") {
+		push = r.Push
+	}
+	if !flags.Changed("no-verify") {
+		noVerify = r.NoVerify
+	}
+	if !flags.Changed("stash") {
+		stash = r.Stash
+	}
+	if !flags.Changed("open-remote-url") {
+		openRemoteURL = r.OpenRemoteURL
+	}
+	if !flags.Changed("update") {
+		updateMode = r.Update
+	}
+	if !flags.Changed("on-existing") {
+		onExisting = r.OnExisting
+	}
+	if !flags.Changed("remote") {
+		pushRemote = r.Remote
+	}
+	if !flags.Changed("fork-url") {
+		forkURL = r.ForkURL
+	}
+	if !flags.Changed("pr-url-template") {
+		prURLTemplate = r.PRURLTemplate
+	}
+	if !flags.Changed("review-system") {
+		reviewSystem = r.ReviewSystem
+	}
+	if !flags.Changed("topic") {
+		reviewTopic = r.Topic
+	}
+	if !flags.Changed("reviewer") {
+		reviewers = r.Reviewers
+	}
+	if !flags.Changed("hashtag") {
+		hashtags = r.Hashtags
+	}
 }
 
 func init() {
@@ -105,25 +323,64 @@ func init() {
 
 	// Required flags
 	applyCmd.Flags().StringVar(&patchFile, "patch", "", "Path to patch file")
-	applyCmd.Flags().StringVar(&scriptFile, "script", "", "Path to executable script")
+	applyCmd.Flags().StringVar(&scriptFile, "script", "", "Path to script, run directly or through an interpreter")
 	applyCmd.Flags().StringVar(&command, "command", "", "Command to execute in each repository")
-	applyCmd.Flags().StringVar(&branch, "branch", "", "Name for the new branch that will be created")
-	applyCmd.Flags().StringVar(&message, "message", "", "Commit message used for the changes")
+	applyCmd.Flags().StringArrayVar(&scriptArgs, "script-arg", nil, "Argument passed to the script, or to the command as $1, $2 and so on, can be a template (repeatable)")
+	applyCmd.Flags().StringVar(&interpreter, "interpreter", "", "Interpreter used to run the script, like 'python3' (detected from the extension or shebang line by default)")
+	applyCmd.Flags().StringVar(&starlarkFile, "starlark", "", "Path to a Starlark script run in a sandbox that can only edit files inside the repository")
+	applyCmd.Flags().StringVar(&wasmFile, "wasm", "", "Path to a WASI module run with the repository mounted as its only filesystem")
+	applyCmd.Flags().DurationVar(&transformTimeout, "transform-timeout", 10*time.Minute, "Maximum time a Starlark script or WebAssembly module may run in each repository, 0 for no limit")
+	applyCmd.Flags().StringArrayVar(&goOptions.Rewrites, "go-rewrite", nil, "Go rewrite rule in the 'pattern -> replacement' form, like gofmt -r (repeatable)")
+	applyCmd.Flags().StringArrayVar(&goOptions.RenameImports, "go-rename-import", nil, "Rename a Go import path and its subpackages, in the 'old=new' form (repeatable)")
+	applyCmd.Flags().StringArrayVar(&goOptions.AddImports, "go-add-import", nil, "Add a Go import to files that reference the package (repeatable)")
+	applyCmd.Flags().StringArrayVar(&goOptions.RemoveImports, "go-remove-import", nil, "Remove a Go import from files that no longer use it (repeatable)")
+	applyCmd.Flags().StringArrayVar(&setSpecs, "set", nil, "Set a key in a YAML, JSON or TOML file, in the 'file:path.to.key=value' form (repeatable)")
+	applyCmd.Flags().StringVar(&recipeFile, "recipe", "", "Path to a recipe file describing the steps and options of the campaign")
+	applyCmd.Flags().BoolVar(&rawTemplates, "raw", false, "Do not render the patch, command, script arguments and --set values as templates")
+	applyCmd.Flags().StringVar(&branch, "branch", "", "Name for the new branch that will be created, can be a template")
+	applyCmd.Flags().StringVar(&message, "message", "", "Commit message used for the changes, can be a template")
 
 	// Optional flags
 	applyCmd.Flags().StringVar(&baseBranch, "base-branch", "", "Branch to check out and apply changes to")
 	applyCmd.Flags().BoolVar(&pullLatest, "pull", false, "Pull latest changes from remote before applying changes")
-	applyCmd.Flags().BoolVar(&push, "push", false, "Push new branch to origin after applying the changes")
+	applyCmd.Flags().BoolVar(&push, "push", false, "Push new branch to the push remote after applying the changes")
 	applyCmd.Flags().BoolVar(&noVerify, "no-verify", false, "Skip git commit and push hooks")
+	applyCmd.Flags().BoolVar(&streamOutput, "stream", false, "Show script and command output live, prefixed with the repository")
+	applyCmd.Flags().BoolVar(&keepOutput, "keep-output", false, "Keep script and command output of successful repositories in the log")
+	applyCmd.Flags().StringVar(&logDir, "log-dir", "", "Directory for a run log directory with a transcript of every command per repository and an index file")
+	applyCmd.Flags().StringVar(&logFormat, "log-format", "text", "Format of the apply log, 'text' or 'json' for one JSON event per line with every command and repository result")
+	applyCmd.Flags().StringVar(&updateMode, "update", "", "Update an existing campaign branch instead of recreating it: 'commit' adds a commit to it, 'amend' amends its last commit, 'regenerate' recreates it from the base branch, the last two force-push with lease")
+	applyCmd.Flags().StringVar(&onExisting, "on-existing", "", "What to do when the branch already exists locally or on the push remote: 'fail', 'skip', 'reset' to recreate it, or 'update' to update it as --update says (default: 'update' with --update, 'fail' otherwise)")
+	addRemoteFlags(applyCmd)
+	applyCmd.Flags().StringVar(&prURLTemplate, "pr-url-template", "", "Pull request URL recorded and opened when the push prints none: 'github', 'gitlab', 'gitea', 'bitbucket' or a template like 'https://{{.Host}}/{{.Path}}/compare/{{.Base}}...{{.Branch}}' (default: detected from the remote host)")
+	applyCmd.Flags().StringVar(&reviewSystem, "review-system", "", "Code review system the changes are pushed to, 'gerrit' adds Change-Id trailers and pushes to refs/for/<base branch> (default: push the branch)")
+	applyCmd.Flags().StringVar(&reviewTopic, "topic", "", "Gerrit topic of the changes, can be a template (default: the branch name)")
+	applyCmd.Flags().StringArrayVar(&reviewers, "reviewer", nil, "Gerrit reviewer added to the changes (repeatable)")
+	applyCmd.Flags().StringArrayVar(&hashtags, "hashtag", nil, "Gerrit hashtag added to the changes (repeatable)")
 	applyCmd.Flags().BoolVar(&stash, "stash", false, "Stash tracked and untracked changes before applying changes")
-	applyCmd.Flags().BoolVar(&openRemoteURL, "open-remote-url", false, "Open the last URL from git push output in the default browser, often links to the PR/MR creation page with default Github and Gitlab server configurations")
+	applyCmd.Flags().BoolVar(&openRemoteURL, "open-remote-url", false, "Open the summary page linking the pull request of every repository in the browser")
+	addRetryFlags(applyCmd)
+	applyCmd.Flags().StringVar(&browser, "browser", "", "Command opening the summary page, given its URL as the last argument (default: $BROWSER, or open on macOS and xdg-open elsewhere)")
 }
 
-// ResetFlags resets all global flag variables to their zero values
+// ResetFlags resets the global flag variables of apply to their defaults.
+// The shared remote and retry flags are reset by ResetRemoteFlags and
+// ResetRetryFlags.
 func ResetFlags() {
 	patchFile = ""
 	scriptFile = ""
 	command = ""
+	starlarkFile = ""
+	wasmFile = ""
+	transformTimeout = 10 * time.Minute
+	rawTemplates = false
+	existingOnly = false
+	scriptArgs = nil
+	interpreter = ""
+	streamOutput = false
+	keepOutput = false
+	logDir = ""
+	logFormat = "text"
 	branch = ""
 	message = ""
 	baseBranch = ""
@@ -132,36 +389,147 @@ func ResetFlags() {
 	noVerify = false
 	stash = false
 	openRemoteURL = false
+	browser = ""
+	goOptions = codemod.GoOptions{}
+	setSpecs = nil
+	assignments = nil
+	recipeFile = ""
+	loadedRecipe = nil
+	varSpecs = nil
+	templateVars = nil
+	updateMode = ""
+	onExisting = ""
+	prURLTemplate = ""
+	reviewSystem = ""
+	reviewTopic = ""
+	reviewers = nil
+	hashtags = nil
+
+	// Recipe options only apply to flags that were not set explicitly, so
+	// forget which flags were set by a previous execution.
+	applyCmd.Flags().VisitAll(func(f *pflag.Flag) {
+		f.Changed = false
+	})
 }
 
 func runApply(cmd *cobra.Command, args []string) error {
-	var absPath string
-	var err error
-
-	if scriptFile != "" {
-		absPath, err = filepath.Abs(scriptFile)
-	} else if patchFile != "" {
-		absPath, err = filepath.Abs(patchFile)
-	}
+	setRetryPolicy()
+	steps, err := applySteps()
 	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %w", err)
+		return err
 	}
-
-	type repoResult struct {
-		repo string
-		err  error
+	format, err := applog.ParseFormat(logFormat)
+	if err != nil {
+		return err
 	}
+	now := time.Now()
+	runDate := now.Format("2006-01-02")
+	runID := newRunID(now)
 
 	results := make([]repoResult, 0, len(args))
 	var logger *applog.ApplyLogger
+	ensureLogger := func() error {
+		if logger != nil {
+			return nil
+		}
+		var err error
+		if logger, err = applog.NewApplyLogger(format, runID); err != nil {
+			return fmt.Errorf("failed to create error log: %w", err)
+		}
+		return nil
+	}
+
+	var runDir *applog.RunDir
+	if logDir != "" {
+		if runDir, err = applog.NewRunDir(logDir, runID); err != nil {
+			return fmt.Errorf("failed to create run log: %w", err)
+		}
+	}
+
+	// The JSON log records every command and repository, not only failures
+	if format == applog.FormatJSON {
+		if err := ensureLogger(); err != nil {
+			return err
+		}
+	}
+
+	var observers []git.Observer
+	if runDir != nil {
+		observers = append(observers, runDir.LogCommand)
+	}
+	if logger != nil {
+		observers = append(observers, logger.LogCommand)
+	} else {
+		// The text log is created for the first failure, or for the first
+		// fetch, pull or push that is retried
+		observers = append(observers, func(rec git.CommandRecord) {
+			if rec.InRetry() && ensureLogger() == nil {
+				logger.LogCommand(rec)
+			}
+		})
+	}
+	if len(observers) > 0 {
+		git.SetObserver(func(rec git.CommandRecord) {
+			for _, observe := range observers {
+				observe(rec)
+			}
+		})
+		defer git.SetObserver(nil)
+	}
+
+	// The summary page is only written to be opened or kept with the run log
+	summarize := openRemoteURL || runDir != nil
+
+	var stream *applog.Stream
+	if streamOutput {
+		stream = applog.NewStream(os.Stdout)
+	}
 
 	for _, repoPath := range args {
 		var repoErr error
-		var pushOutput string
+		// failedStep names the part of the campaign that failed in the log
+		var failedStep string
+		var prURL string
+		var diffStat string
+		var commit string
+		var detail string
+		var committed bool
+		var keptOutput bytes.Buffer
+		var streamWriter *applog.PrefixWriter
+
+		if runDir != nil {
+			if err := runDir.StartRepo(repoPath); err != nil {
+				return fmt.Errorf("failed to write run log: %w", err)
+			}
+		}
 
-		if stash {
+		// Script and command output goes to the stream and to the log
+		var outputs []io.Writer
+		if stream != nil {
+			streamWriter = stream.Writer(repoPath)
+			outputs = append(outputs, streamWriter)
+		}
+		if keepOutput {
+			outputs = append(outputs, &keptOutput)
+		}
+		var stepOutput io.Writer
+		if len(outputs) > 0 {
+			stepOutput = io.MultiWriter(outputs...)
+		}
+
+		data, repoErr := repoTemplateData(repoPath, baseBranch, runDate, runID)
+		repoBranch, repoMessage := branch, message
+		if repoErr == nil {
+			repoBranch, repoMessage, repoErr = renderBranchAndMessage(&data)
+		}
+		if repoErr != nil {
+			failedStep = "prepare"
+		}
+
+		if repoErr == nil && stash {
 			if err := gitStashChanges(repoPath); err != nil {
 				repoErr = fmt.Errorf("stash failed: %w", err)
+				failedStep = "stash"
 			}
 		}
 
@@ -169,6 +537,7 @@ func runApply(cmd *cobra.Command, args []string) error {
 		if repoErr == nil && baseBranch != "" {
 			if err := gitCheckoutExistingBranch(repoPath, baseBranch); err != nil {
 				repoErr = fmt.Errorf("base branch checkout failed: %w", err)
+				failedStep = "base branch checkout"
 			}
 		}
 
@@ -176,81 +545,247 @@ func runApply(cmd *cobra.Command, args []string) error {
 		if repoErr == nil && pullLatest {
 			if err := gitPullLatest(repoPath); err != nil {
 				repoErr = fmt.Errorf("pull latest failed: %w", err)
+				failedStep = "pull"
 			}
 		}
 
-		// Create and checkout the new branch
+		var remote string
 		if repoErr == nil {
-			if err := gitCheckoutBranch(repoPath, branch); err != nil {
-				repoErr = fmt.Errorf("branch checkout failed: %w", err)
+			if remote, err = resolvePushRemote(repoPath, repoBranch, data); err != nil {
+				repoErr = fmt.Errorf("push remote failed: %w", err)
+				failedStep = "remote"
 			}
 		}
 
-		if repoErr == nil {
-			switch {
-			case command != "":
-				if err := gitExecuteCommand(repoPath, command); err != nil {
-					repoErr = fmt.Errorf("command execution failed: %w", err)
-				}
-			case scriptFile != "":
-				if err := gitExecuteScript(repoPath, absPath); err != nil {
-					repoErr = fmt.Errorf("script execution failed: %w", err)
-				}
-			default:
-				if err := gitApplyPatch(repoPath, absPath); err != nil {
-					repoErr = fmt.Errorf("patch application failed: %w", err)
-				}
+		// Fetch the push remote, even origin without --pull, so the
+		// --on-existing policy sees the campaign branch as it is there and
+		// force pushes are leased against it. A repository without origin
+		// has no campaign branch elsewhere to see
+		if repoErr == nil && (remote != "origin" || data.Remote != "") {
+			if err := gitFetch(repoPath, remote); err != nil {
+				repoErr = fmt.Errorf("push remote fetch failed: %w", err)
+				failedStep = "fetch"
 			}
 		}
 
+		// Create and checkout the new branch, or act on an existing one as
+		// the --on-existing policy says
+		var checkout campaignCheckout
 		if repoErr == nil {
-			if err := gitCommitChanges(repoPath, message, noVerify); err != nil {
+			if checkout, err = checkoutCampaignBranch(repoPath, remote, repoBranch); err != nil {
+				repoErr = fmt.Errorf("branch checkout failed: %w", err)
+				failedStep = "branch checkout"
+			}
+		}
+
+		if repoErr == nil && !checkout.skipped {
+			detail, committed, repoErr = runSteps(repoPath, steps, data, stepOutput)
+			if streamWriter != nil {
+				_ = streamWriter.Flush()
+			}
+			var stepErr *stepError
+			if errors.As(repoErr, &stepErr) {
+				failedStep = stepErr.step
+			}
+		}
+
+		if repoErr == nil && !checkout.skipped {
+			commitChanges := commitRemaining
+			if checkout.amend {
+				commitChanges = amendRemaining
+			}
+			if err := commitChanges(repoPath, reviewMessage(repoMessage, data, "campaign"), committed); err != nil {
 				repoErr = fmt.Errorf("commit failed: %w", err)
+				failedStep = "commit"
+			}
+		}
+
+		if repoErr == nil && !checkout.skipped {
+			// The commit is only recorded in the run history, so it is
+			// fine to miss it
+			commit, _ = gitHeadCommit(repoPath)
+			if summarize {
+				// Like the commit, the diffstat is only informative
+				diffStat, _ = gitDiffStat(repoPath, data.BaseBranch, "HEAD")
 			}
 		}
 
-		if repoErr == nil && push {
-			output, err := gitPushChanges(repoPath, branch, noVerify)
+		if repoErr == nil && !checkout.skipped && push {
+			// Reset and amended branches replace the pushed history, while
+			// Gerrit takes them as new patch sets of the same changes
+			var output string
+			switch {
+			case reviewSystem == reviewGerrit:
+				output, err = pushForReview(repoPath, remote, data)
+			case checkout.rewrite:
+				output, err = gitForcePushChanges(repoPath, remote, repoBranch, checkout.remoteSHA, noVerify)
+			default:
+				output, err = gitPushChanges(repoPath, remote, repoBranch, noVerify)
+			}
 			if err != nil {
 				repoErr = fmt.Errorf("push failed: %w", err)
+				failedStep = "push"
 			} else {
-				pushOutput = output
+				prURL = pullRequestURL(repoPath, remote, data, output)
 			}
 		}
 
-		if repoErr == nil && openRemoteURL {
-			// Ignore the error, because opening a browser can fail depending
-			// on the execution environment
-			_ = git.OpenLastRemoteURL(pushOutput)
+		// Report what was done with an existing branch next to the detail
+		// of the steps
+		if checkout.note != "" && detail != "" {
+			detail = checkout.note + ", " + detail
+		} else if checkout.note != "" {
+			detail = checkout.note
 		}
 
 		if repoErr != nil {
-			if logger == nil {
-				var err error
-				logger, err = applog.NewApplyLogger()
-				if err != nil {
-					return fmt.Errorf("failed to create error log: %w", err)
-				}
+			if err := ensureLogger(); err != nil {
+				return err
+			}
+			logger.LogRepoError(repoPath, failedStep, repoErr)
+		} else if keptOutput.Len() > 0 {
+			if err := ensureLogger(); err != nil {
+				return err
 			}
-			logger.LogRepoError(repoPath, repoErr)
+			logger.LogRepoOutput(repoPath, keptOutput.String())
 		}
 
-		results = append(results, repoResult{repo: repoPath, err: repoErr})
+		if logger != nil {
+			logger.LogRepoResult(repoPath, detail, repoErr)
+		}
+		if runDir != nil {
+			if err := runDir.FinishRepo(detail, repoErr); err != nil {
+				return fmt.Errorf("failed to write run log: %w", err)
+			}
+		}
+
+		results = append(results, repoResult{
+			repo:     repoPath,
+			err:      repoErr,
+			detail:   detail,
+			branch:   repoBranch,
+			message:  repoMessage,
+			commit:   commit,
+			prURL:    prURL,
+			diffStat: diffStat,
+		})
 	}
 
 	// Print results
+	failed := false
 	for _, result := range results {
 		status := "ok"
 		if result.err != nil {
 			status = "fail"
+			failed = true
+		}
+		if result.detail != "" {
+			fmt.Printf("%-4s %s (%s)\n", status, result.repo, result.detail)
+		} else {
+			fmt.Printf("%-4s %s\n", status, result.repo)
 		}
-		fmt.Printf("%-4s %s\n", status, result.repo)
 	}
 
 	if logger != nil {
-		fmt.Printf("\nError details: %s\n", logger.Path())
+		if failed {
+			fmt.Printf("\nError details: %s\n", logger.Path())
+		} else {
+			fmt.Printf("\nOutput log: %s\n", logger.Path())
+		}
 		_ = logger.Close()
 	}
 
+	if runDir != nil {
+		if err := runDir.Close(); err != nil {
+			return fmt.Errorf("failed to write run log: %w", err)
+		}
+		fmt.Printf("\nRun log: %s\n", runDir.Path())
+	}
+
+	if summarize {
+		if err := writeSummary(runID, runDir, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	run := newHistoryRun(cmd, runID, now, results)
+	if logger != nil {
+		run.LogPath = logger.Path()
+	}
+	if runDir != nil {
+		run.RunLogDir = runDir.Path()
+	}
+	saveHistoryRun(run)
+
 	return nil
 }
+
+// repoResult is the outcome of a run for a single repository.
+type repoResult struct {
+	repo    string
+	err     error
+	detail  string
+	branch  string
+	message string
+	commit  string
+	prURL   string
+	// diffStat summarizes the changes of the campaign branch.
+	diffStat string
+}
+
+// repoTemplateData collects the template data for a repository. It runs
+// before the campaign branch is checked out, so the current branch is the
+// base branch unless --base-branch is given.
+func repoTemplateData(repoPath string, base string, date string, runID string) (tmpl.Data, error) {
+	data := tmpl.Data{
+		RepoPath:   repoPath,
+		BaseBranch: base,
+		Date:       date,
+		RunID:      runID,
+		Vars:       templateVars,
+	}
+
+	absPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return data, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	data.RepoName = filepath.Base(absPath)
+
+	if data.BaseBranch == "" {
+		if data.BaseBranch, err = gitCurrentBranch(repoPath); err != nil {
+			return data, fmt.Errorf("base branch detection failed: %w", err)
+		}
+	}
+
+	// Repositories without an origin remote simply have no remote URL
+	data.Remote, _ = gitRemoteURL(repoPath, "origin")
+
+	return data, nil
+}
+
+// renderBranchAndMessage renders the branch and commit message templates for
+// a repository and records the rendered branch in data.
+func renderBranchAndMessage(data *tmpl.Data) (string, string, error) {
+	repoBranch, err := tmpl.Render("branch", branch, *data)
+	if err != nil {
+		return "", "", err
+	}
+	if err := validation.ValidateBranchName(repoBranch); err != nil {
+		return "", "", fmt.Errorf("invalid target branch name %q: %w", repoBranch, err)
+	}
+	data.Branch = repoBranch
+
+	repoMessage, err := tmpl.Render("commit message", message, *data)
+	if err != nil {
+		return "", "", err
+	}
+	return repoBranch, repoMessage, nil
+}
+
+// newRunID returns an identifier for a run, made of its start time and a
+// random suffix so that concurrent runs do not collide.
+func newRunID(now time.Time) string {
+	suffix := make([]byte, 3)
+	_, _ = rand.Read(suffix)
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
@@ -1,24 +1,86 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/vpukhanov/cascade/internal/codemod"
+	"github.com/vpukhanov/cascade/internal/git"
+	"github.com/vpukhanov/cascade/internal/keyedit"
+	applog "github.com/vpukhanov/cascade/internal/log"
 )
 
+func TestMain(m *testing.M) {
+	// Keep the run history of tests out of the user's state directory
+	stateDir, err := os.MkdirTemp("", "cascade-state-*")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_STATE_HOME", stateDir)
+	code := m.Run()
+	os.RemoveAll(stateDir)
+	os.Exit(code)
+}
+
 // Override git functions with mocks
 func resetMocks() {
 	gitCheckoutBranch = func(repoPath, branch string) error { return nil }
 	gitCheckoutExistingBranch = func(repoPath, branch string) error { return nil }
 	gitApplyPatch = func(repoPath, patchPath string) error { return nil }
+	gitHeadCommit = func(repoPath string) (string, error) { return "abc123", nil }
+	gitFetch = func(repoPath, remote string) error { return nil }
+	gitGetBranchStatus = func(repoPath, remote, branch, base string) (git.BranchStatus, error) {
+		return git.BranchStatus{Branch: branch, Base: base}, nil
+	}
 	gitCommitChanges = func(repoPath, message string, noVerify bool) error { return nil }
-	gitExecuteCommand = func(repoPath, command string) error { return nil }
-	gitExecuteScript = func(repoPath, scriptPath string) error { return nil }
+	gitAmendCommit = func(repoPath, message string, noVerify bool) error { return nil }
+	gitExecuteCommand = func(repoPath, command string, opts git.ExecOptions) error { return nil }
+	gitExecuteScript = func(repoPath, scriptPath string, opts git.ExecOptions) error { return nil }
 	gitPullLatest = func(repoPath string) error { return nil }
-	gitPushChanges = func(repoPath, branch string, noVerify bool) (string, error) { return "", nil }
+	gitPushChanges = func(repoPath, remote, branch string, noVerify bool) (string, error) { return "", nil }
+	gitForcePushChanges = func(repoPath, remote, branch, expected string, noVerify bool) (string, error) { return "", nil }
 	gitStashChanges = func(repoPath string) error { return nil }
+	codemodApplyGo = func(repoPath string, opts codemod.GoOptions) (int, error) { return 1, nil }
+	keyeditApply = func(repoPath string, a keyedit.Assignment) error { return nil }
+	codemodReplace = func(repoPath string, opts codemod.ReplaceOptions) (int, error) { return 1, nil }
+	transformRunStarlark = func(repoPath, scriptPath string, vars map[string]string, timeout time.Duration) error { return nil }
+	transformRunWasm = func(repoPath, modulePath string, vars map[string]string, timeout time.Duration) error { return nil }
+	gitHasChanges = func(repoPath string) (bool, error) { return false, nil }
+	gitCurrentBranch = func(repoPath string) (string, error) { return "main", nil }
+	gitRemoteURL = func(repoPath, remote string) (string, error) { return "git@example.com:org/" + repoPath + ".git", nil }
+	sleep = func(time.Duration) {}
+	gitDeleteBranch = func(repoPath, branch string) error { return nil }
+	gitDeleteRemoteBranch = func(repoPath, remote, branch, expected string) error { return nil }
+	gitMergedInto = func(repoPath, ref, base string) (bool, error) { return false, nil }
+	gitRefExists = func(repoPath, ref string) (bool, error) { return false, nil }
+	gitRefCommit = func(repoPath, ref string) (string, error) { return "remote-sha", nil }
+	gitIsAncestor = func(repoPath, ref, of string) (bool, error) { return false, nil }
+	gitRebase = func(repoPath, upstream string) error { return nil }
+	gitPushRemote = func(repoPath, branch string) (string, error) { return "origin", nil }
+	gitEnsureRemote = func(repoPath, name, url string) error { return nil }
+	openURL = func(browser, url string) error { return nil }
+	gitDiffStat = func(repoPath, base, head string) (string, error) { return "", nil }
+	gitPushForReview = func(repoPath, remote, base string, opts git.ReviewOptions, noVerify bool) (string, error) {
+		return "", nil
+	}
+}
+
+// writeTestPatch writes a patch file for the change mode flags, which read it
+// to render it as a template.
+func writeTestPatch(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.patch")
+	if err := os.WriteFile(path, []byte("+fix\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
 }
 
 func TestRunApply(t *testing.T) {
@@ -27,6 +89,10 @@ func TestRunApply(t *testing.T) {
 		repos       []string
 		useScript   bool
 		useCommand  bool
+		useGo       bool
+		useSet      bool
+		useStarlark bool
+		useWasm     bool
 		baseBranch  string
 		pullLatest  bool
 		push        bool
@@ -59,6 +125,38 @@ func TestRunApply(t *testing.T) {
 			wantSuccess: 2,
 			wantErrors:  0,
 		},
+		{
+			name:        "all_success_go",
+			repos:       []string{"repo1", "repo2"},
+			useGo:       true,
+			mockSetup:   func() { resetMocks() },
+			wantSuccess: 2,
+			wantErrors:  0,
+		},
+		{
+			name:        "all_success_set",
+			repos:       []string{"repo1", "repo2"},
+			useSet:      true,
+			mockSetup:   func() { resetMocks() },
+			wantSuccess: 2,
+			wantErrors:  0,
+		},
+		{
+			name:        "all_success_starlark",
+			repos:       []string{"repo1", "repo2"},
+			useStarlark: true,
+			mockSetup:   func() { resetMocks() },
+			wantSuccess: 2,
+			wantErrors:  0,
+		},
+		{
+			name:        "all_success_wasm",
+			repos:       []string{"repo1", "repo2"},
+			useWasm:     true,
+			mockSetup:   func() { resetMocks() },
+			wantSuccess: 2,
+			wantErrors:  0,
+		},
 		{
 			name:        "success_with_base_branch",
 			repos:       []string{"repo1", "repo2"},
@@ -144,7 +242,7 @@ func TestRunApply(t *testing.T) {
 			push:      true,
 			mockSetup: func() {
 				resetMocks()
-				gitPushChanges = func(_, _ string, _ bool) (string, error) {
+				gitPushChanges = func(_, _, _ string, _ bool) (string, error) {
 					return "", fmt.Errorf("push failed")
 				}
 			},
@@ -212,7 +310,7 @@ func TestRunApply(t *testing.T) {
 					return nil
 				}
 				// Fail script execution for repo2
-				gitExecuteScript = func(repoPath, _ string) error {
+				gitExecuteScript = func(repoPath, _ string, _ git.ExecOptions) error {
 					if repoPath == "repo2" {
 						return fmt.Errorf("script error")
 					}
@@ -235,20 +333,78 @@ func TestRunApply(t *testing.T) {
 			useCommand: true,
 			mockSetup: func() {
 				resetMocks()
-				gitExecuteCommand = func(_, _ string) error {
+				gitExecuteCommand = func(_, _ string, _ git.ExecOptions) error {
 					return fmt.Errorf("command failed")
 				}
 			},
 			wantSuccess: 0,
 			wantErrors:  1,
 		},
+		{
+			name:  "all_fail_go",
+			repos: []string{"repo1"},
+			useGo: true,
+			mockSetup: func() {
+				resetMocks()
+				codemodApplyGo = func(_ string, _ codemod.GoOptions) (int, error) {
+					return 0, fmt.Errorf("parse error")
+				}
+			},
+			wantSuccess: 0,
+			wantErrors:  1,
+		},
+		{
+			name:   "some_fail_set",
+			repos:  []string{"repo1", "repo2"},
+			useSet: true,
+			mockSetup: func() {
+				resetMocks()
+				keyeditApply = func(repoPath string, _ keyedit.Assignment) error {
+					if repoPath == "repo2" {
+						return fmt.Errorf("key not found")
+					}
+					return nil
+				}
+			},
+			wantSuccess: 1,
+			wantErrors:  1,
+		},
+		{
+			name:        "all_fail_starlark",
+			repos:       []string{"repo1"},
+			useStarlark: true,
+			mockSetup: func() {
+				resetMocks()
+				transformRunStarlark = func(_, _ string, _ map[string]string, _ time.Duration) error {
+					return fmt.Errorf("path is outside the repository")
+				}
+			},
+			wantSuccess: 0,
+			wantErrors:  1,
+		},
+		{
+			name:    "some_fail_wasm",
+			repos:   []string{"repo1", "repo2"},
+			useWasm: true,
+			mockSetup: func() {
+				resetMocks()
+				transformRunWasm = func(repoPath, _ string, _ map[string]string, _ time.Duration) error {
+					if repoPath == "repo1" {
+						return fmt.Errorf("wasm module exited with code 1")
+					}
+					return nil
+				}
+			},
+			wantSuccess: 1,
+			wantErrors:  1,
+		},
 		{
 			name:      "all_fail_script",
 			repos:     []string{"repo1"},
 			useScript: true,
 			mockSetup: func() {
 				resetMocks()
-				gitExecuteScript = func(_, _ string) error {
+				gitExecuteScript = func(_, _ string, _ git.ExecOptions) error {
 					return fmt.Errorf("script failed")
 				}
 			},
@@ -263,7 +419,27 @@ func TestRunApply(t *testing.T) {
 			tt.mockSetup()
 
 			// Set command, script, or patch file
-			if tt.useCommand {
+			if tt.useWasm {
+				wasmFile = "transform.wasm"
+				command = ""
+				scriptFile = ""
+				patchFile = ""
+			} else if tt.useStarlark {
+				starlarkFile = "transform.star"
+				command = ""
+				scriptFile = ""
+				patchFile = ""
+			} else if tt.useSet {
+				assignments = []keyedit.Assignment{{File: "values.yaml", Path: []string{"image", "tag"}, Value: "1.2.3"}}
+				command = ""
+				scriptFile = ""
+				patchFile = ""
+			} else if tt.useGo {
+				goOptions = codemod.GoOptions{Rewrites: []string{"a -> a"}}
+				command = ""
+				scriptFile = ""
+				patchFile = ""
+			} else if tt.useCommand {
 				command = "echo test"
 				scriptFile = ""
 				patchFile = ""
@@ -273,7 +449,7 @@ func TestRunApply(t *testing.T) {
 				command = ""
 			} else {
 				scriptFile = ""
-				patchFile = "test.patch"
+				patchFile = writeTestPatch(t)
 				command = ""
 			}
 
@@ -307,6 +483,10 @@ func TestRunApply(t *testing.T) {
 			noVerify = false
 			stash = false
 			openRemoteURL = false
+			goOptions = codemod.GoOptions{}
+			assignments = nil
+			starlarkFile = ""
+			wasmFile = ""
 
 			// Verify results
 			if err != nil {
@@ -365,3 +545,263 @@ func TestRunApply(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderBranchAndMessage(t *testing.T) {
+	resetMocks()
+	defer ResetFlags()
+
+	branch = "deps/{{.Date}}-{{.Vars.ticket}}"
+	message = "chore({{.RepoName}}): bump to {{.Vars.version}} on {{.BaseBranch}} via {{.Branch}}"
+	templateVars = map[string]string{"ticket": "OPS-1", "version": "1.2.3"}
+
+	data, err := repoTemplateData("repos/service-a", "", "2026-01-02", "run-1")
+	if err != nil {
+		t.Fatalf("repoTemplateData failed: %v", err)
+	}
+	if data.Remote != "git@example.com:org/repos/service-a.git" {
+		t.Errorf("unexpected remote %q", data.Remote)
+	}
+
+	gotBranch, gotMessage, err := renderBranchAndMessage(&data)
+	if err != nil {
+		t.Fatalf("renderBranchAndMessage failed: %v", err)
+	}
+	if gotBranch != "deps/2026-01-02-OPS-1" {
+		t.Errorf("unexpected branch %q", gotBranch)
+	}
+	if want := "chore(service-a): bump to 1.2.3 on main via deps/2026-01-02-OPS-1"; gotMessage != want {
+		t.Errorf("unexpected message %q, want %q", gotMessage, want)
+	}
+
+	branch = "deps/{{.Vars.ticket}} fix"
+	if _, _, err := renderBranchAndMessage(&data); err == nil {
+		t.Error("expected rendered branch with spaces to be rejected")
+	}
+}
+
+func TestRunApplyStreamAndKeepOutput(t *testing.T) {
+	resetMocks()
+	gitExecuteCommand = func(repoPath, _ string, opts git.ExecOptions) error {
+		if opts.Output != nil {
+			fmt.Fprintf(opts.Output, "working on %s\n", repoPath)
+		}
+		if repoPath == "repo2" {
+			return fmt.Errorf("command failed")
+		}
+		return nil
+	}
+	command = "make gen"
+	streamOutput = true
+	keepOutput = true
+	defer func() {
+		command = ""
+		streamOutput = false
+		keepOutput = false
+	}()
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	err := runApply(nil, []string{"repo1", "repo2"})
+	w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stdout = oldStdout
+
+	if err != nil {
+		t.Fatalf("runApply() unexpected error: %v", err)
+	}
+	output := string(out)
+	for _, want := range []string{"[repo1] working on repo1\n", "[repo2] working on repo2\n"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected streamed line %q, got:\n%s", want, output)
+		}
+	}
+
+	var logPath string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "Error details: ") {
+			logPath = strings.TrimPrefix(line, "Error details: ")
+		}
+	}
+	if logPath == "" {
+		t.Fatalf("expected log path in output:\n%s", output)
+	}
+	defer os.Remove(logPath)
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("read log file: %v", err)
+	}
+	logText := string(data)
+	if !strings.Contains(logText, "output:") || !strings.Contains(logText, "  working on repo1") {
+		t.Errorf("expected kept output of the successful repository, got:\n%s", logText)
+	}
+	if !strings.Contains(logText, "command failed") {
+		t.Errorf("expected error of the failed repository, got:\n%s", logText)
+	}
+}
+
+func TestRunApplyJSONLog(t *testing.T) {
+	resetMocks()
+	gitApplyPatch = func(repoPath, _ string) error {
+		if repoPath == "repo2" {
+			return fmt.Errorf("exit status 1")
+		}
+		return nil
+	}
+	patchFile = writeTestPatch(t)
+	logFormat = "json"
+	defer func() {
+		patchFile = ""
+		logFormat = "text"
+	}()
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	err := runApply(nil, []string{"repo1", "repo2"})
+	w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stdout = oldStdout
+
+	if err != nil {
+		t.Fatalf("runApply() unexpected error: %v", err)
+	}
+	output := string(out)
+	_, logPath, found := strings.Cut(output, "Error details: ")
+	if !found {
+		t.Fatalf("expected log path in output:\n%s", output)
+	}
+	logPath = strings.TrimSpace(logPath)
+	defer os.Remove(logPath)
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("read log file: %v", err)
+	}
+	var events []applog.Event
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var event applog.Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("invalid JSON event %q: %v", line, err)
+		}
+		events = append(events, event)
+	}
+
+	var summary []string
+	for _, event := range events {
+		summary = append(summary, event.Repo+":"+event.Event+":"+event.Status+event.Step)
+		if event.RunID == "" {
+			t.Errorf("event without run ID: %+v", event)
+		}
+	}
+	want := []string{"repo1:result:ok", "repo2:error:patch", "repo2:result:fail"}
+	if strings.Join(summary, " ") != strings.Join(want, " ") {
+		t.Errorf("events = %v, want %v", summary, want)
+	}
+	if len(events) == 3 && strings.Join(events[1].Errors, "|") != "patch application failed: exit status 1|exit status 1" {
+		t.Errorf("unexpected error chain: %q", events[1].Errors)
+	}
+}
+
+func TestRunApplyExistingBranch(t *testing.T) {
+	tests := []struct {
+		onExisting string
+		update     string
+		existing   bool
+		// diverged is set when the remote branch has commits the local
+		// branch lacks
+		diverged bool
+		want     []string
+		detail   string
+	}{
+		{"", "", false, false, []string{"checkout -B", "commit", "push"}, ""},
+		{"", "", true, false, nil, ""},
+		{"skip", "", true, false, nil, "skipped existing branch"},
+		{"reset", "", true, false, []string{"checkout -B", "commit", "force-push"}, "reset existing branch"},
+		{"update", "", true, false, []string{"checkout", "commit", "push"}, "updated existing branch (commit)"},
+		{"", "commit", true, false, []string{"checkout", "commit", "push"}, "updated existing branch (commit)"},
+		{"", "commit", false, false, []string{"checkout -B", "commit", "push"}, ""},
+		{"", "amend", true, false, []string{"checkout", "amend", "force-push"}, "updated existing branch (amend)"},
+		{"", "amend", false, false, []string{"checkout -B", "commit", "push"}, ""},
+		{"", "regenerate", true, false, []string{"checkout -B", "commit", "force-push"}, "updated existing branch (regenerate)"},
+		{"", "regenerate", true, true, nil, "skipped diverged branch, origin/deps/upgrade has commits the local branch lacks"},
+		{"", "amend", true, true, nil, "skipped diverged branch, origin/deps/upgrade has commits the local branch lacks"},
+		{"", "commit", true, true, []string{"checkout", "commit", "push"}, "updated existing branch (commit)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("on-existing %q update %q existing %v diverged %v", tt.onExisting, tt.update, tt.existing, tt.diverged), func(t *testing.T) {
+			resetMocks()
+			defer ResetFlags()
+			var calls []string
+			record := func(call string) { calls = append(calls, call) }
+			gitRefExists = func(repoPath, ref string) (bool, error) { return tt.existing, nil }
+			gitIsAncestor = func(repoPath, ref, of string) (bool, error) { return !tt.diverged, nil }
+			gitCheckoutBranch = func(repoPath, branch string) error { record("checkout -B"); return nil }
+			gitCheckoutExistingBranch = func(repoPath, branch string) error { record("checkout"); return nil }
+			gitCommitChanges = func(repoPath, message string, noVerify bool) error { record("commit"); return nil }
+			gitAmendCommit = func(repoPath, message string, noVerify bool) error { record("amend"); return nil }
+			gitPushChanges = func(repoPath, remote, branch string, noVerify bool) (string, error) { record("push"); return "", nil }
+			gitForcePushChanges = func(repoPath, remote, branch, expected string, noVerify bool) (string, error) {
+				// Leased against the commit seen when the branch was checked
+				if expected != "remote-sha" {
+					t.Errorf("expected a lease on remote-sha, got %q", expected)
+				}
+				record("force-push")
+				return "", nil
+			}
+			command = "make upgrade"
+			branch = "deps/upgrade"
+			message = "Upgrade"
+			push = true
+			onExisting = tt.onExisting
+			updateMode = tt.update
+
+			out := captureRunOutput(t, func() error { return runApply(nil, []string{"repo1"}) })
+			if !slices.Equal(calls, tt.want) {
+				t.Errorf("expected calls %v, got %v", tt.want, calls)
+			}
+			status := "ok"
+			if tt.existing && tt.onExisting == "" && tt.update == "" {
+				status = "fail"
+			}
+			result := strings.SplitN(out, "\n", 2)[0]
+			if !strings.HasPrefix(result, status+" ") || !strings.Contains(result, tt.detail) {
+				t.Errorf("expected %s result with detail %q, got %q", status, tt.detail, result)
+			}
+		})
+	}
+}
+
+func TestValidateApplyExistingBranchOptions(t *testing.T) {
+	defer ResetFlags()
+
+	ResetFlags()
+	command = "make upgrade"
+	branch = "deps/upgrade"
+	message = "Upgrade"
+	updateMode = "rebase"
+	if err := validateApply(applyCmd, nil); err == nil || !strings.Contains(err.Error(), "invalid --update") {
+		t.Errorf("expected invalid update mode error, got %v", err)
+	}
+	updateMode = "commit"
+	onExisting = "overwrite"
+	if err := validateApply(applyCmd, nil); err == nil || !strings.Contains(err.Error(), "invalid --on-existing") {
+		t.Errorf("expected invalid policy error, got %v", err)
+	}
+	onExisting = "skip"
+	if err := validateApply(applyCmd, nil); err == nil || !strings.Contains(err.Error(), "--update only applies with --on-existing=update") {
+		t.Errorf("expected conflicting policy error, got %v", err)
+	}
+
+	ResetFlags()
+	recipeFile = filepath.Join(t.TempDir(), "recipe.yaml")
+	recipeContent := "branch: deps/upgrade\nmessage: Upgrade\nupdate: amend\nsteps:\n  - command: make upgrade\n    commit: Run make upgrade\n"
+	if err := os.WriteFile(recipeFile, []byte(recipeContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateApply(applyCmd, nil); err == nil || !strings.Contains(err.Error(), "--update=amend cannot be used") {
+		t.Errorf("expected amend with step commits error, got %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vpukhanov/cascade/internal/forge"
+	"github.com/vpukhanov/cascade/internal/git"
+	"github.com/vpukhanov/cascade/internal/validation"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cleanupBranch string
+	cleanupBase   string
+	cleanupFetch  bool
+	cleanupDryRun bool
+
+	gitDeleteBranch       = git.DeleteBranch
+	gitDeleteRemoteBranch = git.DeleteRemoteBranch
+	gitMergedInto         = git.MergedInto
+)
+
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup [repositories...]",
+	Short: "Delete merged campaign branches locally and on the push remote",
+	Long: `Delete the campaign branch locally and on the remote it is pushed to in every repository where it is merged into the base branch, or where its pull request is merged or closed. The local and the remote branch are checked and deleted on their own.
+Repositories that have the campaign branch checked out are switched back to the base branch first. Branches that are not merged and have an open pull request, or none, are kept.`,
+	Example: `cascade cleanup --branch update-logging --dry-run ./repo1 ./repo2
+cascade cleanup --branch update-logging --base-branch develop ./repos/*`,
+	Args:    cobra.MinimumNArgs(1),
+	RunE:    runCleanup,
+	PreRunE: validateCleanup,
+}
+
+func init() {
+	rootCmd.AddCommand(cleanupCmd)
+
+	cleanupCmd.Flags().StringVar(&cleanupBranch, "branch", "", "Name of the campaign branch, can be a template using the repository name")
+	cleanupCmd.Flags().StringVar(&cleanupBase, "base-branch", "", "Branch the campaign branch is merged into (default: the branch origin/HEAD points to, or main or master)")
+	cleanupCmd.Flags().BoolVar(&cleanupFetch, "fetch", true, "Fetch from origin and the push remote first, so merges and deleted remote branches are known")
+	cleanupCmd.Flags().BoolVar(&cleanupDryRun, "dry-run", false, "Report what would be deleted without deleting")
+	addForgeFlags(cleanupCmd)
+	addRemoteFlags(cleanupCmd)
+	addRetryFlags(cleanupCmd)
+}
+
+// ResetCleanupFlags resets the global flag variables of cleanup to their defaults.
+func ResetCleanupFlags() {
+	cleanupBranch = ""
+	cleanupBase = ""
+	cleanupFetch = true
+	cleanupDryRun = false
+}
+
+func validateCleanup(cmd *cobra.Command, args []string) error {
+	if err := validateCampaignBranch(cleanupBranch, args); err != nil {
+		return err
+	}
+	if cleanupBase != "" {
+		if err := validation.ValidateBranchName(cleanupBase); err != nil {
+			return fmt.Errorf("invalid base branch name: %w", err)
+		}
+	}
+	if err := validateRemoteOptions(); err != nil {
+		return err
+	}
+	if err := parseTemplateVars(); err != nil {
+		return err
+	}
+	return validateRetryFlags()
+}
+
+func runCleanup(cmd *cobra.Command, args []string) error {
+	setRetryPolicy()
+	ctx := context.Background()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tBRANCH\tRESULT\tDELETED\tREASON")
+	for _, repoPath := range args {
+		outcome := cleanupRepo(ctx, repoPath)
+		deleted := "-"
+		if len(outcome.deleted) > 0 {
+			deleted = strings.Join(outcome.deleted, ", ")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", repoPath, outcome.branch, outcome.result, deleted, outcome.reason)
+	}
+	return w.Flush()
+}
+
+// cleanupOutcome is what happened to the campaign branch of a repository,
+// with the reason it was deleted, kept or the cleanup failed.
+type cleanupOutcome struct {
+	branch  string
+	result  string
+	deleted []string
+	reason  string
+}
+
+// cleanupRepo deletes the campaign branch of the repository if it is merged
+// or its pull request is closed.
+func cleanupRepo(ctx context.Context, repoPath string) cleanupOutcome {
+	outcome := cleanupOutcome{branch: "-"}
+	failed := func(err error) cleanupOutcome {
+		outcome.result, outcome.reason = "failed", firstLine(err.Error())
+		return outcome
+	}
+	skipped := func(reason string) cleanupOutcome {
+		outcome.result, outcome.reason = "skipped", reason
+		return outcome
+	}
+
+	repoBranch, err := renderCampaignBranch(repoPath, cleanupBranch)
+	if err != nil {
+		return failed(err)
+	}
+	outcome.branch = repoBranch
+
+	remote, err := campaignRemote(repoPath, repoBranch)
+	if err != nil {
+		return failed(fmt.Errorf("remote setup failed: %w", err))
+	}
+	if cleanupFetch {
+		if err := fetchRemotes(repoPath, remote); err != nil {
+			return failed(fmt.Errorf("fetch failed: %w", err))
+		}
+	}
+	status, err := gitGetBranchStatus(repoPath, remote, repoBranch, cleanupBase)
+	if err != nil {
+		return failed(fmt.Errorf("status failed: %w", err))
+	}
+	if !status.Exists() {
+		return skipped("branch does not exist")
+	}
+	if repoBranch == status.Base {
+		return skipped("branch is the base branch")
+	}
+
+	refs, err := cleanupRefs(ctx, repoPath, remote, status)
+	if err != nil {
+		return failed(err)
+	}
+	outcome.reason = refsReason(refs)
+	var deleteLocal, deleteRemote bool
+	for _, ref := range refs {
+		if ref.deletable {
+			deleteLocal = deleteLocal || ref.name == "local"
+			deleteRemote = deleteRemote || ref.name != "local"
+		}
+	}
+	if !deleteLocal && !deleteRemote {
+		return skipped(outcome.reason)
+	}
+	if deleteLocal && status.CheckedOut && status.Dirty {
+		// Switching branches would carry the changes over or fail
+		return skipped(outcome.reason + ", but the branch is checked out with uncommitted changes")
+	}
+
+	if cleanupDryRun {
+		outcome.result = "would delete"
+		if deleteLocal {
+			outcome.deleted = append(outcome.deleted, "local")
+		}
+		if deleteRemote {
+			outcome.deleted = append(outcome.deleted, remote)
+		}
+		return outcome
+	}
+
+	if deleteLocal {
+		if status.CheckedOut {
+			if err := gitCheckoutExistingBranch(repoPath, status.Base); err != nil {
+				return failed(fmt.Errorf("base branch checkout failed: %w", err))
+			}
+		}
+		if err := gitDeleteBranch(repoPath, repoBranch); err != nil {
+			return failed(fmt.Errorf("branch delete failed: %w", err))
+		}
+		outcome.deleted = append(outcome.deleted, "local")
+	}
+	if deleteRemote {
+		if err := gitDeleteRemoteBranch(repoPath, remote, repoBranch, status.RemoteSHA); err != nil {
+			return failed(fmt.Errorf("remote branch delete failed: %w", err))
+		}
+		outcome.deleted = append(outcome.deleted, remote)
+	}
+	outcome.result = "deleted"
+	return outcome
+}
+
+// cleanupRef is the local or remote-tracking ref of a campaign branch, with
+// whether it can be deleted and why.
+type cleanupRef struct {
+	// name is "local" or the name of the remote.
+	name      string
+	ref       string
+	sha       string
+	deletable bool
+	reason    string
+}
+
+// cleanupRefs checks the local branch and the branch on the remote on their
+// own, since either can have commits the other lacks. A ref can be deleted
+// when it is merged into the base branch, or when its pull request is merged
+// or closed at the commit the ref points to. Squash and rebase merges leave
+// the commits of the branch out of the base branch, so the pull request is
+// checked for those.
+func cleanupRefs(ctx context.Context, repoPath string, remote string, status git.BranchStatus) ([]cleanupRef, error) {
+	var refs []cleanupRef
+	if status.Local {
+		refs = append(refs, cleanupRef{name: "local", ref: "refs/heads/" + status.Branch, sha: status.LocalSHA})
+	}
+	if status.Remote {
+		refs = append(refs, cleanupRef{name: remote, ref: "refs/remotes/" + remote + "/" + status.Branch, sha: status.RemoteSHA})
+	}
+
+	var pr *forge.PullRequest
+	lookedUp := false
+	for i := range refs {
+		ref := &refs[i]
+		merged, err := gitMergedInto(repoPath, ref.ref, status.Base)
+		if err != nil {
+			return nil, fmt.Errorf("merge check failed: %w", err)
+		}
+		if merged {
+			ref.deletable, ref.reason = true, "merged into "+status.Base
+			continue
+		}
+
+		if !lookedUp {
+			_, pr, err = findPullRequest(ctx, repoPath, cleanupBranch)
+			if err != nil && !errors.Is(err, forge.ErrNotFound) {
+				return nil, err
+			}
+			lookedUp = true
+		}
+		switch {
+		case pr == nil:
+			ref.reason = "not merged and has no pull request"
+		case pr.State != forge.StateMerged && pr.State != forge.StateClosed:
+			ref.reason = fmt.Sprintf("not merged and pull request #%d is %s", pr.Number, pr.State)
+		case pr.HeadSHA != ref.sha:
+			ref.reason = fmt.Sprintf("not merged and pull request #%d is %s at another commit", pr.Number, pr.State)
+		default:
+			ref.deletable, ref.reason = true, fmt.Sprintf("pull request #%d is %s", pr.Number, pr.State)
+		}
+	}
+	return refs, nil
+}
+
+// refsReason describes why the refs are deleted or kept, naming the refs
+// when their reasons differ.
+func refsReason(refs []cleanupRef) string {
+	if len(refs) == 2 && refs[0].reason != refs[1].reason {
+		return fmt.Sprintf("%s %s, %s %s", refs[0].name, refs[0].reason, refs[1].name, refs[1].reason)
+	}
+	return refs[0].reason
+}
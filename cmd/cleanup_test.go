@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vpukhanov/cascade/internal/git"
+)
+
+func TestRunCleanup(t *testing.T) {
+	// repo4 and repo8 have a closed pull request, the one of repo8 closed
+	// before the branch got another commit, and the others are described by
+	// their branch status below
+	closed := map[string]string{
+		"/repos/org/repo4/pulls?direction=desc&head=org%3Adeps%2Frepo4&sort=created&state=all": `[{"number": 4}]`,
+		"/repos/org/repo4/pulls/4":                                                             `{"number": 4, "state": "closed", "head": {"sha": "sha4"}}`,
+		"/repos/org/repo4/pulls/4/reviews?per_page=100":                                        `[]`,
+		"/repos/org/repo4/commits/sha4/check-runs?per_page=100":                                `{"check_runs": []}`,
+		"/repos/org/repo4/commits/sha4/status":                                                 `{"statuses": []}`,
+		"/repos/org/repo7/pulls?direction=desc&head=org%3Adeps%2Frepo7&sort=created&state=all": `[]`,
+		"/repos/org/repo8/pulls?direction=desc&head=org%3Adeps%2Frepo8&sort=created&state=all": `[{"number": 8}]`,
+		"/repos/org/repo8/pulls/8":                                                             `{"number": 8, "state": "closed", "head": {"sha": "sha8"}}`,
+		"/repos/org/repo8/pulls/8/reviews?per_page=100":                                        `[]`,
+		"/repos/org/repo8/commits/sha8/check-runs?per_page=100":                                `{"check_runs": []}`,
+		"/repos/org/repo8/commits/sha8/status":                                                 `{"statuses": []}`,
+	}
+	statuses := map[string]git.BranchStatus{
+		"repo1": {Local: true, Remote: true, Ahead: 2},
+		"repo2": {Local: true, Ahead: 1},
+		"repo3": {Local: true, Remote: true, CheckedOut: true, RemoteSHA: "sha3"},
+		"repo4": {Remote: true, Ahead: 1, RemoteSHA: "sha4"},
+		"repo5": {},
+		"repo6": {Local: true, CheckedOut: true, Dirty: true},
+		"repo7": {Local: true, Remote: true, RemoteSHA: "sha7"},
+		"repo8": {Remote: true, Ahead: 2, RemoteSHA: "sha8-review"},
+	}
+	// The local branch of repo7 is merged, but its remote branch got a
+	// commit that is not
+	unmerged := map[string]bool{"refs/remotes/origin/deps/repo7": true}
+	want := map[string]string{
+		"repo1": "repo1 deps/repo1 skipped - not merged and pull request #1 is open",
+		"repo2": "repo2 deps/repo2 skipped - not merged and has no pull request",
+		"repo3": "repo3 deps/repo3 deleted local, origin merged into main",
+		"repo4": "repo4 deps/repo4 deleted origin pull request #4 is closed",
+		"repo5": "repo5 deps/repo5 skipped - branch does not exist",
+		"repo6": "repo6 deps/repo6 skipped - merged into main, but the branch is checked out with uncommitted changes",
+		"repo7": "repo7 deps/repo7 deleted local local merged into main, origin not merged and has no pull request",
+		"repo8": "repo8 deps/repo8 skipped - not merged and pull request #8 is closed at another commit",
+	}
+
+	for _, dryRun := range []bool{false, true} {
+		resetMocks()
+		ResetCleanupFlags()
+		ResetPRsFlags()
+		server := newFakeGitHub(t, closed)
+		cleanupBranch = "deps/{{.RepoName}}"
+		cleanupDryRun = dryRun
+		forgeName = "github"
+		forgeAPIURL = server.URL
+
+		var calls []string
+		gitFetch = func(repoPath, remote string) error {
+			calls = append(calls, "fetch "+repoPath)
+			return nil
+		}
+		gitGetBranchStatus = func(repoPath, remote, branch, base string) (git.BranchStatus, error) {
+			status := statuses[repoPath]
+			status.Branch, status.Base = branch, "main"
+			return status, nil
+		}
+		gitMergedInto = func(repoPath, ref, base string) (bool, error) {
+			return statuses[repoPath].Ahead == 0 && !unmerged[ref], nil
+		}
+		gitCheckoutExistingBranch = func(repoPath, branch string) error {
+			calls = append(calls, "checkout "+repoPath+" "+branch)
+			return nil
+		}
+		gitDeleteBranch = func(repoPath, branch string) error {
+			calls = append(calls, "delete "+repoPath+" "+branch)
+			return nil
+		}
+		gitDeleteRemoteBranch = func(repoPath, remote, branch, expected string) error {
+			calls = append(calls, "delete "+repoPath+" "+remote+"/"+branch+"@"+expected)
+			return nil
+		}
+
+		repos := []string{"repo1", "repo2", "repo3", "repo4", "repo5", "repo6", "repo7", "repo8"}
+		out := captureRunOutput(t, func() error { return runCleanup(nil, repos) })
+		rows := map[string]string{}
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n")[1:] {
+			row := strings.Join(strings.Fields(line), " ")
+			rows[strings.Fields(row)[0]] = row
+		}
+		for repo, row := range want {
+			if dryRun {
+				row = strings.Replace(row, " deleted ", " would delete ", 1)
+			}
+			if rows[repo] != row {
+				t.Errorf("dry run %v: expected row %q, got %q", dryRun, row, rows[repo])
+			}
+		}
+
+		wantCalls := []string{"fetch repo1", "fetch repo2", "fetch repo3"}
+		if !dryRun {
+			wantCalls = append(wantCalls, "checkout repo3 main", "delete repo3 deps/repo3", "delete repo3 origin/deps/repo3@sha3")
+		}
+		wantCalls = append(wantCalls, "fetch repo4")
+		if !dryRun {
+			wantCalls = append(wantCalls, "delete repo4 origin/deps/repo4@sha4")
+		}
+		wantCalls = append(wantCalls, "fetch repo5", "fetch repo6", "fetch repo7")
+		if !dryRun {
+			wantCalls = append(wantCalls, "delete repo7 deps/repo7")
+		}
+		wantCalls = append(wantCalls, "fetch repo8")
+		if !slices.Equal(calls, wantCalls) {
+			t.Errorf("dry run %v: expected calls %v, got %v", dryRun, wantCalls, calls)
+		}
+	}
+	ResetCleanupFlags()
+	ResetPRsFlags()
+}
+
+func TestRunCleanupPushRemote(t *testing.T) {
+	resetMocks()
+	defer ResetCleanupFlags()
+	defer ResetRemoteFlags()
+	var calls []string
+	gitFetch = func(repoPath, remote string) error {
+		calls = append(calls, "fetch "+remote)
+		return nil
+	}
+	gitGetBranchStatus = func(repoPath, remote, branch, base string) (git.BranchStatus, error) {
+		return git.BranchStatus{Branch: branch, Base: "main", Local: true, Remote: true}, nil
+	}
+	gitMergedInto = func(repoPath, ref, base string) (bool, error) {
+		calls = append(calls, "merged "+ref)
+		return true, nil
+	}
+	gitDeleteBranch = func(repoPath, branch string) error {
+		calls = append(calls, "delete "+branch)
+		return nil
+	}
+	gitDeleteRemoteBranch = func(repoPath, remote, branch, expected string) error {
+		calls = append(calls, "delete "+remote+"/"+branch)
+		return nil
+	}
+	cleanupBranch = "deps/upgrade"
+	pushRemote = "upstream"
+
+	out := captureRunOutput(t, func() error { return runCleanup(nil, []string{"repo1"}) })
+	want := []string{"fetch origin", "fetch upstream", "merged refs/heads/deps/upgrade", "merged refs/remotes/upstream/deps/upgrade", "delete deps/upgrade", "delete upstream/deps/upgrade"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("expected calls %v, got %v", want, calls)
+	}
+	if !strings.Contains(out, "local, upstream") {
+		t.Errorf("expected the branch to be deleted on upstream, got:\n%s", out)
+	}
+}
+
+func TestValidateCleanup(t *testing.T) {
+	defer ResetCleanupFlags()
+
+	ResetCleanupFlags()
+	if err := validateCleanup(nil, nil); err == nil || !strings.Contains(err.Error(), "--branch is required") {
+		t.Errorf("expected missing branch error, got %v", err)
+	}
+
+	cleanupBranch = "deps/{{.RepoName}}"
+	cleanupBase = "bad..name"
+	if err := validateCleanup(nil, nil); err == nil || !strings.Contains(err.Error(), "invalid base branch name") {
+		t.Errorf("expected invalid base branch error, got %v", err)
+	}
+}
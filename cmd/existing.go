@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Update modes for existing campaign branches.
+const (
+	updateCommit     = "commit"
+	updateAmend      = "amend"
+	updateRegenerate = "regenerate"
+)
+
+// Policies for campaign branches that already exist.
+const (
+	onExistingFail   = "fail"
+	onExistingSkip   = "skip"
+	onExistingReset  = "reset"
+	onExistingUpdate = "update"
+)
+
+func validateExistingBranchOptions() error {
+	switch updateMode {
+	case "", updateCommit, updateAmend, updateRegenerate:
+	default:
+		return fmt.Errorf("invalid --update %q, must be commit, amend or regenerate", updateMode)
+	}
+	switch onExisting {
+	case "", onExistingFail, onExistingSkip, onExistingReset, onExistingUpdate:
+	default:
+		return fmt.Errorf("invalid --on-existing %q, must be fail, skip, reset or update", onExisting)
+	}
+	if updateMode != "" && existingPolicy() != onExistingUpdate {
+		return fmt.Errorf("--update only applies with --on-existing=update")
+	}
+	if updateMode == updateAmend && loadedRecipe != nil && loadedRecipe.CommitsAnyStep() {
+		return fmt.Errorf("--update=amend cannot be used with recipe steps that make their own commits")
+	}
+	return nil
+}
+
+// existingPolicy returns the --on-existing policy, which defaults to
+// updating the branch when an update mode is given and to failing
+// otherwise, so that no commits are thrown away by accident.
+func existingPolicy() string {
+	switch {
+	case onExisting != "":
+		return onExisting
+	case updateMode != "":
+		return onExistingUpdate
+	default:
+		return onExistingFail
+	}
+}
+
+// existingOnly makes apply skip repositories without the campaign branch
+// instead of creating it. It is set by sync, which regenerates existing
+// campaign branches and must not bring back ones that were cleaned up.
+var existingOnly bool
+
+// campaignCheckout describes how the campaign branch was checked out.
+type campaignCheckout struct {
+	// skipped is set when the branch exists and the repository is left
+	// alone.
+	skipped bool
+	// amend makes the changes amend the last commit of the branch.
+	amend bool
+	// rewrite replaces the history of the branch on push.
+	rewrite bool
+	// remoteSHA is the commit of the branch on the push remote when it was
+	// checked, empty when it does not exist there. Force pushes are leased
+	// against it.
+	remoteSHA string
+	// note reports the policy applied to an existing branch.
+	note string
+}
+
+// checkoutCampaignBranch checks out the campaign branch. A new branch is
+// created from the checked out base branch, unless only existing branches
+// are updated, and an existing one, locally or on the push remote, is
+// handled as the --on-existing policy and --update mode say.
+func checkoutCampaignBranch(repoPath string, remote string, repoBranch string) (campaignCheckout, error) {
+	var checkout campaignCheckout
+	var where []string
+	localRef := "refs/heads/" + repoBranch
+	remoteRef := "refs/remotes/" + remote + "/" + repoBranch
+	local, err := gitRefExists(repoPath, localRef)
+	if err != nil {
+		return checkout, err
+	}
+	if local {
+		where = append(where, "locally")
+	}
+	onRemote, err := gitRefExists(repoPath, remoteRef)
+	if err != nil {
+		return checkout, err
+	}
+	if onRemote {
+		where = append(where, "on "+remote)
+		if checkout.remoteSHA, err = gitRefCommit(repoPath, remoteRef); err != nil {
+			return checkout, err
+		}
+	}
+	if len(where) == 0 {
+		if existingOnly {
+			checkout.skipped = true
+			checkout.note = "skipped, branch does not exist"
+			return checkout, nil
+		}
+		return checkout, gitCheckoutBranch(repoPath, repoBranch)
+	}
+
+	switch existingPolicy() {
+	case onExistingSkip:
+		checkout.skipped = true
+		checkout.note = "skipped existing branch"
+		return checkout, nil
+	case onExistingReset:
+		checkout.rewrite = true
+		checkout.note = "reset existing branch"
+		return checkout, gitCheckoutBranch(repoPath, repoBranch)
+	case onExistingUpdate:
+		mode := updateMode
+		if mode == "" {
+			mode = updateCommit
+		}
+		if mode != updateCommit && local && onRemote {
+			// Replacing the branch would drop commits others pushed to
+			// it, like review fixes, unless the local branch has them too
+			contained, err := gitIsAncestor(repoPath, remoteRef, localRef)
+			if err != nil {
+				return checkout, err
+			}
+			if !contained {
+				checkout.skipped = true
+				checkout.note = "skipped diverged branch, " + remote + "/" + repoBranch + " has commits the local branch lacks"
+				return checkout, nil
+			}
+		}
+		checkout.note = "updated existing branch (" + mode + ")"
+		if mode == updateRegenerate {
+			checkout.rewrite = true
+			return checkout, gitCheckoutBranch(repoPath, repoBranch)
+		}
+		checkout.amend = mode == updateAmend
+		checkout.rewrite = checkout.amend
+		return checkout, gitCheckoutExistingBranch(repoPath, repoBranch)
+	default:
+		return checkout, fmt.Errorf("branch %s already exists %s, use --on-existing to skip, reset or update it", repoBranch, strings.Join(where, " and "))
+	}
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vpukhanov/cascade/internal/forge"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	mergeBranch        string
+	mergeMethod        string
+	mergeRequireChecks bool
+	mergeMinApprovals  int
+	mergeDelay         time.Duration
+	mergeLimit         int
+	mergeDryRun        bool
+
+	sleep = time.Sleep
+)
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge [repositories...]",
+	Short: "Merge the campaign pull requests that are ready",
+	Long: `Merge the pull request of a campaign branch in every repository where it is open, not a draft, free of conflicts, approved and green.
+Pull requests that do not meet the conditions are skipped with the reasons. The code host is detected from the origin remote, like for the prs command.`,
+	Example: `cascade merge --branch update-logging --dry-run ./repo1 ./repo2
+cascade merge --branch update-logging --method squash --min-approvals 2 --delay 10s ./repos/*`,
+	Args:    cobra.MinimumNArgs(1),
+	RunE:    runMerge,
+	PreRunE: validateMerge,
+}
+
+func init() {
+	rootCmd.AddCommand(mergeCmd)
+
+	mergeCmd.Flags().StringVar(&mergeBranch, "branch", "", "Name of the campaign branch, can be a template using the repository name")
+	mergeCmd.Flags().StringVar(&mergeMethod, "method", "merge", "Merge method, 'merge', 'squash' or 'rebase'")
+	mergeCmd.Flags().BoolVar(&mergeRequireChecks, "require-checks", true, "Only merge pull requests whose CI checks all passed")
+	mergeCmd.Flags().IntVar(&mergeMinApprovals, "min-approvals", 1, "Number of approvals a pull request needs")
+	mergeCmd.Flags().DurationVar(&mergeDelay, "delay", time.Second, "Time to wait between merges, to spread the load on CI and the code host")
+	mergeCmd.Flags().IntVar(&mergeLimit, "limit", 0, "Maximum number of pull requests to merge, 0 for no limit")
+	mergeCmd.Flags().BoolVar(&mergeDryRun, "dry-run", false, "Report what would be merged without merging")
+	addForgeFlags(mergeCmd)
+	addRemoteFlags(mergeCmd)
+}
+
+// ResetMergeFlags resets the global flag variables of merge to their defaults.
+func ResetMergeFlags() {
+	mergeBranch = ""
+	mergeMethod = "merge"
+	mergeRequireChecks = true
+	mergeMinApprovals = 1
+	mergeDelay = time.Second
+	mergeLimit = 0
+	mergeDryRun = false
+}
+
+func validateMerge(cmd *cobra.Command, args []string) error {
+	if err := validateCampaignBranch(mergeBranch, args); err != nil {
+		return err
+	}
+	if _, err := forge.ParseMergeMethod(mergeMethod); err != nil {
+		return fmt.Errorf("invalid --method: %w", err)
+	}
+	if mergeMinApprovals < 0 {
+		return fmt.Errorf("--min-approvals cannot be negative")
+	}
+	if mergeDelay < 0 {
+		return fmt.Errorf("--delay cannot be negative")
+	}
+	if mergeLimit < 0 {
+		return fmt.Errorf("--limit cannot be negative")
+	}
+	if err := validateRemoteOptions(); err != nil {
+		return err
+	}
+	if err := parseTemplateVars(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func runMerge(cmd *cobra.Command, args []string) error {
+	method, err := forge.ParseMergeMethod(mergeMethod)
+	if err != nil {
+		return err
+	}
+	conditions := forge.MergeConditions{RequireChecks: mergeRequireChecks, MinApprovals: mergeMinApprovals}
+	ctx := context.Background()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tPR\tRESULT\tREASON")
+	merged := 0
+	for _, repoPath := range args {
+		outcome := mergeRepo(ctx, repoPath, method, conditions, merged)
+		if outcome.result == "merged" || outcome.result == "would merge" {
+			merged++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", repoPath, outcome.pr, outcome.result, outcome.reason)
+	}
+	return w.Flush()
+}
+
+// mergeOutcome is what happened to the pull request of a repository, with
+// the reason it was skipped or failed.
+type mergeOutcome struct {
+	pr     string
+	result string
+	reason string
+}
+
+// mergeRepo merges the pull request of the repository if it is ready.
+// merged is the number of pull requests merged so far.
+func mergeRepo(ctx context.Context, repoPath string, method forge.MergeMethod, conditions forge.MergeConditions, merged int) mergeOutcome {
+	outcome := mergeOutcome{pr: "-"}
+	failed := func(err error) mergeOutcome {
+		outcome.result, outcome.reason = "failed", firstLine(err.Error())
+		return outcome
+	}
+
+	repoBranch, err := renderCampaignBranch(repoPath, mergeBranch)
+	if err != nil {
+		return failed(err)
+	}
+	f, err := repoForge(repoPath, repoBranch)
+	if err != nil {
+		return failed(err)
+	}
+	pr, err := f.FindPullRequest(ctx, repoBranch)
+	if errors.Is(err, forge.ErrNotFound) {
+		outcome.result, outcome.reason = "skipped", "no pull request"
+		return outcome
+	}
+	if err != nil {
+		return failed(fmt.Errorf("pull request lookup failed: %w", err))
+	}
+	outcome.pr = fmt.Sprintf("#%d", pr.Number)
+
+	if blockers := conditions.Blockers(pr); len(blockers) > 0 {
+		outcome.result, outcome.reason = "skipped", strings.Join(blockers, "; ")
+		return outcome
+	}
+	if mergeLimit > 0 && merged >= mergeLimit {
+		outcome.result, outcome.reason = "skipped", fmt.Sprintf("limit of %d merges reached", mergeLimit)
+		return outcome
+	}
+	if mergeDryRun {
+		outcome.result = "would merge"
+		return outcome
+	}
+
+	if merged > 0 && mergeDelay > 0 {
+		sleep(mergeDelay)
+	}
+	if err := f.Merge(ctx, pr, method); err != nil {
+		return failed(fmt.Errorf("merge failed: %w", err))
+	}
+	outcome.result = "merged"
+	return outcome
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunMerge(t *testing.T) {
+	// repo3 has a pull request that is approved, but whose checks failed
+	blocked := map[string]string{
+		"/repos/org/repo3/pulls?direction=desc&head=org%3Adeps%2Frepo3&sort=created&state=all": `[{"number": 3}]`,
+		"/repos/org/repo3/pulls/3":                              `{"number": 3, "state": "open", "mergeable": true, "mergeable_state": "blocked", "head": {"sha": "sha3"}}`,
+		"/repos/org/repo3/pulls/3/reviews?per_page=100":         `[{"user": {"login": "alice"}, "state": "APPROVED"}]`,
+		"/repos/org/repo3/commits/sha3/check-runs?per_page=100": `{"check_runs": [{"name": "lint", "status": "completed", "conclusion": "failure"}]}`,
+		"/repos/org/repo3/commits/sha3/status":                  `{"statuses": []}`,
+		"PUT /repos/org/repo1/pulls/1/merge":                    `{"merged": true}`,
+	}
+
+	tests := []struct {
+		name    string
+		setup   func()
+		results map[string]string
+		sleeps  int
+	}{
+		{
+			name: "merge ready pull requests",
+			results: map[string]string{
+				"repo1": "repo1 #1 merged",
+				"repo2": "repo2 - skipped no pull request",
+				"repo3": "repo3 #3 skipped checks failed (lint)",
+			},
+		},
+		{
+			name:  "dry run",
+			setup: func() { mergeDryRun = true },
+			results: map[string]string{
+				"repo1": "repo1 #1 would merge",
+			},
+		},
+		{
+			name:  "more approvals required",
+			setup: func() { mergeMinApprovals = 2 },
+			results: map[string]string{
+				"repo1": "repo1 #1 skipped 1 of 2 required approvals",
+				"repo3": "repo3 #3 skipped checks failed (lint); 1 of 2 required approvals",
+			},
+		},
+		{
+			name:  "checks not required",
+			setup: func() { mergeRequireChecks = false; mergeDryRun = true },
+			results: map[string]string{
+				"repo3": "repo3 #3 would merge",
+			},
+		},
+		{
+			name:  "limit",
+			setup: func() { mergeRequireChecks = false; mergeLimit = 1 },
+			results: map[string]string{
+				"repo1": "repo1 #1 merged",
+				"repo3": "repo3 #3 skipped limit of 1 merges reached",
+			},
+		},
+		{
+			name:  "merge failure",
+			setup: func() { mergeRequireChecks = false },
+			results: map[string]string{
+				"repo1": "repo1 #1 merged",
+				"repo3": "repo3 #3 failed merge failed: error merging pull request",
+			},
+			sleeps: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetMocks()
+			ResetMergeFlags()
+			ResetPRsFlags()
+			defer ResetMergeFlags()
+			defer ResetPRsFlags()
+			server := newFakeGitHub(t, blocked)
+			mergeBranch = "deps/{{.RepoName}}"
+			forgeName = "github"
+			forgeAPIURL = server.URL
+			if tt.setup != nil {
+				tt.setup()
+			}
+			var slept []time.Duration
+			sleep = func(d time.Duration) { slept = append(slept, d) }
+
+			out := captureRunOutput(t, func() error { return runMerge(nil, []string{"repo1", "repo2", "repo3"}) })
+			rows := map[string]string{}
+			for _, line := range strings.Split(strings.TrimSpace(out), "\n")[1:] {
+				row := strings.Join(strings.Fields(line), " ")
+				rows[strings.Fields(row)[0]] = row
+			}
+			for repo, want := range tt.results {
+				if !strings.HasPrefix(rows[repo], want) {
+					t.Errorf("expected row %q, got %q", want, rows[repo])
+				}
+			}
+			if len(slept) != tt.sleeps {
+				t.Errorf("expected %d delays, got %v", tt.sleeps, slept)
+			}
+			for _, d := range slept {
+				if d != time.Second {
+					t.Errorf("expected a delay of 1s, got %v", d)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateMerge(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func()
+		wantErr string
+	}{
+		{name: "valid", setup: func() {}},
+		{name: "missing branch", setup: func() { mergeBranch = "" }, wantErr: "--branch is required"},
+		{name: "unknown method", setup: func() { mergeMethod = "octopus" }, wantErr: "invalid --method"},
+		{name: "negative approvals", setup: func() { mergeMinApprovals = -1 }, wantErr: "--min-approvals cannot be negative"},
+		{name: "negative delay", setup: func() { mergeDelay = -time.Second }, wantErr: "--delay cannot be negative"},
+		{name: "negative limit", setup: func() { mergeLimit = -1 }, wantErr: "--limit cannot be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ResetMergeFlags()
+			ResetPRsFlags()
+			defer ResetMergeFlags()
+			defer ResetPRsFlags()
+			mergeBranch = "deps/{{.RepoName}}"
+			tt.setup()
+			err := validateMerge(nil, nil)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
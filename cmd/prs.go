@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vpukhanov/cascade/internal/forge"
+	"github.com/vpukhanov/cascade/internal/tmpl"
+	"github.com/vpukhanov/cascade/internal/validation"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	prsBranch   string
+	prsJSON     bool
+	forgeName   string
+	forgeAPIURL string
+)
+
+var prsCmd = &cobra.Command{
+	Use:   "prs [repositories...]",
+	Short: "Show the pull requests of a campaign branch across repositories",
+	Long: `Show the pull request of a campaign branch in every repository, with its state, review state, CI checks and merge conflicts.
+The code host is detected from the origin remote. GitHub requests use the GITHUB_TOKEN or GH_TOKEN environment variable, GitLab requests use GITLAB_TOKEN.`,
+	Example: `cascade prs --branch update-logging ./repo1 ./repo2
+cascade prs --branch update-logging --json ./repos/* > prs.json`,
+	Args:    cobra.MinimumNArgs(1),
+	RunE:    runPRs,
+	PreRunE: validatePRs,
+}
+
+func init() {
+	rootCmd.AddCommand(prsCmd)
+
+	prsCmd.Flags().StringVar(&prsBranch, "branch", "", "Name of the campaign branch, can be a template using the repository name")
+	prsCmd.Flags().BoolVar(&prsJSON, "json", false, "Print the pull requests as JSON")
+	addForgeFlags(prsCmd)
+	addRemoteFlags(prsCmd)
+}
+
+// ResetPRsFlags resets the global flag variables of prs, with the code host
+// selection shared by merge and cleanup, to their defaults.
+func ResetPRsFlags() {
+	prsBranch = ""
+	prsJSON = false
+	forgeName = ""
+	forgeAPIURL = ""
+}
+
+// addForgeFlags adds the flags selecting the code host API.
+func addForgeFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&forgeName, "forge", "", "Code host API, 'github' or 'gitlab' (default: detected from the origin remote host)")
+	cmd.Flags().StringVar(&forgeAPIURL, "api-url", "", "Code host API endpoint, like https://gitlab.example.com/api/v4 (default: derived from the origin remote host)")
+}
+
+func validatePRs(cmd *cobra.Command, args []string) error {
+	if err := validateCampaignBranch(prsBranch, args); err != nil {
+		return err
+	}
+	if err := validateRemoteOptions(); err != nil {
+		return err
+	}
+	return parseTemplateVars()
+}
+
+// validateCampaignBranch checks the campaign branch flag, the forge flags and
+// the repositories of commands working with pull requests.
+func validateCampaignBranch(branchName string, repos []string) error {
+	if branchName == "" {
+		return fmt.Errorf("--branch is required")
+	}
+	if strings.Contains(branchName, "{{") {
+		if err := tmpl.Validate("branch", branchName); err != nil {
+			return err
+		}
+	} else if err := validation.ValidateBranchName(branchName); err != nil {
+		return fmt.Errorf("invalid branch name: %w", err)
+	}
+	if forgeName != "" {
+		if _, err := forge.ParseKind(forgeName); err != nil {
+			return fmt.Errorf("invalid --forge: %w", err)
+		}
+	}
+	for _, repo := range repos {
+		if err := validation.ValidateGitRepo(repo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prResult is the pull request of a repository, or the error finding it.
+type prResult struct {
+	Repo        string             `json:"repo"`
+	Branch      string             `json:"branch"`
+	PullRequest *forge.PullRequest `json:"pull_request"`
+	Error       string             `json:"error,omitempty"`
+}
+
+func runPRs(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	results := make([]prResult, 0, len(args))
+	for _, repoPath := range args {
+		result := prResult{Repo: repoPath}
+		var err error
+		result.Branch, result.PullRequest, err = findPullRequest(ctx, repoPath, prsBranch)
+		if err != nil && !errors.Is(err, forge.ErrNotFound) {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	if prsJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tPR\tSTATE\tREVIEW\tCHECKS\tMERGEABLE\tURL")
+	for _, result := range results {
+		pr := result.PullRequest
+		switch {
+		case result.Error != "":
+			fmt.Fprintf(w, "%s\terror: %s\n", result.Repo, firstLine(result.Error))
+		case pr == nil:
+			fmt.Fprintf(w, "%s\t-\tno pull request\n", result.Repo)
+		default:
+			state := pr.State
+			if pr.Draft && pr.State == forge.StateOpen {
+				state = "draft"
+			}
+			checks := pr.Checks
+			if len(pr.FailedChecks) > 0 {
+				checks += " (" + strings.Join(pr.FailedChecks, ", ") + ")"
+			}
+			fmt.Fprintf(w, "%s\t#%d\t%s\t%s\t%s\t%s\t%s\n", result.Repo, pr.Number, state, pr.Review, checks, pr.Mergeable, pr.URL)
+		}
+	}
+	return w.Flush()
+}
+
+// findPullRequest renders the campaign branch for the repository and finds
+// its pull request on the code host of the origin remote, opened from the
+// push remote.
+func findPullRequest(ctx context.Context, repoPath string, branchName string) (string, *forge.PullRequest, error) {
+	repoBranch, err := renderCampaignBranch(repoPath, branchName)
+	if err != nil {
+		return "", nil, err
+	}
+	f, err := repoForge(repoPath, repoBranch)
+	if err != nil {
+		return repoBranch, nil, err
+	}
+	pr, err := f.FindPullRequest(ctx, repoBranch)
+	if err != nil {
+		return repoBranch, nil, fmt.Errorf("pull request lookup failed: %w", err)
+	}
+	return repoBranch, pr, nil
+}
+
+// renderCampaignBranch renders a campaign branch template for the
+// repository. Only the repository name and path are known outside of apply.
+func renderCampaignBranch(repoPath string, branchName string) (string, error) {
+	absPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	data := tmpl.Data{RepoPath: repoPath, RepoName: filepath.Base(absPath), Vars: templateVars}
+	return tmpl.Render("branch", branchName, data)
+}
+
+// firstLine returns the first line of a message, which keeps table rows on
+// a single line.
+func firstLine(message string) string {
+	first, _, _ := strings.Cut(message, "\n")
+	return first
+}
+
+// repoForge returns the API client of the code host of the origin remote,
+// finding pull requests opened from the remote the campaign branch is pushed
+// to.
+func repoForge(repoPath string, repoBranch string) (forge.Forge, error) {
+	remoteURL, err := gitRemoteURL(repoPath, "origin")
+	if err != nil {
+		return nil, fmt.Errorf("remote lookup failed: %w", err)
+	}
+	remote, err := forge.ParseRemote(remoteURL)
+	if err != nil {
+		return nil, err
+	}
+
+	kind := forge.Kind(forgeName)
+	if kind == "" {
+		if kind, err = forge.Detect(remote.Host); err != nil {
+			return nil, err
+		}
+	}
+	owner, err := headOwner(repoPath, repoBranch)
+	if err != nil {
+		return nil, err
+	}
+	return forge.New(kind, remote, forge.Options{
+		APIURL:    forgeAPIURL,
+		Token:     forge.TokenFromEnv(kind),
+		HeadOwner: owner,
+	})
+}
+
+// headOwner returns the owner of the repository the campaign branch is
+// pushed to when that is not origin, like a fork, and is empty for origin.
+func headOwner(repoPath string, repoBranch string) (string, error) {
+	remote, err := pushRemoteName(repoPath, repoBranch)
+	if err != nil {
+		return "", fmt.Errorf("push remote lookup failed: %w", err)
+	}
+	if remote == "origin" {
+		return "", nil
+	}
+	remoteURL, err := gitRemoteURL(repoPath, remote)
+	if err != nil {
+		return "", fmt.Errorf("push remote lookup failed: %w", err)
+	}
+	head, err := forge.ParseRemote(remoteURL)
+	if err != nil {
+		return "", err
+	}
+	return head.Owner(), nil
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// newFakeGitHub serves the GitHub API responses needed to find the pull
+// requests of repo1, which has an approved and green pull request, while
+// repo2 has none.
+func newFakeGitHub(t *testing.T, extra map[string]string) *httptest.Server {
+	t.Helper()
+	responses := map[string]string{
+		"/repos/org/repo1/pulls?direction=desc&head=org%3Adeps%2Frepo1&sort=created&state=all": `[{"number": 1}]`,
+		"/repos/org/repo1/pulls/1": `{"number": 1, "html_url": "https://github.com/org/repo1/pull/1", "state": "open",
+			"mergeable": true, "mergeable_state": "clean", "head": {"sha": "sha1"}}`,
+		"/repos/org/repo1/pulls/1/reviews?per_page=100":                                        `[{"user": {"login": "alice"}, "state": "APPROVED"}]`,
+		"/repos/org/repo1/commits/sha1/check-runs?per_page=100":                                `{"check_runs": [{"name": "test", "status": "completed", "conclusion": "success"}]}`,
+		"/repos/org/repo1/commits/sha1/status":                                                 `{"statuses": []}`,
+		"/repos/org/repo2/pulls?direction=desc&head=org%3Adeps%2Frepo2&sort=created&state=all": `[]`,
+	}
+	for key, value := range extra {
+		responses[key] = value
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.RequestURI()]
+		if !ok {
+			response, ok = responses[r.Method+" "+r.URL.RequestURI()]
+		}
+		if !ok {
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func captureRunOutput(t *testing.T, run func() error) string {
+	t.Helper()
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	err := run()
+	w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stdout = oldStdout
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return string(out)
+}
+
+func TestRunPRs(t *testing.T) {
+	resetMocks()
+	defer ResetPRsFlags()
+	server := newFakeGitHub(t, nil)
+	prsBranch = "deps/{{.RepoName}}"
+	forgeName = "github"
+	forgeAPIURL = server.URL
+
+	out := captureRunOutput(t, func() error { return runPRs(nil, []string{"repo1", "repo2", "repo3"}) })
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected a header and 3 rows, got:\n%s", out)
+	}
+	if got := strings.Join(strings.Fields(lines[1]), " "); got != "repo1 #1 open approved success clean https://github.com/org/repo1/pull/1" {
+		t.Errorf("unexpected row %q", got)
+	}
+	if !strings.Contains(lines[2], "no pull request") {
+		t.Errorf("unexpected row %q", lines[2])
+	}
+	if !strings.Contains(lines[3], "error: pull request lookup failed") || !strings.Contains(lines[3], "404") {
+		t.Errorf("unexpected row %q", lines[3])
+	}
+
+	prsJSON = true
+	out = captureRunOutput(t, func() error { return runPRs(nil, []string{"repo1", "repo2"}) })
+	var results []prResult
+	if err := json.Unmarshal([]byte(out), &results); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, out)
+	}
+	if len(results) != 2 || results[0].PullRequest == nil || results[0].PullRequest.Approvals != 1 || results[0].Branch != "deps/repo1" {
+		t.Errorf("unexpected results %+v", results)
+	}
+	if results[1].PullRequest != nil || results[1].Error != "" {
+		t.Errorf("expected repo2 without a pull request or error, got %+v", results[1])
+	}
+}
+
+func TestRunPRsFork(t *testing.T) {
+	resetMocks()
+	defer ResetPRsFlags()
+	defer ResetRemoteFlags()
+	// The pull request is opened on origin from the fork of "me"
+	server := newFakeGitHub(t, map[string]string{
+		"/repos/org/repo1/pulls?direction=desc&head=me%3Adeps%2Frepo1&sort=created&state=all": `[{"number": 1}]`,
+	})
+	gitRemoteURL = func(repoPath, remote string) (string, error) {
+		if remote == "fork" {
+			return "git@example.com:me/" + repoPath + ".git", nil
+		}
+		return "git@example.com:org/" + repoPath + ".git", nil
+	}
+	prsBranch = "deps/{{.RepoName}}"
+	forgeName = "github"
+	forgeAPIURL = server.URL
+	forkURL = "git@example.com:me/{{.RepoName}}.git"
+
+	out := captureRunOutput(t, func() error { return runPRs(nil, []string{"repo1"}) })
+	if !strings.Contains(out, "https://github.com/org/repo1/pull/1") {
+		t.Errorf("expected the pull request from the fork, got:\n%s", out)
+	}
+}
+
+func TestRepoForgeDetection(t *testing.T) {
+	resetMocks()
+	defer ResetPRsFlags()
+
+	if _, err := repoForge("repo1", "deps/repo1"); err == nil || !strings.Contains(err.Error(), "--forge") {
+		t.Errorf("expected detection error for an unknown host, got %v", err)
+	}
+
+	gitRemoteURL = func(string, string) (string, error) { return "https://gitlab.example.com/group/repo.git", nil }
+	if _, err := repoForge("repo1", "deps/repo1"); err != nil {
+		t.Errorf("repoForge() error: %v", err)
+	}
+
+	gitRemoteURL = func(string, string) (string, error) { return "/srv/git/repo.git", nil }
+	if _, err := repoForge("repo1", "deps/repo1"); err == nil {
+		t.Error("expected error for a local remote")
+	}
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vpukhanov/cascade/internal/forge"
+	"github.com/vpukhanov/cascade/internal/git"
+	"github.com/vpukhanov/cascade/internal/tmpl"
+	"github.com/vpukhanov/cascade/internal/validation"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	pushRemote string
+	forkURL    string
+	forkRemote string
+
+	gitPushRemote   = git.PushRemote
+	gitEnsureRemote = git.EnsureRemote
+	openURL         = git.OpenURL
+)
+
+// addRemoteFlags adds the flags selecting the remote campaign branches are
+// pushed to, and the variables the branch and fork URL templates use.
+func addRemoteFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&varSpecs, "var", nil, "Template variable in the 'key=value' form, available as {{.Vars.key}} (repeatable)")
+	cmd.Flags().StringVar(&pushRemote, "remote", "", "Remote the branch is pushed to (default: the branch.<name>.pushRemote or remote.pushDefault git setting, or origin)")
+	cmd.Flags().StringVar(&forkURL, "fork-url", "", "URL of a fork the branch is pushed to, added as a remote, can be a template like 'git@github.com:me/{{.RepoName}}.git'")
+	cmd.Flags().StringVar(&forkRemote, "fork-remote", "fork", "Name of the remote added for --fork-url")
+}
+
+// ResetRemoteFlags resets the remote flags shared by the commands to their
+// defaults.
+func ResetRemoteFlags() {
+	pushRemote = ""
+	forkURL = ""
+	forkRemote = "fork"
+}
+
+func validateRemoteOptions() error {
+	if pushRemote != "" && forkURL != "" {
+		return fmt.Errorf("--remote and --fork-url cannot be used together, the fork is pushed to --fork-remote")
+	}
+	if forkURL != "" {
+		if err := tmpl.Validate("fork URL", forkURL); err != nil {
+			return err
+		}
+		if err := validation.ValidateRemoteName(forkRemote); err != nil {
+			return fmt.Errorf("invalid --fork-remote: %w", err)
+		}
+	}
+	if pushRemote != "" {
+		if err := validation.ValidateRemoteName(pushRemote); err != nil {
+			return fmt.Errorf("invalid --remote: %w", err)
+		}
+	}
+	if err := forge.ValidateURLFormat(prURLTemplate); err != nil {
+		return fmt.Errorf("invalid --pr-url-template: %w", err)
+	}
+	return nil
+}
+
+// parseTemplateVars parses the --var flags of the commands that only render
+// the branch and fork URL templates.
+func parseTemplateVars() error {
+	vars, err := tmpl.ParseVars(varSpecs)
+	if err != nil {
+		return fmt.Errorf("invalid --var: %w", err)
+	}
+	templateVars = vars
+	return nil
+}
+
+// resolvePushRemote adds the fork as a remote, when there is one, and
+// returns the remote the campaign branch is pushed to.
+func resolvePushRemote(repoPath string, repoBranch string, data tmpl.Data) (string, error) {
+	if forkURL != "" {
+		url, err := tmpl.Render("fork URL", forkURL, data)
+		if err != nil {
+			return "", err
+		}
+		if err := gitEnsureRemote(repoPath, forkRemote, url); err != nil {
+			return "", err
+		}
+	}
+	return pushRemoteName(repoPath, repoBranch)
+}
+
+// pushRemoteName returns the remote the campaign branch is pushed to: the
+// fork, --remote or the push remote git is configured with. It changes
+// nothing, so a fork that was never added is an error.
+func pushRemoteName(repoPath string, repoBranch string) (string, error) {
+	switch {
+	case forkURL != "":
+		if _, err := gitRemoteURL(repoPath, forkRemote); err != nil {
+			return "", fmt.Errorf("fork remote %s is not set up, apply adds it: %w", forkRemote, err)
+		}
+		return forkRemote, nil
+	case pushRemote != "":
+		return pushRemote, nil
+	default:
+		return gitPushRemote(repoPath, repoBranch)
+	}
+}
+
+// campaignRemote returns the remote the campaign branch of the repository
+// is pushed to, for the commands reading branches that apply pushed.
+func campaignRemote(repoPath string, repoBranch string) (string, error) {
+	return pushRemoteName(repoPath, repoBranch)
+}
+
+// ensureCampaignRemote adds the fork as a remote like apply does, rendering
+// the fork URL with the same data, and returns the remote the campaign
+// branch is pushed to, for the commands pushing branches that apply pushed.
+func ensureCampaignRemote(repoPath string, repoBranch string, base string) (string, error) {
+	data, err := repoTemplateData(repoPath, base, time.Now().Format("2006-01-02"), "")
+	if err != nil {
+		return "", err
+	}
+	data.Branch = repoBranch
+	return resolvePushRemote(repoPath, repoBranch, data)
+}
+
+// fetchRemotes fetches origin, which has the base branch, and the remote the
+// campaign branch is pushed to when that is another one.
+func fetchRemotes(repoPath string, remote string) error {
+	if err := gitFetch(repoPath, "origin"); err != nil {
+		return err
+	}
+	if remote == "origin" {
+		return nil
+	}
+	return gitFetch(repoPath, remote)
+}
+
+// pullRequestURL returns the last URL the push printed or, when it printed
+// none, the URL that opens a pull request built from the URLs of the push
+// remote and origin. It is empty when the code host is not known.
+func pullRequestURL(repoPath string, remote string, data tmpl.Data, pushOutput string) string {
+	if url := git.LastRemoteURL(pushOutput); url != "" || reviewSystem == reviewGerrit {
+		return url
+	}
+
+	headURL, err := gitRemoteURL(repoPath, remote)
+	if err != nil {
+		return ""
+	}
+	head, err := forge.ParseRemote(headURL)
+	if err != nil {
+		return ""
+	}
+	// Pull requests from a fork are opened on origin
+	repo := head
+	if origin, err := forge.ParseRemote(data.Remote); err == nil {
+		repo = origin
+	}
+	url, err := forge.PullRequestURL(prURLTemplate, repo, head, data.Branch, data.BaseBranch)
+	if err != nil {
+		return ""
+	}
+	return url
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vpukhanov/cascade/internal/tmpl"
+)
+
+func TestRunApplyPushRemote(t *testing.T) {
+	tests := []struct {
+		name       string
+		pushRemote string
+		forkURL    string
+		configured string
+		noOrigin   bool
+		want       []string
+	}{
+		{name: "origin by default", want: []string{"fetch origin", "refs/remotes/origin/deps/upgrade", "push origin"}},
+		{name: "no origin", noOrigin: true, want: []string{"refs/remotes/origin/deps/upgrade", "push origin"}},
+		{name: "configured push remote", configured: "mine", want: []string{"fetch mine", "refs/remotes/mine/deps/upgrade", "push mine"}},
+		{name: "explicit remote", pushRemote: "upstream", configured: "mine", want: []string{"fetch upstream", "refs/remotes/upstream/deps/upgrade", "push upstream"}},
+		{
+			name:    "fork",
+			forkURL: "git@example.com:me/{{.RepoName}}.git",
+			want:    []string{"add fork git@example.com:me/repo1.git", "fetch fork", "refs/remotes/fork/deps/upgrade", "push fork"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetMocks()
+			defer ResetFlags()
+			defer ResetRemoteFlags()
+			var calls []string
+			gitPushRemote = func(repoPath, branch string) (string, error) {
+				if tt.configured != "" {
+					return tt.configured, nil
+				}
+				return "origin", nil
+			}
+			if tt.noOrigin {
+				gitRemoteURL = func(repoPath, remote string) (string, error) {
+					return "", errors.New("no such remote")
+				}
+			}
+			gitEnsureRemote = func(repoPath, name, url string) error {
+				calls = append(calls, "add "+name+" "+url)
+				return nil
+			}
+			gitFetch = func(repoPath, remote string) error {
+				calls = append(calls, "fetch "+remote)
+				return nil
+			}
+			gitRefExists = func(repoPath, ref string) (bool, error) {
+				if strings.HasPrefix(ref, "refs/remotes/") {
+					calls = append(calls, ref)
+				}
+				return false, nil
+			}
+			gitPushChanges = func(repoPath, remote, branch string, noVerify bool) (string, error) {
+				calls = append(calls, "push "+remote)
+				return "", nil
+			}
+			command = "make upgrade"
+			branch = "deps/upgrade"
+			message = "Upgrade"
+			push = true
+			pushRemote = tt.pushRemote
+			forkURL = tt.forkURL
+
+			captureRunOutput(t, func() error { return runApply(nil, []string{"repo1"}) })
+			if !slices.Equal(calls, tt.want) {
+				t.Errorf("expected calls %v, got %v", tt.want, calls)
+			}
+		})
+	}
+}
+
+func TestPullRequestURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		pushOutput string
+		forkURL    string
+		template   string
+		origin     string
+		want       string
+	}{
+		{
+			name:       "printed by the push",
+			pushOutput: "remote: Create a pull request:\nremote:   https://github.com/org/repo1/pull/new/deps/bump\n",
+			origin:     "git@github.com:org/repo1.git",
+			want:       "https://github.com/org/repo1/pull/new/deps/bump",
+		},
+		{
+			name:   "built from the remote URL",
+			origin: "git@github.com:org/repo1.git",
+			want:   "https://github.com/org/repo1/compare/main...deps/bump?expand=1",
+		},
+		{
+			name:    "built for a fork",
+			forkURL: "git@github.com:me/repo1.git",
+			origin:  "https://github.com/org/repo1.git",
+			want:    "https://github.com/org/repo1/compare/main...me:deps/bump?expand=1",
+		},
+		{
+			name:     "self-hosted template",
+			template: "https://{{.Host}}/{{.Path}}/pulls/new?branch={{urlquery .Branch}}",
+			origin:   "ssh://git@git.example.com:2222/org/repo1.git",
+			want:     "https://git.example.com/org/repo1/pulls/new?branch=deps%2Fbump",
+		},
+		{
+			name:   "unknown host",
+			origin: "ssh://git@git.example.com/org/repo1.git",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetMocks()
+			defer ResetFlags()
+			remote := "origin"
+			remoteURLs := map[string]string{"origin": tt.origin}
+			if tt.forkURL != "" {
+				remote = "fork"
+				remoteURLs["fork"] = tt.forkURL
+			}
+			gitRemoteURL = func(repoPath, remote string) (string, error) {
+				return remoteURLs[remote], nil
+			}
+			prURLTemplate = tt.template
+
+			data := tmpl.Data{RepoName: "repo1", Remote: tt.origin, BaseBranch: "main", Branch: "deps/bump"}
+			if got := pullRequestURL("repo1", remote, data, tt.pushOutput); got != tt.want {
+				t.Errorf("pullRequestURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRemoteOptions(t *testing.T) {
+	defer ResetFlags()
+	defer ResetRemoteFlags()
+
+	tests := []struct {
+		name          string
+		pushRemote    string
+		forkURL       string
+		forkRemote    string
+		prURLTemplate string
+		wantErr       string
+	}{
+		{name: "no options", forkRemote: "fork"},
+		{name: "remote", pushRemote: "upstream", forkRemote: "fork"},
+		{name: "fork", forkURL: "git@example.com:me/{{.RepoName}}.git", forkRemote: "fork"},
+		{name: "both", pushRemote: "upstream", forkURL: "git@example.com:me/repo.git", forkRemote: "fork", wantErr: "cannot be used together"},
+		{name: "invalid template", forkURL: "git@example.com:me/{{.RepoName}.git", forkRemote: "fork", wantErr: "fork URL"},
+		{name: "invalid fork remote", forkURL: "git@example.com:me/repo.git", forkRemote: "-fork", wantErr: "invalid --fork-remote"},
+		{name: "invalid remote", pushRemote: "my remote", forkRemote: "fork", wantErr: "invalid --remote"},
+		{name: "invalid URL template", prURLTemplate: "https://{{.Host}/", forkRemote: "fork", wantErr: "invalid --pr-url-template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pushRemote, forkURL, forkRemote, prURLTemplate = tt.pushRemote, tt.forkURL, tt.forkRemote, tt.prURLTemplate
+			err := validateRemoteOptions()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vpukhanov/cascade/internal/git"
+
+	"github.com/spf13/cobra"
+)
+
+// maxRetryDelay caps the backoff between retries.
+const maxRetryDelay = 30 * time.Second
+
+var (
+	retries    int
+	retryDelay time.Duration
+)
+
+// addRetryFlags adds the flags retrying fetches, pulls and pushes.
+func addRetryFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&retries, "retries", 2, "Times a fetch, pull or push is retried after a transient error, like a connection reset or a server error of the host, 0 to not retry")
+	cmd.Flags().DurationVar(&retryDelay, "retry-delay", time.Second, "Delay before the first retry, doubled for every further retry up to 30s, with jitter")
+}
+
+// ResetRetryFlags resets the retry flags shared by the commands to their
+// defaults.
+func ResetRetryFlags() {
+	retries = 2
+	retryDelay = time.Second
+}
+
+func validateRetryFlags() error {
+	if retries < 0 {
+		return fmt.Errorf("--retries must not be negative")
+	}
+	if retryDelay < 0 {
+		return fmt.Errorf("--retry-delay must not be negative")
+	}
+	return nil
+}
+
+// setRetryPolicy makes the git commands of the run retry as the flags say.
+func setRetryPolicy() {
+	git.SetRetryPolicy(git.RetryPolicy{Retries: retries, Delay: retryDelay, MaxDelay: maxRetryDelay})
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateRetryFlags(t *testing.T) {
+	defer ResetSyncFlags()
+	defer ResetRetryFlags()
+
+	tests := []struct {
+		name    string
+		retries int
+		delay   time.Duration
+		wantErr string
+	}{
+		{name: "defaults", retries: 2, delay: time.Second},
+		{name: "disabled", retries: 0, delay: 0},
+		{name: "negative retries", retries: -1, delay: time.Second, wantErr: "--retries must not be negative"},
+		{name: "negative delay", retries: 2, delay: -time.Second, wantErr: "--retry-delay must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ResetSyncFlags()
+			syncBranch = "deps/upgrade"
+			retries, retryDelay = tt.retries, tt.delay
+			err := validateSync(nil, nil)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vpukhanov/cascade/internal/git"
+	"github.com/vpukhanov/cascade/internal/tmpl"
+)
+
+// reviewGerrit is the --review-system that pushes changes to Gerrit.
+const reviewGerrit = "gerrit"
+
+var gitPushForReview = git.PushForReview
+
+func validateReviewOptions() error {
+	switch reviewSystem {
+	case "":
+		if reviewTopic != "" || len(reviewers) > 0 || len(hashtags) > 0 {
+			return fmt.Errorf("--topic, --reviewer and --hashtag require --review-system gerrit")
+		}
+		return nil
+	case reviewGerrit:
+	default:
+		return fmt.Errorf("invalid --review-system %q, must be gerrit", reviewSystem)
+	}
+	if forkURL != "" {
+		return fmt.Errorf("--fork-url cannot be used with --review-system gerrit, changes are pushed for review to the repository itself")
+	}
+	// A follow-up commit would repeat the Change-Id of the commit it builds
+	// on, and Gerrit rejects pushes with two commits of the same change
+	if existingPolicy() == onExistingUpdate && (updateMode == "" || updateMode == updateCommit) {
+		return fmt.Errorf("--update commit cannot be used with --review-system gerrit, use --update amend or regenerate to upload new patch sets of the changes")
+	}
+	return tmpl.Validate("topic", reviewTopic)
+}
+
+// reviewMessage adds a Change-Id trailer to the commit message when pushing
+// to Gerrit. The Change-Id is derived from the repository, the base and
+// campaign branches and the commit within the campaign, so that running the
+// campaign again uploads new patch sets of the same changes.
+func reviewMessage(message string, data tmpl.Data, commit string) string {
+	if reviewSystem != reviewGerrit {
+		return message
+	}
+	key := strings.Join([]string{data.Remote, data.RepoName, data.BaseBranch, data.Branch, commit}, "\n")
+	return git.WithChangeID(message, git.ChangeID(key))
+}
+
+// pushForReview pushes the campaign branch to refs/for/<base branch>, with
+// the topic, reviewers and hashtags as push options.
+func pushForReview(repoPath string, remote string, data tmpl.Data) (string, error) {
+	topic := data.Branch
+	if reviewTopic != "" {
+		var err error
+		if topic, err = tmpl.Render("topic", reviewTopic, data); err != nil {
+			return "", err
+		}
+	}
+	opts := git.ReviewOptions{Topic: topic, Reviewers: reviewers, Hashtags: hashtags}
+	return gitPushForReview(repoPath, remote, data.BaseBranch, opts, noVerify)
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vpukhanov/cascade/internal/git"
+)
+
+func TestRunApplyGerrit(t *testing.T) {
+	resetMocks()
+	defer ResetFlags()
+
+	var messages []string
+	var pushes []string
+	gitCommitChanges = func(repoPath, message string, noVerify bool) error {
+		messages = append(messages, message)
+		return nil
+	}
+	gitHasChanges = func(repoPath string) (bool, error) { return true, nil }
+	gitPushChanges = func(repoPath, remote, branch string, noVerify bool) (string, error) {
+		t.Errorf("unexpected branch push in %s", repoPath)
+		return "", nil
+	}
+	gitPushForReview = func(repoPath, remote, base string, opts git.ReviewOptions, noVerify bool) (string, error) {
+		pushes = append(pushes, strings.Join([]string{repoPath, remote, base, opts.Topic, strings.Join(opts.Reviewers, ","), strings.Join(opts.Hashtags, ",")}, " "))
+		return "remote:   https://review.example.com/c/" + repoPath + "/+/1 Upgrade [NEW]\n", nil
+	}
+
+	apply := func() string {
+		t.Helper()
+		ResetFlags()
+		command = "make upgrade"
+		branch = "deps/upgrade"
+		message = "Upgrade"
+		push = true
+		reviewSystem = "gerrit"
+		reviewers = []string{"dev@example.com"}
+		hashtags = []string{"deps"}
+		return captureRunOutput(t, func() error { return runApply(nil, []string{"repo1", "repo2"}) })
+	}
+
+	out := apply()
+	wantPushes := []string{
+		"repo1 origin main deps/upgrade dev@example.com deps",
+		"repo2 origin main deps/upgrade dev@example.com deps",
+	}
+	if !slices.Equal(pushes, wantPushes) {
+		t.Errorf("expected pushes %v, got %v", wantPushes, pushes)
+	}
+	if !strings.Contains(out, "ok   repo1") {
+		t.Errorf("expected repo1 to succeed, got:\n%s", out)
+	}
+
+	changeID := regexp.MustCompile(`\n\nChange-Id: (I[0-9a-f]{40})$`)
+	var ids []string
+	for _, m := range messages {
+		match := changeID.FindStringSubmatch(m)
+		if match == nil {
+			t.Fatalf("expected a Change-Id trailer, got %q", m)
+		}
+		ids = append(ids, match[1])
+	}
+	if ids[0] == ids[1] {
+		t.Errorf("expected different Change-Ids per repository, got %v", ids)
+	}
+
+	// Running the campaign again updates the same changes
+	messages = nil
+	apply()
+	for i, m := range messages {
+		if !strings.HasSuffix(m, "Change-Id: "+ids[i]) {
+			t.Errorf("expected Change-Id %s on the rerun, got %q", ids[i], m)
+		}
+	}
+}
+
+func TestRunApplyGerritTopic(t *testing.T) {
+	resetMocks()
+	defer ResetFlags()
+
+	var topic string
+	gitPushForReview = func(repoPath, remote, base string, opts git.ReviewOptions, noVerify bool) (string, error) {
+		topic = opts.Topic
+		return "", nil
+	}
+	command = "make upgrade"
+	branch = "deps/upgrade"
+	message = "Upgrade"
+	push = true
+	reviewSystem = "gerrit"
+	reviewTopic = "upgrade-{{.RepoName}}"
+	captureRunOutput(t, func() error { return runApply(nil, []string{"repo1"}) })
+	if topic != "upgrade-repo1" {
+		t.Errorf("expected the rendered topic, got %q", topic)
+	}
+}
+
+func TestValidateReviewOptions(t *testing.T) {
+	defer ResetFlags()
+	defer ResetRemoteFlags()
+
+	tests := []struct {
+		name    string
+		setup   func()
+		wantErr string
+	}{
+		{name: "no review system", setup: func() {}},
+		{name: "gerrit", setup: func() { reviewSystem = "gerrit"; reviewTopic = "{{.RepoName}}" }},
+		{name: "unknown system", setup: func() { reviewSystem = "phabricator" }, wantErr: "invalid --review-system"},
+		{name: "options without gerrit", setup: func() { reviewers = []string{"dev@example.com"} }, wantErr: "require --review-system gerrit"},
+		{name: "invalid topic", setup: func() { reviewSystem = "gerrit"; reviewTopic = "{{.RepoName" }, wantErr: "topic"},
+		{name: "fork", setup: func() { reviewSystem = "gerrit"; forkURL = "git@example.com:me/repo.git" }, wantErr: "--fork-url cannot be used"},
+		{name: "follow-up commit", setup: func() { reviewSystem = "gerrit"; updateMode = "commit" }, wantErr: "--update commit cannot be used"},
+		{name: "update defaults to commit", setup: func() { reviewSystem = "gerrit"; onExisting = "update" }, wantErr: "--update commit cannot be used"},
+		{name: "amend", setup: func() { reviewSystem = "gerrit"; updateMode = "amend" }},
+		{name: "regenerate", setup: func() { reviewSystem = "gerrit"; onExisting = "update"; updateMode = "regenerate" }},
+		{name: "follow-up commit without gerrit", setup: func() { updateMode = "commit" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ResetFlags()
+			ResetRemoteFlags()
+			tt.setup()
+			err := validateReviewOptions()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vpukhanov/cascade/internal/history"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var rerunFailed bool
+
+var runsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "Browse and replay past apply runs",
+	Long:  "Every apply run is recorded in $XDG_STATE_HOME/cascade (~/.local/state/cascade by default) with its flags, repositories, statuses, commits and pull request URLs.",
+}
+
+var runsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List past runs, the most recent first",
+	Args:  cobra.NoArgs,
+	RunE:  runRunsList,
+}
+
+var runsShowCmd = &cobra.Command{
+	Use:   "show <run-id>",
+	Short: "Show the parameters and repository results of a run",
+	Long:  "Show the parameters and repository results of a run. The run ID can be shortened to any unique prefix.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRunsShow,
+}
+
+var runsRerunCmd = &cobra.Command{
+	Use:   "rerun <run-id>",
+	Short: "Run apply again with the flags and repositories of a past run",
+	Long:  "Run apply again with the flags and repositories of a past run, from the directory it was started in. The run ID can be shortened to any unique prefix.",
+	Example: `cascade runs rerun 20261018-101502
+cascade runs rerun 20261018-101502 --failed`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRunsRerun,
+}
+
+func init() {
+	rootCmd.AddCommand(runsCmd)
+	runsCmd.AddCommand(runsListCmd, runsShowCmd, runsRerunCmd)
+
+	runsRerunCmd.Flags().BoolVar(&rerunFailed, "failed", false, "Only rerun the repositories that failed")
+}
+
+// ResetRunsFlags resets the global flag variables of runs rerun to their defaults.
+func ResetRunsFlags() {
+	rerunFailed = false
+}
+
+func runRunsList(cmd *cobra.Command, args []string) error {
+	store, err := openHistory()
+	if err != nil {
+		return err
+	}
+	runs, err := store.List()
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Println("No runs recorded yet")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTARTED\tBRANCH\tREPOS\tFAILED")
+	for _, run := range runs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\n", run.ID, run.Started.Local().Format("2006-01-02 15:04"), run.Branch, len(run.Repos), run.Failed())
+	}
+	return w.Flush()
+}
+
+func runRunsShow(cmd *cobra.Command, args []string) error {
+	run, err := loadRun(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Run:       %s\n", run.ID)
+	fmt.Printf("Started:   %s\n", run.Started.Local().Format(time.RFC3339))
+	fmt.Printf("Finished:  %s\n", run.Finished.Local().Format(time.RFC3339))
+	fmt.Printf("Directory: %s\n", run.Dir)
+	fmt.Printf("Command:   %s\n", shellJoin(append([]string{"cascade", "apply"}, rerunArgs(run, run.Repos)...)))
+	if run.LogPath != "" {
+		fmt.Printf("Log:       %s\n", run.LogPath)
+	}
+	if run.RunLogDir != "" {
+		fmt.Printf("Run log:   %s\n", run.RunLogDir)
+	}
+	fmt.Println()
+
+	for _, repo := range run.Repos {
+		line := fmt.Sprintf("%-4s %s", repo.Status, repo.Path)
+		if repo.Detail != "" {
+			line += fmt.Sprintf(" (%s)", repo.Detail)
+		}
+		fmt.Println(line)
+		if repo.Branch != "" {
+			fmt.Printf("     branch: %s\n", repo.Branch)
+		}
+		if repo.Commit != "" {
+			fmt.Printf("     commit: %s\n", repo.Commit)
+		}
+		if repo.PRURL != "" {
+			fmt.Printf("     pr:     %s\n", repo.PRURL)
+		}
+		if repo.Error != "" {
+			// The first line is enough here, the log has the whole chain
+			fmt.Printf("     error:  %s\n", firstLine(repo.Error))
+		}
+	}
+	return nil
+}
+
+func runRunsRerun(cmd *cobra.Command, args []string) error {
+	run, err := loadRun(args[0])
+	if err != nil {
+		return err
+	}
+
+	repos := run.Repos
+	if rerunFailed {
+		repos = slices.DeleteFunc(slices.Clone(repos), func(repo history.Repo) bool {
+			return repo.Status == "ok"
+		})
+		if len(repos) == 0 {
+			return fmt.Errorf("run %s has no failed repositories", run.ID)
+		}
+	}
+
+	// Relative paths in the flags and repositories are relative to the
+	// directory the run was started from
+	if run.Dir != "" {
+		if err := os.Chdir(run.Dir); err != nil {
+			return fmt.Errorf("failed to change to the run directory: %w", err)
+		}
+	}
+
+	applyArgs := rerunArgs(run, repos)
+	fmt.Printf("Rerunning %s: %s\n\n", run.ID, shellJoin(append([]string{"cascade", "apply"}, applyArgs...)))
+
+	ResetFlags()
+	if err := applyCmd.ParseFlags(applyArgs); err != nil {
+		return fmt.Errorf("invalid flags in run %s: %w", run.ID, err)
+	}
+	repoArgs := applyCmd.Flags().Args()
+	if err := validateApply(applyCmd, repoArgs); err != nil {
+		return err
+	}
+	return runApply(applyCmd, repoArgs)
+}
+
+// openHistory opens the run history for reading. Runs that cannot be read
+// are skipped with a warning.
+func openHistory() (*history.Store, error) {
+	store, err := history.Open()
+	if err != nil {
+		return nil, err
+	}
+	store.Warn = func(err error) {
+		fmt.Fprintf(os.Stderr, "Warning: skipping run: %v\n", err)
+	}
+	return store, nil
+}
+
+func loadRun(id string) (history.Run, error) {
+	store, err := openHistory()
+	if err != nil {
+		return history.Run{}, err
+	}
+	return store.Load(id)
+}
+
+// rerunArgs returns the apply arguments that repeat the run for the given
+// repositories.
+func rerunArgs(run history.Run, repos []history.Repo) []string {
+	args := append(slices.Clone(run.Args), "--")
+	for _, repo := range repos {
+		args = append(args, repo.Path)
+	}
+	return args
+}
+
+// newHistoryRun builds the history record of an apply run. Only flags set on
+// the command line are recorded, options from a recipe are read from the
+// recipe again when the run is repeated.
+func newHistoryRun(cmd *cobra.Command, runID string, started time.Time, results []repoResult) history.Run {
+	run := history.Run{
+		ID:       runID,
+		Started:  started,
+		Finished: time.Now(),
+		Branch:   branch,
+		Message:  message,
+	}
+	run.Dir, _ = os.Getwd()
+
+	if cmd != nil {
+		cmd.Flags().Visit(func(f *pflag.Flag) {
+			if values, ok := f.Value.(pflag.SliceValue); ok {
+				for _, value := range values.GetSlice() {
+					run.Args = append(run.Args, "--"+f.Name+"="+value)
+				}
+				return
+			}
+			run.Args = append(run.Args, "--"+f.Name+"="+f.Value.String())
+		})
+	}
+
+	for _, result := range results {
+		repo := history.Repo{
+			Path:   result.repo,
+			Status: "ok",
+			Detail: result.detail,
+			Branch: result.branch,
+			Commit: result.commit,
+			PRURL:  result.prURL,
+		}
+		if result.err != nil {
+			repo.Status = "fail"
+			repo.Error = result.err.Error()
+		}
+		run.Repos = append(run.Repos, repo)
+	}
+	return run
+}
+
+// saveHistoryRun records the run. The history is a convenience, so failing
+// to write it only prints a warning.
+func saveHistoryRun(run history.Run) {
+	store, err := history.Open()
+	if err == nil {
+		err = store.Save(run)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save run history: %v\n", err)
+	}
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellJoin joins the arguments into a command line that can be pasted into
+// a shell.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if shellSafe.MatchString(arg) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewHistoryRun(t *testing.T) {
+	ResetFlags()
+	defer ResetFlags()
+	defer ResetRunsFlags()
+	err := applyCmd.ParseFlags([]string{
+		"--command", "make gen",
+		"--script-arg", "a b",
+		"--script-arg", "c",
+		"--branch", "deps/{{.RepoName}}",
+		"--message", "Bump deps",
+		"--push",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := time.Now().Add(-time.Minute)
+	results := []repoResult{
+		{repo: "repo1", detail: "2 files edited", branch: "deps/repo1", commit: "abc123", prURL: "https://example.com/pr/1"},
+		{repo: "repo2", branch: "deps/repo2", err: fmt.Errorf("push failed: %w", fmt.Errorf("rejected"))},
+	}
+	run := newHistoryRun(applyCmd, "run-1", started, results)
+
+	want := "--branch=deps/{{.RepoName}} --command=make gen --message=Bump deps --push=true --script-arg=a b --script-arg=c"
+	if got := strings.Join(run.Args, " "); got != want {
+		t.Errorf("Args = %q, want %q", got, want)
+	}
+	if run.ID != "run-1" || run.Branch != "deps/{{.RepoName}}" || run.Dir == "" || !run.Finished.After(started) {
+		t.Errorf("unexpected run %+v", run)
+	}
+	if run.Repos[0].Status != "ok" || run.Repos[0].Commit != "abc123" || run.Repos[0].PRURL != "https://example.com/pr/1" {
+		t.Errorf("unexpected first repository %+v", run.Repos[0])
+	}
+	if run.Repos[1].Status != "fail" || run.Repos[1].Error != "push failed: rejected" || run.Failed() != 1 {
+		t.Errorf("unexpected second repository %+v", run.Repos[1])
+	}
+
+	args := rerunArgs(run, run.Repos[1:])
+	if got := shellJoin(append([]string{"cascade", "apply"}, args...)); !strings.HasSuffix(got, "'--script-arg=a b' --script-arg=c -- repo2") {
+		t.Errorf("unexpected rerun command %q", got)
+	}
+}
+
+func TestShellJoin(t *testing.T) {
+	got := shellJoin([]string{"cascade", "--message=Fix it", "--var=k=v", "it's"})
+	if want := `cascade '--message=Fix it' --var=k=v 'it'\''s'`; got != want {
+		t.Errorf("shellJoin() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/vpukhanov/cascade/internal/git"
+	"github.com/vpukhanov/cascade/internal/validation"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	statusBranch string
+	statusBase   string
+	statusFetch  bool
+
+	gitFetch           = git.Fetch
+	gitGetBranchStatus = git.GetBranchStatus
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status [repositories...]",
+	Short: "Show the state of a campaign branch across repositories",
+	Long:  "Show whether the campaign branch exists locally and on the remote it is pushed to, how many commits it is ahead of and behind the base branch, whether it is checked out, whether the working tree is dirty, and its last commit.",
+	Example: `cascade status --branch update-logging ./repo1 ./repo2
+cascade status --branch update-logging --base-branch develop --fetch ./repos/*`,
+	Args:    cobra.MinimumNArgs(1),
+	RunE:    runStatus,
+	PreRunE: validateStatus,
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.Flags().StringVar(&statusBranch, "branch", "", "Name of the campaign branch, can be a template using the repository name")
+	statusCmd.Flags().StringVar(&statusBase, "base-branch", "", "Branch to compare against (default: the branch origin/HEAD points to, or main or master)")
+	statusCmd.Flags().BoolVar(&statusFetch, "fetch", false, "Fetch from origin and the push remote before reading the remote state")
+	addRemoteFlags(statusCmd)
+	addRetryFlags(statusCmd)
+}
+
+// ResetStatusFlags resets the global flag variables of status to their defaults.
+func ResetStatusFlags() {
+	statusBranch = ""
+	statusBase = ""
+	statusFetch = false
+}
+
+func validateStatus(cmd *cobra.Command, args []string) error {
+	if err := validateCampaignBranch(statusBranch, args); err != nil {
+		return err
+	}
+	if statusBase != "" {
+		if err := validation.ValidateBranchName(statusBase); err != nil {
+			return fmt.Errorf("invalid base branch name: %w", err)
+		}
+	}
+	if err := validateRemoteOptions(); err != nil {
+		return err
+	}
+	if err := parseTemplateVars(); err != nil {
+		return err
+	}
+	return validateRetryFlags()
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	setRetryPolicy()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tBRANCH\tLOCAL\tREMOTE\tAHEAD\tBEHIND\tCHECKED OUT\tDIRTY\tLAST COMMIT")
+
+	for _, repoPath := range args {
+		status, err := repoBranchStatus(repoPath)
+		if err != nil {
+			fmt.Fprintf(w, "%s\terror: %s\n", repoPath, firstLine(err.Error()))
+			continue
+		}
+
+		ahead, behind, last := "-", "-", "-"
+		if status.Exists() {
+			ahead = strconv.Itoa(status.Ahead)
+			behind = strconv.Itoa(status.Behind)
+			last = status.LastCommit
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			repoPath, status.Branch, yesNo(status.Local), yesNo(status.Remote),
+			ahead, behind, yesNo(status.CheckedOut), yesNo(status.Dirty), last)
+	}
+
+	return w.Flush()
+}
+
+// repoBranchStatus renders the branch name for the repository and returns
+// the state of the branch.
+func repoBranchStatus(repoPath string) (git.BranchStatus, error) {
+	repoBranch, err := renderCampaignBranch(repoPath, statusBranch)
+	if err != nil {
+		return git.BranchStatus{}, err
+	}
+
+	remote, err := campaignRemote(repoPath, repoBranch)
+	if err != nil {
+		return git.BranchStatus{}, fmt.Errorf("remote setup failed: %w", err)
+	}
+	if statusFetch {
+		if err := fetchRemotes(repoPath, remote); err != nil {
+			return git.BranchStatus{}, fmt.Errorf("fetch failed: %w", err)
+		}
+	}
+	status, err := gitGetBranchStatus(repoPath, remote, repoBranch, statusBase)
+	if err != nil {
+		return status, fmt.Errorf("status failed: %w", err)
+	}
+	return status, nil
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vpukhanov/cascade/internal/git"
+)
+
+func TestRunStatus(t *testing.T) {
+	resetMocks()
+	var fetched []string
+	gitFetch = func(repoPath, _ string) error {
+		fetched = append(fetched, repoPath)
+		if repoPath == "repo3" {
+			return fmt.Errorf("error fetching from remote: exit status 128\nfatal: unable to access")
+		}
+		return nil
+	}
+	gitGetBranchStatus = func(repoPath, remote, branch, base string) (git.BranchStatus, error) {
+		if repoPath == "repo2" {
+			return git.BranchStatus{Branch: branch, Base: "main"}, nil
+		}
+		return git.BranchStatus{
+			Branch: branch, Base: "main", Local: true, Remote: true,
+			Ahead: 2, Behind: 1, CheckedOut: true, LastCommit: "abc1234 Update logging (2 hours ago)",
+		}, nil
+	}
+	statusBranch = "deps/{{.RepoName}}"
+	statusFetch = true
+	defer ResetStatusFlags()
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	err := runStatus(nil, []string{"repo1", "repo2", "repo3"})
+	w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stdout = oldStdout
+
+	if err != nil {
+		t.Fatalf("runStatus() unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected a header and 3 rows, got:\n%s", out)
+	}
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{lines[1], []string{"repo1", "deps/repo1", "yes", "2", "1", "abc1234 Update logging (2 hours ago)"}},
+		{lines[2], []string{"repo2", "deps/repo2", "no", "-"}},
+		{lines[3], []string{"repo3", "error: fetch failed: error fetching from remote: exit status 128"}},
+	}
+	for _, tt := range tests {
+		for _, want := range tt.want {
+			if !strings.Contains(tt.line, want) {
+				t.Errorf("row %q does not contain %q", tt.line, want)
+			}
+		}
+	}
+	if strings.Contains(string(out), "unable to access") {
+		t.Errorf("expected only the first line of the error, got:\n%s", out)
+	}
+	if len(fetched) != 3 {
+		t.Errorf("expected every repository to be fetched, got %v", fetched)
+	}
+}
+
+func TestRunStatusPushRemote(t *testing.T) {
+	resetMocks()
+	defer ResetStatusFlags()
+	defer ResetRemoteFlags()
+	var calls []string
+	gitEnsureRemote = func(repoPath, name, url string) error {
+		calls = append(calls, "add "+name+" "+url)
+		return nil
+	}
+	gitFetch = func(repoPath, remote string) error {
+		calls = append(calls, "fetch "+remote)
+		return nil
+	}
+	gitGetBranchStatus = func(repoPath, remote, branch, base string) (git.BranchStatus, error) {
+		calls = append(calls, "status "+remote)
+		return git.BranchStatus{Branch: branch, Base: "main"}, nil
+	}
+	statusBranch = "deps/upgrade"
+	statusFetch = true
+	forkURL = "git@example.com:me/{{.RepoName}}.git"
+	forkRemote = "fork"
+
+	captureRunOutput(t, func() error { return runStatus(nil, []string{"repo1"}) })
+	// Status reads the fork remote apply added, but never adds it
+	want := []string{"fetch origin", "fetch fork", "status fork"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("expected calls %v, got %v", want, calls)
+	}
+
+	calls = nil
+	gitRemoteURL = func(repoPath, remote string) (string, error) {
+		return "", errors.New("no such remote")
+	}
+	output := captureRunOutput(t, func() error { return runStatus(nil, []string{"repo1"}) })
+	if len(calls) != 0 || !strings.Contains(output, "fork remote fork is not set up") {
+		t.Errorf("expected the missing fork to be reported without changes, got calls %v and:\n%s", calls, output)
+	}
+}
+
+func TestValidateStatus(t *testing.T) {
+	defer ResetStatusFlags()
+
+	ResetStatusFlags()
+	if err := validateStatus(nil, nil); err == nil || !strings.Contains(err.Error(), "--branch is required") {
+		t.Errorf("expected missing branch error, got %v", err)
+	}
+
+	statusBranch = "bad..name"
+	if err := validateStatus(nil, nil); err == nil {
+		t.Error("expected invalid branch name error")
+	}
+
+	statusBranch = "deps/{{.RepoName}}"
+	if err := validateStatus(nil, []string{t.TempDir()}); err == nil {
+		t.Error("expected error for a directory that is not a repository")
+	}
+}
@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vpukhanov/cascade/internal/git"
+	"github.com/vpukhanov/cascade/internal/keyedit"
+	"github.com/vpukhanov/cascade/internal/recipe"
+	"github.com/vpukhanov/cascade/internal/tmpl"
+)
+
+// applySteps returns the recipe steps, or a single step built from the
+// change mode flags.
+func applySteps() ([]recipe.Step, error) {
+	if loadedRecipe != nil {
+		return loadedRecipe.Steps, nil
+	}
+
+	step := recipe.Step{Raw: rawTemplates}
+	switch {
+	case command != "":
+		step.Command = command
+		step.Args = scriptArgs
+	case scriptFile != "":
+		absPath, err := filepath.Abs(scriptFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path: %w", err)
+		}
+		step.Script = absPath
+		step.Args = scriptArgs
+		step.Interpreter = interpreter
+	case starlarkFile != "":
+		absPath, err := filepath.Abs(starlarkFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path: %w", err)
+		}
+		step.Starlark = absPath
+	case wasmFile != "":
+		absPath, err := filepath.Abs(wasmFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path: %w", err)
+		}
+		step.Wasm = absPath
+	case !goOptions.IsZero():
+		opts := goOptions
+		step.Go = &opts
+	case len(assignments) > 0:
+		step.Assignments = assignments
+	default:
+		absPath, err := filepath.Abs(patchFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path: %w", err)
+		}
+		step.Patch = absPath
+	}
+	return []recipe.Step{step}, nil
+}
+
+// runSteps applies the steps to the repository in order, committing after
+// steps that have their own commit message. Script and command output is
+// copied to output when it is not nil. It returns a short description
+// of the changes and whether any commit was made.
+func runSteps(repoPath string, steps []recipe.Step, data tmpl.Data, output io.Writer) (string, bool, error) {
+	var details []string
+	committed := false
+
+	for i, step := range steps {
+		detail, err := runTemplatedStep(repoPath, step, data, output)
+		if err == nil && step.Commit != "" {
+			if err = commitStep(repoPath, i, step, data); err == nil {
+				committed = true
+			}
+		}
+		if err != nil {
+			if len(steps) > 1 {
+				err = fmt.Errorf("step %d (%s) failed: %w", i+1, step.Describe(), err)
+			}
+			return strings.Join(details, ", "), committed, &stepError{step: step.Describe(), err: err}
+		}
+		if detail != "" {
+			details = append(details, detail)
+		}
+	}
+
+	return strings.Join(details, ", "), committed, nil
+}
+
+// stepError records which step failed. It adds nothing to the message, so
+// the error reads the same as the error it wraps.
+type stepError struct {
+	step string
+	err  error
+}
+
+func (e *stepError) Error() string { return e.err.Error() }
+
+func (e *stepError) Unwrap() error { return e.err }
+
+func commitStep(repoPath string, index int, step recipe.Step, data tmpl.Data) error {
+	stepMessage, err := tmpl.Render("commit message", step.Commit, data)
+	if err != nil {
+		return err
+	}
+	stepMessage = reviewMessage(stepMessage, data, fmt.Sprintf("step %d", index+1))
+	if err := gitCommitChanges(repoPath, stepMessage, noVerify); err != nil {
+		return fmt.Errorf("commit failed: %w", err)
+	}
+	return nil
+}
+
+// runTemplatedStep renders the step with the template data before running
+// it, unless the step is raw.
+func runTemplatedStep(repoPath string, step recipe.Step, data tmpl.Data, output io.Writer) (string, error) {
+	if step.Raw {
+		return runStep(repoPath, step, data, output)
+	}
+
+	rendered, cleanup, err := renderStep(step, data)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	return runStep(repoPath, rendered, data, output)
+}
+
+// renderStep returns a copy of the step with its command, values, replace
+// options and patch rendered. The returned cleanup function removes the rendered patch.
+func renderStep(step recipe.Step, data tmpl.Data) (recipe.Step, func(), error) {
+	cleanup := func() {}
+	var err error
+
+	if step.Command, err = tmpl.Render("command", step.Command, data); err != nil {
+		return step, cleanup, err
+	}
+	if step.Verify, err = tmpl.Render("verify", step.Verify, data); err != nil {
+		return step, cleanup, err
+	}
+
+	if len(step.Assignments) > 0 {
+		assignments := make([]keyedit.Assignment, len(step.Assignments))
+		for i, a := range step.Assignments {
+			if a.Value, err = tmpl.Render("value", a.Value, data); err != nil {
+				return step, cleanup, err
+			}
+			assignments[i] = a
+		}
+		step.Assignments = assignments
+	}
+
+	if step.Replace != nil {
+		replace := *step.Replace
+		if replace.Pattern, err = tmpl.Render("replace pattern", replace.Pattern, data); err != nil {
+			return step, cleanup, err
+		}
+		if replace.With, err = tmpl.Render("replace value", replace.With, data); err != nil {
+			return step, cleanup, err
+		}
+		step.Replace = &replace
+	}
+
+	if step.Patch != "" {
+		content, err := os.ReadFile(step.Patch)
+		if err != nil {
+			return step, cleanup, fmt.Errorf("error reading patch file: %w", err)
+		}
+		rendered, err := tmpl.Render("patch", string(content), data)
+		if err != nil {
+			return step, cleanup, err
+		}
+		file, err := os.CreateTemp("", "cascade-patch-*.patch")
+		if err != nil {
+			return step, cleanup, fmt.Errorf("error creating rendered patch: %w", err)
+		}
+		cleanup = func() { _ = os.Remove(file.Name()) }
+		_, err = file.WriteString(rendered)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			cleanup()
+			return step, func() {}, fmt.Errorf("error writing rendered patch: %w", err)
+		}
+		step.Patch = file.Name()
+	}
+
+	return step, cleanup, nil
+}
+
+func runStep(repoPath string, step recipe.Step, data tmpl.Data, output io.Writer) (string, error) {
+	switch step.Kind() {
+	case "command":
+		opts, err := execOptions(step, data, output)
+		if err != nil {
+			return "", err
+		}
+		if err := gitExecuteCommand(repoPath, step.Command, opts); err != nil {
+			return "", fmt.Errorf("command execution failed: %w", err)
+		}
+	case "script":
+		opts, err := execOptions(step, data, output)
+		if err != nil {
+			return "", err
+		}
+		if err := gitExecuteScript(repoPath, step.Script, opts); err != nil {
+			return "", fmt.Errorf("script execution failed: %w", err)
+		}
+	case "patch":
+		if err := gitApplyPatch(repoPath, step.Patch); err != nil {
+			return "", fmt.Errorf("patch application failed: %w", err)
+		}
+	case "go":
+		edited, err := codemodApplyGo(repoPath, *step.Go)
+		if err != nil {
+			return "", fmt.Errorf("go codemod failed: %w", err)
+		}
+		return fmt.Sprintf("%d Go files edited", edited), nil
+	case "set":
+		for _, a := range step.Assignments {
+			if err := keyeditApply(repoPath, a); err != nil {
+				return "", fmt.Errorf("set %s failed: %w", a.Key(), err)
+			}
+		}
+	case "replace":
+		edited, err := codemodReplace(repoPath, *step.Replace)
+		if err != nil {
+			return "", fmt.Errorf("replace failed: %w", err)
+		}
+		return fmt.Sprintf("%d files edited", edited), nil
+	case "starlark":
+		if err := transformRunStarlark(repoPath, step.Starlark, data.Vars, transformTimeout); err != nil {
+			return "", fmt.Errorf("starlark execution failed: %w", err)
+		}
+	case "wasm":
+		if err := transformRunWasm(repoPath, step.Wasm, data.Vars, transformTimeout); err != nil {
+			return "", fmt.Errorf("wasm execution failed: %w", err)
+		}
+	case "verify":
+		if err := gitExecuteCommand(repoPath, step.Verify, git.ExecOptions{Env: data.Env(), Output: output}); err != nil {
+			return "", fmt.Errorf("verification failed: %w", err)
+		}
+	default:
+		return "", fmt.Errorf("step has no action")
+	}
+	return "", nil
+}
+
+// execOptions returns the CASCADE_* environment and the step arguments,
+// rendered for the repository unless the step is raw. Process output is
+// copied to output when it is not nil.
+func execOptions(step recipe.Step, data tmpl.Data, output io.Writer) (git.ExecOptions, error) {
+	opts := git.ExecOptions{Env: data.Env(), Interpreter: step.Interpreter, Output: output}
+	for _, arg := range step.Args {
+		if !step.Raw {
+			var err error
+			if arg, err = tmpl.Render("script argument", arg, data); err != nil {
+				return opts, err
+			}
+		}
+		opts.Args = append(opts.Args, arg)
+	}
+	return opts, nil
+}
+
+// commitRemaining commits the changes left after the steps with the campaign
+// message. When steps already made commits, it only commits if something is
+// left over.
+func commitRemaining(repoPath string, message string, committed bool) error {
+	if committed {
+		hasChanges, err := gitHasChanges(repoPath)
+		if err != nil {
+			return err
+		}
+		if !hasChanges {
+			return nil
+		}
+	}
+	return gitCommitChanges(repoPath, message, noVerify)
+}
+
+// amendRemaining adds the changes to the last commit of an existing campaign
+// branch, with the campaign message.
+func amendRemaining(repoPath string, message string, committed bool) error {
+	return gitAmendCommit(repoPath, message, noVerify)
+}
@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vpukhanov/cascade/internal/codemod"
+	"github.com/vpukhanov/cascade/internal/git"
+	"github.com/vpukhanov/cascade/internal/keyedit"
+	"github.com/vpukhanov/cascade/internal/recipe"
+	"github.com/vpukhanov/cascade/internal/tmpl"
+)
+
+func TestRunSteps(t *testing.T) {
+	t.Run("runs steps in order with per-step commits", func(t *testing.T) {
+		resetMocks()
+		var calls []string
+		gitExecuteCommand = func(_, command string, _ git.ExecOptions) error {
+			calls = append(calls, "command:"+command)
+			return nil
+		}
+		gitApplyPatch = func(_, patchPath string) error {
+			calls = append(calls, "patch:"+patchPath)
+			return nil
+		}
+		gitCommitChanges = func(_, message string, _ bool) error {
+			calls = append(calls, "commit:"+message)
+			return nil
+		}
+		codemodReplace = func(_ string, _ codemod.ReplaceOptions) (int, error) {
+			calls = append(calls, "replace")
+			return 3, nil
+		}
+
+		steps := []recipe.Step{
+			{Command: "make gen", Commit: "Generate code"},
+			{Patch: "/tmp/fix.patch", Raw: true},
+			{Replace: &codemod.ReplaceOptions{Files: []string{"*"}, Pattern: "a", With: "b"}},
+			{Verify: "make test"},
+		}
+		detail, committed, err := runSteps("repo1", steps, tmpl.Data{}, nil)
+		if err != nil {
+			t.Fatalf("runSteps failed: %v", err)
+		}
+		if !committed {
+			t.Error("expected a commit to be reported")
+		}
+		if detail != "3 files edited" {
+			t.Errorf("unexpected detail %q", detail)
+		}
+
+		want := []string{"command:make gen", "commit:Generate code", "patch:/tmp/fix.patch", "replace", "command:make test"}
+		if strings.Join(calls, "|") != strings.Join(want, "|") {
+			t.Errorf("calls = %v, want %v", calls, want)
+		}
+	})
+
+	t.Run("stops at the first failing step", func(t *testing.T) {
+		resetMocks()
+		ran := 0
+		gitExecuteCommand = func(_, command string, _ git.ExecOptions) error {
+			ran++
+			if command == "make test" {
+				return fmt.Errorf("tests failed")
+			}
+			return nil
+		}
+
+		steps := []recipe.Step{
+			{Command: "make gen"},
+			{Name: "tests", Verify: "make test"},
+			{Command: "never"},
+		}
+		_, _, err := runSteps("repo1", steps, tmpl.Data{}, nil)
+		if err == nil || !strings.Contains(err.Error(), "step 2 (tests) failed: verification failed") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ran != 2 {
+			t.Errorf("expected 2 commands to run, got %d", ran)
+		}
+		var stepErr *stepError
+		if !errors.As(err, &stepErr) || stepErr.step != "tests" {
+			t.Errorf("expected the failing step to be recorded, got %v", err)
+		}
+	})
+}
+
+func TestCommitRemaining(t *testing.T) {
+	resetMocks()
+	commits := 0
+	gitCommitChanges = func(_, _ string, _ bool) error {
+		commits++
+		return nil
+	}
+
+	if err := commitRemaining("repo1", "Update", true); err != nil {
+		t.Fatalf("commitRemaining failed: %v", err)
+	}
+	if commits != 0 {
+		t.Errorf("expected no commit when steps committed everything, got %d", commits)
+	}
+
+	gitHasChanges = func(_ string) (bool, error) { return true, nil }
+	if err := commitRemaining("repo1", "Update", true); err != nil {
+		t.Fatalf("commitRemaining failed: %v", err)
+	}
+	if err := commitRemaining("repo1", "Update", false); err != nil {
+		t.Fatalf("commitRemaining failed: %v", err)
+	}
+	if commits != 2 {
+		t.Errorf("expected 2 commits for leftover changes, got %d", commits)
+	}
+}
+
+func TestRunStepsTemplates(t *testing.T) {
+	resetMocks()
+	var commands, messages []string
+	var patchContent, setValue string
+	gitExecuteCommand = func(_, command string, _ git.ExecOptions) error {
+		commands = append(commands, command)
+		return nil
+	}
+	gitCommitChanges = func(_, message string, _ bool) error {
+		messages = append(messages, message)
+		return nil
+	}
+	gitApplyPatch = func(_, patchPath string) error {
+		data, err := os.ReadFile(patchPath)
+		patchContent = string(data)
+		return err
+	}
+	keyeditApply = func(_ string, a keyedit.Assignment) error {
+		setValue = a.Value
+		return nil
+	}
+
+	patchPath := filepath.Join(t.TempDir(), "bump.patch")
+	if err := os.WriteFile(patchPath, []byte("+version {{.Vars.version}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []recipe.Step{
+		{Command: "bump {{.Vars.version}}", Commit: "Bump to {{.Vars.version}}"},
+		{Command: "echo {{literal}}", Raw: true},
+		{Patch: patchPath},
+		{Assignments: []keyedit.Assignment{{File: "package.json", Path: []string{"version"}, Value: "{{.Vars.version}}"}}},
+	}
+	data := tmpl.Data{Vars: map[string]string{"version": "1.2.3"}}
+	if _, _, err := runSteps("repo1", steps, data, nil); err != nil {
+		t.Fatalf("runSteps failed: %v", err)
+	}
+
+	if strings.Join(commands, "|") != "bump 1.2.3|echo {{literal}}" {
+		t.Errorf("unexpected commands: %v", commands)
+	}
+	if len(messages) != 1 || messages[0] != "Bump to 1.2.3" {
+		t.Errorf("unexpected commit messages: %v", messages)
+	}
+	if patchContent != "+version 1.2.3\n" {
+		t.Errorf("unexpected rendered patch: %q", patchContent)
+	}
+	if setValue != "1.2.3" {
+		t.Errorf("unexpected rendered set value: %q", setValue)
+	}
+
+	steps = []recipe.Step{{Command: "bump {{.Vars.missing}}"}}
+	if _, _, err := runSteps("repo1", steps, data, nil); err == nil {
+		t.Error("expected error for undefined template variable")
+	}
+
+	// Steps use the built-in fields without any variables, and steps from
+	// the flags keep literal braces with --raw
+	defer ResetFlags()
+	data = tmpl.Data{RepoName: "repo1", Branch: "deps/bump"}
+	command = "release {{.RepoName}} {{.Branch}}"
+	commands = nil
+	for _, raw := range []bool{false, true} {
+		rawTemplates = raw
+		steps, err := applySteps()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := runSteps("repo1", steps, data, nil); err != nil {
+			t.Fatalf("runSteps failed: %v", err)
+		}
+	}
+	if strings.Join(commands, "|") != "release repo1 deps/bump|release {{.RepoName}} {{.Branch}}" {
+		t.Errorf("unexpected commands: %v", commands)
+	}
+
+	var replace codemod.ReplaceOptions
+	codemodReplace = func(_ string, opts codemod.ReplaceOptions) (int, error) {
+		replace = opts
+		return 1, nil
+	}
+	steps = []recipe.Step{{Replace: &codemod.ReplaceOptions{Files: []string{"*"}, Pattern: "v{{.Vars.old}}", With: "v{{.Vars.new}}"}}}
+	data = tmpl.Data{Vars: map[string]string{"old": "1", "new": "2"}}
+	if _, _, err := runSteps("repo1", steps, data, nil); err != nil {
+		t.Fatalf("runSteps failed: %v", err)
+	}
+	if replace.Pattern != "v1" || replace.With != "v2" {
+		t.Errorf("unexpected rendered replace options: %q -> %q", replace.Pattern, replace.With)
+	}
+	if steps[0].Replace.Pattern != "v{{.Vars.old}}" {
+		t.Errorf("rendering changed the step: %q", steps[0].Replace.Pattern)
+	}
+}
+
+func TestRunStepsExecOptions(t *testing.T) {
+	resetMocks()
+	var scriptOpts, verifyOpts git.ExecOptions
+	gitExecuteScript = func(_, _ string, opts git.ExecOptions) error {
+		scriptOpts = opts
+		return nil
+	}
+	gitExecuteCommand = func(_, _ string, opts git.ExecOptions) error {
+		verifyOpts = opts
+		return nil
+	}
+
+	steps := []recipe.Step{
+		{Script: "/scripts/update.sh", Args: []string{"--repo", "{{.RepoName}}"}, Interpreter: "bash"},
+		{Verify: "make test"},
+	}
+	data := tmpl.Data{RepoName: "service", Branch: "deps/bump", RunID: "run-1"}
+	if _, _, err := runSteps("repo1", steps, data, nil); err != nil {
+		t.Fatalf("runSteps failed: %v", err)
+	}
+
+	if strings.Join(scriptOpts.Args, " ") != "--repo service" {
+		t.Errorf("unexpected script args: %q", scriptOpts.Args)
+	}
+	if scriptOpts.Interpreter != "bash" {
+		t.Errorf("unexpected script interpreter: %q", scriptOpts.Interpreter)
+	}
+	for _, want := range []string{"CASCADE_REPO_NAME=service", "CASCADE_BRANCH=deps/bump", "CASCADE_RUN_ID=run-1"} {
+		if !slices.Contains(scriptOpts.Env, want) {
+			t.Errorf("script environment %q does not contain %q", scriptOpts.Env, want)
+		}
+		if !slices.Contains(verifyOpts.Env, want) {
+			t.Errorf("verify environment %q does not contain %q", verifyOpts.Env, want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"github.com/vpukhanov/cascade/internal/history"
+	applog "github.com/vpukhanov/cascade/internal/log"
+)
+
+// writeSummary writes the summary page of the run into the run log
+// directory, or into the state directory without one, and opens it with
+// --open-remote-url when a pull request link is on it.
+func writeSummary(runID string, runDir *applog.RunDir, results []repoResult) error {
+	var dir string
+	if runDir != nil {
+		dir = runDir.Path()
+	} else {
+		stateDir, err := history.Dir()
+		if err != nil {
+			return fmt.Errorf("failed to write summary: %w", err)
+		}
+		dir = filepath.Join(stateDir, "summaries", runID)
+	}
+
+	summary := applog.Summary{
+		RunID:    runID,
+		Branch:   branch,
+		Message:  message,
+		Template: true,
+		Finished: time.Now(),
+	}
+	// The heading shows the branch and message as rendered when they are
+	// the same for every repository, and the templates otherwise
+	rendered := map[[2]string]bool{}
+	for _, result := range results {
+		rendered[[2]string{result.branch, result.message}] = true
+	}
+	if len(rendered) == 1 {
+		for heading := range rendered {
+			summary.Branch, summary.Message, summary.Template = heading[0], heading[1], false
+		}
+	}
+	links := 0
+	for _, result := range results {
+		repo := applog.SummaryRepo{
+			Repo:     result.repo,
+			Status:   "ok",
+			Branch:   result.branch,
+			Detail:   result.detail,
+			DiffStat: result.diffStat,
+			URL:      result.prURL,
+		}
+		if result.err != nil {
+			repo.Status = "fail"
+			repo.Detail = firstLine(result.err.Error())
+		}
+		if repo.URL != "" {
+			links++
+		}
+		summary.Repos = append(summary.Repos, repo)
+	}
+
+	path, err := applog.WriteSummary(dir, summary)
+	if err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	fmt.Printf("\nSummary: %s\n", path)
+
+	if openRemoteURL && links > 0 {
+		// Ignore the error, because opening a browser can fail depending
+		// on the execution environment
+		_ = openURL(browser, fileURL(path))
+	}
+	return nil
+}
+
+// fileURL returns the file:// URL of the path.
+func fileURL(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
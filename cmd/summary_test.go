@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRunApplySummary(t *testing.T) {
+	resetMocks()
+	defer ResetFlags()
+
+	gitPushChanges = func(repoPath, remote, branch string, noVerify bool) (string, error) {
+		return "remote:   https://github.com/org/" + repoPath + "/pull/new/" + branch + "\n", nil
+	}
+	gitDiffStat = func(repoPath, base, head string) (string, error) {
+		return "1 file changed, 2 insertions(+)", nil
+	}
+	var opened []string
+	openURL = func(browser, url string) error {
+		opened = append(opened, browser+" "+url)
+		return nil
+	}
+	command = "make bump"
+	branch = "deps/bump"
+	message = "Bump"
+	push = true
+	openRemoteURL = true
+	browser = "firefox --new-tab"
+	logDir = t.TempDir()
+
+	out := captureRunOutput(t, func() error { return runApply(nil, []string{"repo1", "repo2"}) })
+	match := regexp.MustCompile(`Summary: (\S+)`).FindStringSubmatch(out)
+	if match == nil {
+		t.Fatalf("expected the summary path, got:\n%s", out)
+	}
+	path := match[1]
+	if filepath.Dir(filepath.Dir(path)) != logDir || filepath.Base(path) != "summary.html" {
+		t.Errorf("expected the summary in the run log directory, got %s", path)
+	}
+
+	// One page is opened for all repositories
+	if len(opened) != 1 || opened[0] != "firefox --new-tab "+fileURL(path) {
+		t.Errorf("expected the summary page to be opened once, got %v", opened)
+	}
+
+	markdown, err := os.ReadFile(filepath.Join(filepath.Dir(path), "summary.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, repo := range []string{"repo1", "repo2"} {
+		url := "https://github.com/org/" + repo + "/pull/new/deps/bump"
+		row := "| " + repo + " | ok | deps/bump | 1 file changed, 2 insertions(+) | [" + url + "](" + url + ") |"
+		if !strings.Contains(string(markdown), row) {
+			t.Errorf("expected row %q in the summary, got:\n%s", row, markdown)
+		}
+	}
+}
+
+func TestRunApplySummaryWithoutLinks(t *testing.T) {
+	resetMocks()
+	defer ResetFlags()
+
+	openURL = func(browser, url string) error {
+		t.Errorf("unexpected browser for a summary without links: %s", url)
+		return nil
+	}
+	command = "make bump"
+	branch = "deps/bump"
+	message = "Bump"
+	push = true
+	openRemoteURL = true
+
+	out := captureRunOutput(t, func() error { return runApply(nil, []string{"repo1"}) })
+	// Without a run log directory, the summary is kept in the state directory
+	stateDir := os.Getenv("XDG_STATE_HOME")
+	if !strings.Contains(out, "Summary: "+filepath.Join(stateDir, "cascade", "summaries")) {
+		t.Errorf("expected the summary in the state directory, got:\n%s", out)
+	}
+}
+
+func TestRunApplySummaryTemplates(t *testing.T) {
+	resetMocks()
+	defer ResetFlags()
+
+	command = "make bump"
+	message = "Bump {{.RepoName}}"
+	logDir = t.TempDir()
+
+	summaryOf := func(repos ...string) string {
+		t.Helper()
+		out := captureRunOutput(t, func() error { return runApply(nil, repos) })
+		match := regexp.MustCompile(`Summary: (\S+)`).FindStringSubmatch(out)
+		if match == nil {
+			t.Fatalf("expected the summary path, got:\n%s", out)
+		}
+		markdown, err := os.ReadFile(filepath.Join(filepath.Dir(match[1]), "summary.md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(markdown)
+	}
+
+	// Branches that differ per repository are shown in the rows, and the
+	// heading is labeled as the template
+	branch = "deps/{{.RepoName}}"
+	markdown := summaryOf("repo1", "repo2")
+	if !strings.HasPrefix(markdown, "# deps/{{.RepoName}}\n\nBump {{.RepoName}}\n\nBranch and message templates") {
+		t.Errorf("expected the templates in the heading, got:\n%s", markdown)
+	}
+	if !strings.Contains(markdown, "| repo1 | ok | deps/repo1 |") || !strings.Contains(markdown, "| repo2 | ok | deps/repo2 |") {
+		t.Errorf("expected the rendered branches in the rows, got:\n%s", markdown)
+	}
+
+	// A single repository renders the heading
+	markdown = summaryOf("repo1")
+	if !strings.HasPrefix(markdown, "# deps/repo1\n\nBump repo1\n\nRun ") {
+		t.Errorf("expected the rendered branch and message in the heading, got:\n%s", markdown)
+	}
+}
+
+func TestRunApplyPushWithoutSummary(t *testing.T) {
+	resetMocks()
+	defer ResetFlags()
+
+	command = "make bump"
+	branch = "deps/bump"
+	message = "Bump"
+	push = true
+
+	out := captureRunOutput(t, func() error { return runApply(nil, []string{"repo1"}) })
+	if strings.Contains(out, "Summary:") {
+		t.Errorf("expected no summary without --open-remote-url or --log-dir, got:\n%s", out)
+	}
+}
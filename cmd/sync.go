@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vpukhanov/cascade/internal/git"
+	"github.com/vpukhanov/cascade/internal/validation"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncBranch   string
+	syncBase     string
+	syncRecipe   string
+	syncNoVerify bool
+
+	gitRefExists  = git.RefExists
+	gitIsAncestor = git.IsAncestor
+	gitRebase     = git.Rebase
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync [repositories...]",
+	Short: "Bring campaign branches up to date with their base branch",
+	Long: `Fetch from origin and the push remote, rebase the campaign branch onto the latest base branch and force-push it with lease in every repository.
+Rebases that conflict are aborted and the repositories are listed, to be handled by hand. With --recipe the campaign branch is regenerated instead: the recipe runs again on a freshly pulled base branch and the result replaces the branch.
+Repositories without the campaign branch are skipped, and so are branches whose remote branch has commits the local branch lacks.`,
+	Example: `cascade sync --branch update-logging ./repo1 ./repo2
+cascade sync --branch update-logging --recipe ./upgrade.yaml --base-branch main ./repos/*`,
+	Args:    cobra.MinimumNArgs(1),
+	RunE:    runSync,
+	PreRunE: validateSync,
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+
+	syncCmd.Flags().StringVar(&syncBranch, "branch", "", "Name of the campaign branch, can be a template using the repository name")
+	syncCmd.Flags().StringVar(&syncBase, "base-branch", "", "Branch to rebase onto (default: the branch origin/HEAD points to, or main or master)")
+	syncCmd.Flags().StringVar(&syncRecipe, "recipe", "", "Regenerate the branch by running the recipe again on the latest base branch instead of rebasing")
+	syncCmd.Flags().BoolVar(&syncNoVerify, "no-verify", false, "Skip git commit and push hooks")
+	addRemoteFlags(syncCmd)
+	addRetryFlags(syncCmd)
+}
+
+// ResetSyncFlags resets the global flag variables of sync to their defaults.
+func ResetSyncFlags() {
+	syncBranch = ""
+	syncBase = ""
+	syncRecipe = ""
+	syncNoVerify = false
+}
+
+func validateSync(cmd *cobra.Command, args []string) error {
+	// Regenerating goes through apply, which validates the recipe
+	if syncRecipe == "" {
+		if err := validateCampaignBranch(syncBranch, args); err != nil {
+			return err
+		}
+	} else if syncBranch == "" {
+		return fmt.Errorf("--branch is required")
+	}
+	if syncBase != "" {
+		if err := validation.ValidateBranchName(syncBase); err != nil {
+			return fmt.Errorf("invalid base branch name: %w", err)
+		}
+	}
+	if err := validateRemoteOptions(); err != nil {
+		return err
+	}
+	if err := parseTemplateVars(); err != nil {
+		return err
+	}
+	return validateRetryFlags()
+}
+
+func runSync(cmd *cobra.Command, args []string) error {
+	if syncRecipe != "" {
+		return regenerateBranches(cmd, args)
+	}
+	setRetryPolicy()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tBRANCH\tRESULT\tREASON")
+	var conflicts []string
+	for _, repoPath := range args {
+		outcome := syncRepo(repoPath)
+		if outcome.result == "conflict" {
+			conflicts = append(conflicts, fmt.Sprintf("  %s: rebase %s onto %s", repoPath, outcome.branch, outcome.upstream))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", repoPath, outcome.branch, outcome.result, outcome.reason)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(conflicts) > 0 {
+		fmt.Printf("\n%d repositories have conflicts to resolve by hand:\n%s\n", len(conflicts), strings.Join(conflicts, "\n"))
+	}
+	return nil
+}
+
+// syncOutcome is what happened to the campaign branch of a repository, with
+// the base it was rebased onto.
+type syncOutcome struct {
+	branch   string
+	upstream string
+	result   string
+	reason   string
+}
+
+// syncRepo rebases the campaign branch of the repository onto the latest
+// base branch and force-pushes it. The repository is left on the branch
+// that was checked out before.
+func syncRepo(repoPath string) (outcome syncOutcome) {
+	outcome.branch = "-"
+	failed := func(err error) syncOutcome {
+		outcome.result, outcome.reason = "failed", firstLine(err.Error())
+		return outcome
+	}
+
+	repoBranch, err := renderCampaignBranch(repoPath, syncBranch)
+	if err != nil {
+		return failed(err)
+	}
+	outcome.branch = repoBranch
+
+	remote, err := ensureCampaignRemote(repoPath, repoBranch, syncBase)
+	if err != nil {
+		return failed(fmt.Errorf("remote setup failed: %w", err))
+	}
+	if err := fetchRemotes(repoPath, remote); err != nil {
+		return failed(fmt.Errorf("fetch failed: %w", err))
+	}
+	status, err := gitGetBranchStatus(repoPath, remote, repoBranch, syncBase)
+	if err != nil {
+		return failed(fmt.Errorf("status failed: %w", err))
+	}
+	if !status.Exists() {
+		outcome.result, outcome.reason = "skipped", "branch does not exist"
+		return outcome
+	}
+	if status.Dirty {
+		outcome.result, outcome.reason = "skipped", "working tree has uncommitted changes"
+		return outcome
+	}
+
+	// The remote base branch is the latest one, the local one may be stale
+	upstream := "origin/" + status.Base
+	remoteBase, err := gitRefExists(repoPath, "refs/remotes/"+upstream)
+	if err != nil {
+		return failed(err)
+	}
+	if !remoteBase {
+		upstream = status.Base
+	}
+	outcome.upstream = upstream
+
+	remoteRef := "refs/remotes/" + remote + "/" + repoBranch
+	branchRef := remoteRef
+	if status.Local {
+		branchRef = "refs/heads/" + repoBranch
+	}
+	// The force push would drop commits others pushed to the remote branch,
+	// like review fixes, unless the local branch has them too
+	if status.Local && status.Remote {
+		contained, err := gitIsAncestor(repoPath, remoteRef, branchRef)
+		if err != nil {
+			return failed(err)
+		}
+		if !contained {
+			outcome.result, outcome.reason = "diverged", remote+"/"+repoBranch+" has commits the local branch lacks"
+			return outcome
+		}
+	}
+	upToDate, err := gitIsAncestor(repoPath, upstream, branchRef)
+	if err != nil {
+		return failed(err)
+	}
+	if upToDate {
+		outcome.result, outcome.reason = "up to date", "already based on "+upstream
+		return outcome
+	}
+
+	previous, err := gitCurrentBranch(repoPath)
+	if err != nil {
+		return failed(err)
+	}
+	if err := gitCheckoutExistingBranch(repoPath, repoBranch); err != nil {
+		return failed(fmt.Errorf("branch checkout failed: %w", err))
+	}
+	// Go back to the branch the repository was on, whatever happens
+	defer func() {
+		if previous == "" || previous == repoBranch {
+			return
+		}
+		if err := gitCheckoutExistingBranch(repoPath, previous); err != nil && outcome.result != "failed" {
+			outcome.result = "failed"
+			outcome.reason = firstLine(fmt.Errorf("checkout of %s failed: %w", previous, err).Error())
+		}
+	}()
+
+	if err := gitRebase(repoPath, upstream); err != nil {
+		var conflictErr *git.ConflictError
+		if errors.As(err, &conflictErr) {
+			outcome.result, outcome.reason = "conflict", "conflicts in "+strings.Join(conflictErr.Files, ", ")
+			return outcome
+		}
+		return failed(fmt.Errorf("rebase failed: %w", err))
+	}
+	if _, err := gitForcePushChanges(repoPath, remote, repoBranch, status.RemoteSHA, syncNoVerify); err != nil {
+		return failed(fmt.Errorf("push failed: %w", err))
+	}
+	outcome.result, outcome.reason = "rebased", "onto "+upstream+" and pushed"
+	return outcome
+}
+
+// regenerateBranches runs the recipe again through apply, starting from the
+// latest base branch, and replaces the campaign branches with the result.
+// Like the rebase, it leaves repositories without the branch alone, and
+// skips branches that have commits on the push remote missing locally.
+func regenerateBranches(cmd *cobra.Command, args []string) error {
+	vars := varSpecs
+	ResetFlags()
+	flags := map[string]string{
+		"recipe": syncRecipe,
+		"branch": syncBranch,
+		"pull":   "true",
+		"push":   "true",
+		"update": updateRegenerate,
+	}
+	if syncBase != "" {
+		flags["base-branch"] = syncBase
+	}
+	if syncNoVerify {
+		flags["no-verify"] = "true"
+	}
+	// Remote flags set for sync take precedence over the recipe, like they
+	// do for apply
+	for _, name := range []string{"remote", "fork-url", "fork-remote"} {
+		if f := cmd.Flags().Lookup(name); f.Changed {
+			flags[name] = f.Value.String()
+		}
+	}
+	for name, value := range flags {
+		if err := applyCmd.Flags().Set(name, value); err != nil {
+			return fmt.Errorf("invalid --%s: %w", name, err)
+		}
+	}
+	for _, spec := range vars {
+		if err := applyCmd.Flags().Set("var", spec); err != nil {
+			return fmt.Errorf("invalid --var: %w", err)
+		}
+	}
+	if err := validateApply(applyCmd, args); err != nil {
+		return err
+	}
+	if baseBranch == "" {
+		return fmt.Errorf("--recipe requires --base-branch or a base_branch in the recipe, the branch is regenerated from it")
+	}
+	existingOnly = true
+	return runApply(applyCmd, args)
+}
@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vpukhanov/cascade/internal/git"
+)
+
+func TestRunSync(t *testing.T) {
+	resetMocks()
+	defer ResetSyncFlags()
+	syncBranch = "deps/{{.RepoName}}"
+
+	statuses := map[string]git.BranchStatus{
+		"repo1": {Local: true, Remote: true},
+		"repo2": {Local: true, Remote: true},
+		"repo3": {Remote: true},
+		"repo4": {},
+		"repo5": {Local: true, Dirty: true},
+		"repo6": {Local: true, Remote: true},
+		"repo7": {Local: true, Remote: true},
+	}
+	var calls []string
+	gitRefExists = func(repoPath, ref string) (bool, error) { return true, nil }
+	gitGetBranchStatus = func(repoPath, remote, branch, base string) (git.BranchStatus, error) {
+		status := statuses[repoPath]
+		status.Branch, status.Base = branch, "main"
+		return status, nil
+	}
+	gitIsAncestor = func(repoPath, ref, of string) (bool, error) {
+		calls = append(calls, fmt.Sprintf("is-ancestor %s %s %s", repoPath, ref, of))
+		// Someone pushed to the campaign branch of repo7
+		if strings.HasPrefix(ref, "refs/remotes/origin/") {
+			return repoPath != "repo7", nil
+		}
+		return repoPath == "repo2", nil
+	}
+	gitCheckoutExistingBranch = func(repoPath, branch string) error {
+		calls = append(calls, "checkout "+repoPath+" "+branch)
+		return nil
+	}
+	gitRebase = func(repoPath, upstream string) error {
+		calls = append(calls, "rebase "+repoPath+" "+upstream)
+		switch repoPath {
+		case "repo3":
+			return &git.ConflictError{Files: []string{"go.mod", "go.sum"}}
+		case "repo6":
+			return fmt.Errorf("error rebasing onto %s: exit status 1\nfatal: bad revision", upstream)
+		}
+		return nil
+	}
+	gitForcePushChanges = func(repoPath, remote, branch, expected string, noVerify bool) (string, error) {
+		calls = append(calls, "force-push "+repoPath+" "+branch)
+		return "", nil
+	}
+	gitPushChanges = func(repoPath, remote, branch string, noVerify bool) (string, error) {
+		t.Errorf("unexpected push without lease in %s", repoPath)
+		return "", nil
+	}
+
+	out := captureRunOutput(t, func() error {
+		return runSync(nil, []string{"repo1", "repo2", "repo3", "repo4", "repo5", "repo6", "repo7"})
+	})
+	rows := map[string]string{}
+	table, summary, _ := strings.Cut(out, "\n\n")
+	for _, line := range strings.Split(table, "\n")[1:] {
+		row := strings.Join(strings.Fields(line), " ")
+		rows[strings.Fields(row)[0]] = row
+	}
+	want := map[string]string{
+		"repo1": "repo1 deps/repo1 rebased onto origin/main and pushed",
+		"repo2": "repo2 deps/repo2 up to date already based on origin/main",
+		"repo3": "repo3 deps/repo3 conflict conflicts in go.mod, go.sum",
+		"repo4": "repo4 deps/repo4 skipped branch does not exist",
+		"repo5": "repo5 deps/repo5 skipped working tree has uncommitted changes",
+		"repo6": "repo6 deps/repo6 failed rebase failed: error rebasing onto origin/main: exit status 1",
+		"repo7": "repo7 deps/repo7 diverged origin/deps/repo7 has commits the local branch lacks",
+	}
+	for repo, row := range want {
+		if rows[repo] != row {
+			t.Errorf("expected row %q, got %q", row, rows[repo])
+		}
+	}
+	if !strings.Contains(summary, "1 repositories have conflicts") || !strings.Contains(summary, "repo3: rebase deps/repo3 onto origin/main") {
+		t.Errorf("expected the conflicting repository to be listed, got:\n%s", summary)
+	}
+
+	wantCalls := []string{
+		"is-ancestor repo1 refs/remotes/origin/deps/repo1 refs/heads/deps/repo1",
+		"is-ancestor repo1 origin/main refs/heads/deps/repo1",
+		"checkout repo1 deps/repo1", "rebase repo1 origin/main", "force-push repo1 deps/repo1", "checkout repo1 main",
+		"is-ancestor repo2 refs/remotes/origin/deps/repo2 refs/heads/deps/repo2",
+		"is-ancestor repo2 origin/main refs/heads/deps/repo2",
+		"is-ancestor repo3 origin/main refs/remotes/origin/deps/repo3",
+		"checkout repo3 deps/repo3", "rebase repo3 origin/main", "checkout repo3 main",
+		"is-ancestor repo6 refs/remotes/origin/deps/repo6 refs/heads/deps/repo6",
+		"is-ancestor repo6 origin/main refs/heads/deps/repo6",
+		"checkout repo6 deps/repo6", "rebase repo6 origin/main", "checkout repo6 main",
+		"is-ancestor repo7 refs/remotes/origin/deps/repo7 refs/heads/deps/repo7",
+	}
+	if !slices.Equal(calls, wantCalls) {
+		t.Errorf("expected calls:\n%s\ngot:\n%s", strings.Join(wantCalls, "\n"), strings.Join(calls, "\n"))
+	}
+}
+
+func TestRunSyncPushRemote(t *testing.T) {
+	resetMocks()
+	defer ResetSyncFlags()
+	var calls []string
+	// The branch is pushed to the remote git is configured with
+	gitPushRemote = func(repoPath, branch string) (string, error) { return "fork", nil }
+	gitFetch = func(repoPath, remote string) error {
+		calls = append(calls, "fetch "+remote)
+		return nil
+	}
+	gitRefExists = func(repoPath, ref string) (bool, error) { return true, nil }
+	gitGetBranchStatus = func(repoPath, remote, branch, base string) (git.BranchStatus, error) {
+		return git.BranchStatus{Branch: branch, Base: "main", Local: true, Remote: true}, nil
+	}
+	gitIsAncestor = func(repoPath, ref, of string) (bool, error) {
+		calls = append(calls, "is-ancestor "+ref)
+		return ref != "origin/main", nil
+	}
+	gitForcePushChanges = func(repoPath, remote, branch, expected string, noVerify bool) (string, error) {
+		calls = append(calls, "force-push "+remote)
+		return "", nil
+	}
+	syncBranch = "deps/upgrade"
+
+	captureRunOutput(t, func() error { return runSync(nil, []string{"repo1"}) })
+	want := []string{"fetch origin", "fetch fork", "is-ancestor refs/remotes/fork/deps/upgrade", "is-ancestor origin/main", "force-push fork"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("expected calls %v, got %v", want, calls)
+	}
+}
+
+func TestRunSyncForkRemote(t *testing.T) {
+	resetMocks()
+	defer ResetSyncFlags()
+	defer ResetRemoteFlags()
+	defer ResetFlags()
+	var added string
+	gitEnsureRemote = func(repoPath, name, url string) error {
+		added = name + " " + url
+		return nil
+	}
+	gitGetBranchStatus = func(repoPath, remote, branch, base string) (git.BranchStatus, error) {
+		return git.BranchStatus{Branch: branch, Base: "main"}, nil
+	}
+	syncBranch = "deps/upgrade"
+	syncBase = "develop"
+	varSpecs = []string{"owner=me"}
+	forkURL = "git@example.com:{{.Vars.owner}}/{{.RepoName}}-{{.BaseBranch}}.git"
+	if err := validateSync(nil, []string{"."}); err != nil {
+		t.Fatalf("validateSync failed: %v", err)
+	}
+
+	// The fork URL is rendered with the same data as apply renders it with
+	output := captureRunOutput(t, func() error { return runSync(nil, []string{"repo1"}) })
+	if added != "fork git@example.com:me/repo1-develop.git" {
+		t.Errorf("expected the fork to be added with the rendered URL, got %q:\n%s", added, output)
+	}
+}
+
+func TestRunSyncRegenerate(t *testing.T) {
+	resetMocks()
+	defer ResetFlags()
+	defer ResetSyncFlags()
+
+	repoPath := t.TempDir()
+	if output, err := exec.Command("git", "init", repoPath).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, output)
+	}
+	recipeFile := filepath.Join(t.TempDir(), "recipe.yaml")
+	recipeContent := "branch: ignored\nmessage: Upgrade logging\nsteps:\n  - command: make upgrade\n"
+	if err := os.WriteFile(recipeFile, []byte(recipeContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []string
+	// The campaign branch exists and has no commits missing locally, so it
+	// is replaced
+	exists, diverged := true, false
+	gitRefExists = func(repoPath, ref string) (bool, error) { return exists, nil }
+	gitIsAncestor = func(repoPath, ref, of string) (bool, error) { return !diverged, nil }
+	gitCheckoutExistingBranch = func(repoPath, branch string) error {
+		calls = append(calls, "checkout "+branch)
+		return nil
+	}
+	gitPullLatest = func(repoPath string) error {
+		calls = append(calls, "pull")
+		return nil
+	}
+	gitCheckoutBranch = func(repoPath, branch string) error {
+		calls = append(calls, "checkout -B "+branch)
+		return nil
+	}
+	gitForcePushChanges = func(repoPath, remote, branch, expected string, noVerify bool) (string, error) {
+		calls = append(calls, "force-push "+branch)
+		return "", nil
+	}
+	gitPushChanges = func(repoPath, remote, branch string, noVerify bool) (string, error) {
+		t.Error("unexpected push without lease")
+		return "", nil
+	}
+
+	syncBranch = "deps/upgrade"
+	syncRecipe = recipeFile
+	if err := validateSync(nil, []string{repoPath}); err != nil {
+		t.Fatalf("validateSync() unexpected error: %v", err)
+	}
+	if err := runSync(syncCmd, []string{repoPath}); err == nil || !strings.Contains(err.Error(), "requires --base-branch") {
+		t.Fatalf("expected missing base branch error, got %v", err)
+	}
+
+	ResetSyncFlags()
+	calls = nil
+	syncBranch = "deps/upgrade"
+	syncRecipe = recipeFile
+	syncBase = "main"
+	captureRunOutput(t, func() error { return runSync(syncCmd, []string{repoPath}) })
+
+	wantCalls := []string{"checkout main", "pull", "checkout -B deps/upgrade", "force-push deps/upgrade"}
+	if !slices.Equal(calls, wantCalls) {
+		t.Errorf("expected calls %v, got %v", wantCalls, calls)
+	}
+
+	// Repositories without the branch and diverged branches are left alone
+	for _, tt := range []struct {
+		exists, diverged bool
+		detail           string
+	}{
+		{false, false, "skipped, branch does not exist"},
+		{true, true, "skipped diverged branch, origin/deps/upgrade has commits the local branch lacks"},
+	} {
+		exists, diverged = tt.exists, tt.diverged
+		calls = nil
+		out := captureRunOutput(t, func() error { return runSync(syncCmd, []string{repoPath}) })
+		wantCalls := []string{"checkout main", "pull"}
+		if !slices.Equal(calls, wantCalls) {
+			t.Errorf("expected calls %v, got %v", wantCalls, calls)
+		}
+		if !strings.Contains(out, tt.detail) {
+			t.Errorf("expected %q in the output, got:\n%s", tt.detail, out)
+		}
+	}
+}
+
+func TestValidateSync(t *testing.T) {
+	defer ResetSyncFlags()
+
+	ResetSyncFlags()
+	if err := validateSync(nil, nil); err == nil || !strings.Contains(err.Error(), "--branch is required") {
+		t.Errorf("expected missing branch error, got %v", err)
+	}
+
+	syncRecipe = "recipe.yaml"
+	if err := validateSync(nil, nil); err == nil || !strings.Contains(err.Error(), "--branch is required") {
+		t.Errorf("expected missing branch error with a recipe, got %v", err)
+	}
+
+	syncBranch = "deps/{{.RepoName}}"
+	syncBase = "bad..name"
+	if err := validateSync(nil, nil); err == nil || !strings.Contains(err.Error(), "invalid base branch name") {
+		t.Errorf("expected invalid base branch error, got %v", err)
+	}
+}
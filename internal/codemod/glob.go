@@ -0,0 +1,85 @@
+package codemod
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Glob returns the regular files in root matching any of the patterns, as
+// sorted slash-separated paths relative to root. `*` matches within a path
+// segment and `**` matches any number of directories. The .git directory is
+// skipped.
+func Glob(root string, patterns []string) ([]string, error) {
+	globs := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		glob, err := compileGlob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		globs = append(globs, glob)
+	}
+
+	var matches []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		for _, glob := range globs {
+			if glob.MatchString(rel) {
+				matches = append(matches, rel)
+				break
+			}
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// compileGlob converts a slash-separated glob pattern to a regular expression.
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if strings.HasPrefix(pattern[i:], "**/") {
+				b.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(pattern[i:], "**") {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid file pattern %q: %w", pattern, err)
+	}
+	return re, nil
+}
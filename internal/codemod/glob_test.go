@@ -0,0 +1,48 @@
+package codemod
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGlob(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "main.go", "")
+	writeFile(t, root, "pkg/util.go", "")
+	writeFile(t, root, "docs/a.md", "")
+	writeFile(t, root, ".git/hooks.go", "")
+
+	got, err := Glob(root, []string{"**/*.go", "docs/*.md"})
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	want := []string{"docs/a.md", "main.go", "pkg/util.go"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Glob() = %v, want %v", got, want)
+	}
+}
+
+func TestCompileGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "pkg/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "pkg/sub/main.go", true},
+		{"pkg/**", "pkg/sub/main.go", true},
+		{"docs/?.md", "docs/a.md", true},
+		{"docs/?.md", "docs/ab.md", false},
+	}
+	for _, tt := range tests {
+		re, err := compileGlob(tt.pattern)
+		if err != nil {
+			t.Fatalf("compileGlob(%q) error: %v", tt.pattern, err)
+		}
+		if got := re.MatchString(tt.path); got != tt.want {
+			t.Errorf("glob %q match %q = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package codemod
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// GoOptions describes the semantic rewrites applied to Go source files.
+type GoOptions struct {
+	// Rewrites are `gofmt -r` style rules in the `pattern -> replacement` form.
+	Rewrites []string `yaml:"rewrite"`
+	// RenameImports are `old=new` import path renames. Subpackages of old
+	// are renamed as well.
+	RenameImports []string `yaml:"rename_import"`
+	// AddImports are import paths added to files that reference the package.
+	AddImports []string `yaml:"add_import"`
+	// RemoveImports are import paths removed from files that no longer use them.
+	RemoveImports []string `yaml:"remove_import"`
+}
+
+// IsZero reports whether no Go rewrites are configured.
+func (o GoOptions) IsZero() bool {
+	return len(o.Rewrites) == 0 && len(o.RenameImports) == 0 &&
+		len(o.AddImports) == 0 && len(o.RemoveImports) == 0
+}
+
+// Validate checks that all rules and import specs are well-formed.
+func (o GoOptions) Validate() error {
+	_, err := o.compile()
+	return err
+}
+
+type importRename struct {
+	from string
+	to   string
+}
+
+type goProgram struct {
+	rules   []rewriteRule
+	renames []importRename
+	add     []string
+	remove  []string
+}
+
+func (o GoOptions) compile() (goProgram, error) {
+	var prog goProgram
+	for _, rule := range o.Rewrites {
+		r, err := parseRewriteRule(rule)
+		if err != nil {
+			return goProgram{}, err
+		}
+		prog.rules = append(prog.rules, r)
+	}
+	for _, spec := range o.RenameImports {
+		from, to, ok := strings.Cut(spec, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return goProgram{}, fmt.Errorf("import rename must be of the form 'old=new': %q", spec)
+		}
+		prog.renames = append(prog.renames, importRename{from: from, to: to})
+	}
+	for _, path := range o.AddImports {
+		if strings.TrimSpace(path) == "" {
+			return goProgram{}, fmt.Errorf("import path to add cannot be empty")
+		}
+		prog.add = append(prog.add, strings.TrimSpace(path))
+	}
+	for _, path := range o.RemoveImports {
+		if strings.TrimSpace(path) == "" {
+			return goProgram{}, fmt.Errorf("import path to remove cannot be empty")
+		}
+		prog.remove = append(prog.remove, strings.TrimSpace(path))
+	}
+	return prog, nil
+}
+
+// ApplyGo applies the configured rewrites to every Go file in the repository
+// and reformats the files it changes. It returns the number of edited files.
+// Hidden directories, vendor and testdata are skipped, like the go tool does.
+func ApplyGo(repoPath string, opts GoOptions) (int, error) {
+	prog, err := opts.compile()
+	if err != nil {
+		return 0, err
+	}
+
+	edited := 0
+	err = filepath.WalkDir(repoPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != repoPath && (strings.HasPrefix(name, ".") || name == "vendor" || name == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || filepath.Ext(path) != ".go" {
+			return nil
+		}
+
+		changed, err := prog.rewriteFile(path)
+		if err != nil {
+			rel, _ := filepath.Rel(repoPath, path)
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		if changed {
+			edited++
+		}
+		return nil
+	})
+	if err != nil {
+		return edited, fmt.Errorf("error rewriting Go files: %w", err)
+	}
+	return edited, nil
+}
+
+func (p goProgram) rewriteFile(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return false, err
+	}
+	original, err := formatFile(fset, file)
+	if err != nil {
+		return false, err
+	}
+
+	for _, rule := range p.rules {
+		file = rule.apply(fset, file)
+	}
+	for _, rename := range p.renames {
+		renameImport(file, rename)
+	}
+
+	out, err := formatFile(fset, file)
+	if err != nil {
+		return false, err
+	}
+
+	if len(p.add) > 0 || len(p.remove) > 0 {
+		// Reparse so identifier resolution reflects the rewritten code.
+		fset = token.NewFileSet()
+		file, err = parser.ParseFile(fset, path, out, parser.ParseComments)
+		if err != nil {
+			return false, fmt.Errorf("rewritten file does not parse: %w", err)
+		}
+		for _, importPath := range p.add {
+			if referencesPackage(file, importName(importPath)) && !hasImport(file, importPath) {
+				astutil.AddImport(fset, file, importPath)
+			}
+		}
+		for _, importPath := range p.remove {
+			if hasImport(file, importPath) && !astutil.UsesImport(file, importPath) {
+				astutil.DeleteImport(fset, file, importPath)
+			}
+		}
+		out, err = formatFile(fset, file)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if bytes.Equal(out, original) {
+		return false, nil
+	}
+	if err := os.WriteFile(path, out, info.Mode().Perm()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func formatFile(fset *token.FileSet, file *ast.File) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		return nil, fmt.Errorf("format: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// renameImport rewrites imports of rename.from and its subpackages. When the
+// package name implied by the path changes, the old name is kept as an
+// explicit import name so existing references continue to compile.
+func renameImport(file *ast.File, rename importRename) {
+	for _, imp := range file.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		if path != rename.from && !strings.HasPrefix(path, rename.from+"/") {
+			continue
+		}
+
+		newPath := rename.to + strings.TrimPrefix(path, rename.from)
+		if imp.Name == nil && importName(path) != importName(newPath) {
+			imp.Name = ast.NewIdent(importName(path))
+		}
+		imp.Path.Value = strconv.Quote(newPath)
+	}
+}
+
+func hasImport(file *ast.File, path string) bool {
+	for _, imp := range file.Imports {
+		if p, err := strconv.Unquote(imp.Path.Value); err == nil && p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// referencesPackage reports whether the file uses name as an unresolved
+// package qualifier, such as `name.Func`.
+func referencesPackage(file *ast.File, name string) bool {
+	for _, imp := range file.Imports {
+		if imp.Name != nil && imp.Name.Name == name {
+			return false
+		}
+		if p, err := strconv.Unquote(imp.Path.Value); err == nil && imp.Name == nil && importName(p) == name {
+			return false
+		}
+	}
+
+	found := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok || found {
+			return !found
+		}
+		if id, ok := sel.X.(*ast.Ident); ok && id.Name == name && id.Obj == nil {
+			found = true
+		}
+		return true
+	})
+	return found
+}
+
+var majorVersionPattern = regexp.MustCompile(`^v[0-9]+$`)
+
+// importName returns the package name conventionally implied by an import
+// path, ignoring major version suffixes like `/v2` and `.v3`.
+func importName(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if majorVersionPattern.MatchString(name) && len(elems) > 1 {
+		name = elems[len(elems)-2]
+	}
+	if i := strings.Index(name, ".v"); i > 0 && majorVersionPattern.MatchString(name[i+1:]) {
+		name = name[:i]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if i := strings.IndexAny(name, ".-"); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
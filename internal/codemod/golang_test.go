@@ -0,0 +1,133 @@
+package codemod
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyGo(t *testing.T) {
+	t.Run("rewrite and fix imports", func(t *testing.T) {
+		repoPath := t.TempDir()
+		writeFile(t, repoPath, "main.go", `package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	data, _ := ioutil.ReadAll(os.Stdin)
+	fmt.Println(len(data))
+}
+`)
+		writeFile(t, repoPath, "untouched.go", "package main\n\nfunc helper() int { return 1 }\n")
+
+		edited, err := ApplyGo(repoPath, GoOptions{
+			Rewrites:      []string{"ioutil.ReadAll(r) -> io.ReadAll(r)"},
+			AddImports:    []string{"io"},
+			RemoveImports: []string{"io/ioutil"},
+		})
+		if err != nil {
+			t.Fatalf("ApplyGo failed: %v", err)
+		}
+		if edited != 1 {
+			t.Errorf("Expected 1 edited file, got %d", edited)
+		}
+
+		got := readFile(t, repoPath, "main.go")
+		for _, want := range []string{`"io"`, "io.ReadAll(os.Stdin)"} {
+			if !strings.Contains(got, want) {
+				t.Errorf("Expected output to contain %q, got:\n%s", want, got)
+			}
+		}
+		if strings.Contains(got, "ioutil") {
+			t.Errorf("Expected ioutil to be removed, got:\n%s", got)
+		}
+		if readFile(t, repoPath, "untouched.go") != "package main\n\nfunc helper() int { return 1 }\n" {
+			t.Errorf("Expected file without matches to stay unformatted")
+		}
+	})
+
+	t.Run("rename import keeps package name", func(t *testing.T) {
+		repoPath := t.TempDir()
+		writeFile(t, repoPath, "pkg/a.go", `package pkg
+
+import "github.com/old/logging/v2/sub"
+
+var _ = sub.Value
+`)
+		writeFile(t, repoPath, "vendor/b.go", `package vendored
+
+import "github.com/old/logging/v2/sub"
+`)
+
+		edited, err := ApplyGo(repoPath, GoOptions{
+			RenameImports: []string{"github.com/old/logging/v2=github.com/new/logging/v3"},
+		})
+		if err != nil {
+			t.Fatalf("ApplyGo failed: %v", err)
+		}
+		if edited != 1 {
+			t.Errorf("Expected 1 edited file, got %d", edited)
+		}
+		if got := readFile(t, repoPath, "pkg/a.go"); !strings.Contains(got, `"github.com/new/logging/v3/sub"`) {
+			t.Errorf("Expected import to be renamed, got:\n%s", got)
+		}
+		if got := readFile(t, repoPath, "vendor/b.go"); !strings.Contains(got, "github.com/old/logging") {
+			t.Errorf("Expected vendor directory to be skipped, got:\n%s", got)
+		}
+	})
+
+	t.Run("invalid rule", func(t *testing.T) {
+		if _, err := ApplyGo(t.TempDir(), GoOptions{Rewrites: []string{"a +"}}); err == nil {
+			t.Error("Expected error for malformed rewrite rule")
+		}
+	})
+
+	t.Run("unparsable file", func(t *testing.T) {
+		repoPath := t.TempDir()
+		writeFile(t, repoPath, "broken.go", "package main\nfunc {")
+		if _, err := ApplyGo(repoPath, GoOptions{Rewrites: []string{"a -> a"}}); err == nil {
+			t.Error("Expected error for unparsable Go file")
+		}
+	})
+}
+
+func TestImportName(t *testing.T) {
+	tests := map[string]string{
+		"io":                           "io",
+		"github.com/spf13/cobra":       "cobra",
+		"github.com/old/logging/v2":    "logging",
+		"gopkg.in/yaml.v3":             "yaml",
+		"github.com/mattn/go-isatty":   "isatty",
+		"github.com/pelletier/go-toml": "toml",
+	}
+	for path, want := range tests {
+		if got := importName(path); got != want {
+			t.Errorf("importName(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func writeFile(t *testing.T, root, name, content string) {
+	t.Helper()
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, root, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(root, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
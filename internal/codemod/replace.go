@@ -0,0 +1,79 @@
+package codemod
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// ReplaceOptions describes a regular expression replacement across files.
+type ReplaceOptions struct {
+	// Files are glob patterns relative to the repository root. `*` matches
+	// within a path segment and `**` matches any number of directories.
+	Files []string `yaml:"files"`
+	// Pattern is a regular expression in RE2 syntax.
+	Pattern string `yaml:"pattern"`
+	// With is the replacement text, which may reference groups as `$1`.
+	With string `yaml:"with"`
+}
+
+// Validate checks that the options are complete and the patterns compile.
+func (o ReplaceOptions) Validate() error {
+	_, err := o.compile()
+	return err
+}
+
+func (o ReplaceOptions) compile() (*regexp.Regexp, error) {
+	if len(o.Files) == 0 {
+		return nil, fmt.Errorf("replace requires at least one file pattern")
+	}
+	if o.Pattern == "" {
+		return nil, fmt.Errorf("replace requires a pattern")
+	}
+	re, err := regexp.Compile(o.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid replace pattern: %w", err)
+	}
+	for _, pattern := range o.Files {
+		if _, err := compileGlob(pattern); err != nil {
+			return nil, err
+		}
+	}
+	return re, nil
+}
+
+// Replace applies the replacement to every matching file in the repository
+// and returns the number of edited files. The .git directory is skipped.
+func Replace(repoPath string, opts ReplaceOptions) (int, error) {
+	re, err := opts.compile()
+	if err != nil {
+		return 0, err
+	}
+	files, err := Glob(repoPath, opts.Files)
+	if err != nil {
+		return 0, fmt.Errorf("error replacing in files: %w", err)
+	}
+
+	edited := 0
+	for _, file := range files {
+		path := filepath.Join(repoPath, file)
+		info, err := os.Stat(path)
+		if err != nil {
+			return edited, fmt.Errorf("error replacing in files: %w", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return edited, fmt.Errorf("error replacing in files: %w", err)
+		}
+		out := re.ReplaceAll(data, []byte(opts.With))
+		if string(out) == string(data) {
+			continue
+		}
+		if err := os.WriteFile(path, out, info.Mode().Perm()); err != nil {
+			return edited, fmt.Errorf("error replacing in files: %w", err)
+		}
+		edited++
+	}
+	return edited, nil
+}
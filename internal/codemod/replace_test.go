@@ -0,0 +1,42 @@
+package codemod
+
+import "testing"
+
+func TestReplace(t *testing.T) {
+	repoPath := t.TempDir()
+	writeFile(t, repoPath, "main.go", "log.Printf(\"a\")\n")
+	writeFile(t, repoPath, "pkg/sub/util.go", "log.Printf(\"b\")\nlog.Println(\"c\")\n")
+	writeFile(t, repoPath, "README.md", "log.Printf is used\n")
+	writeFile(t, repoPath, ".git/config.go", "log.Printf(\"git\")\n")
+
+	edited, err := Replace(repoPath, ReplaceOptions{
+		Files:   []string{"**/*.go"},
+		Pattern: `log\.Print(f|ln)`,
+		With:    "slog.Info$1",
+	})
+	if err != nil {
+		t.Fatalf("Replace failed: %v", err)
+	}
+	if edited != 2 {
+		t.Errorf("Expected 2 edited files, got %d", edited)
+	}
+
+	if got := readFile(t, repoPath, "pkg/sub/util.go"); got != "slog.Infof(\"b\")\nslog.Infoln(\"c\")\n" {
+		t.Errorf("Unexpected replacement result: %q", got)
+	}
+	if got := readFile(t, repoPath, "README.md"); got != "log.Printf is used\n" {
+		t.Errorf("Expected non-matching file to be unchanged, got %q", got)
+	}
+	if got := readFile(t, repoPath, ".git/config.go"); got != "log.Printf(\"git\")\n" {
+		t.Errorf("Expected .git directory to be skipped, got %q", got)
+	}
+}
+
+func TestReplaceOptionsValidate(t *testing.T) {
+	if err := (ReplaceOptions{Pattern: "a"}).Validate(); err == nil {
+		t.Error("Expected error without file patterns")
+	}
+	if err := (ReplaceOptions{Files: []string{"*"}}).Validate(); err == nil {
+		t.Error("Expected error without pattern")
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the NOTICE file.
+
+// Adapted from cmd/gofmt/rewrite.go in the Go distribution.
+
+package codemod
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The rewrite engine below follows the semantics of `gofmt -r`: single
+// lowercase-letter identifiers in the pattern act as wildcards that match
+// any expression and are substituted into the replacement.
+
+// rewriteRule is a parsed `pattern -> replacement` rule.
+type rewriteRule struct {
+	pattern ast.Expr
+	replace ast.Expr
+}
+
+// parseRewriteRule parses a rule in the `pattern -> replacement` form.
+func parseRewriteRule(rule string) (rewriteRule, error) {
+	f := strings.Split(rule, "->")
+	if len(f) != 2 {
+		return rewriteRule{}, fmt.Errorf("rewrite rule must be of the form 'pattern -> replacement': %q", rule)
+	}
+
+	pattern, err := parser.ParseExpr(strings.TrimSpace(f[0]))
+	if err != nil {
+		return rewriteRule{}, fmt.Errorf("parsing pattern %q: %w", strings.TrimSpace(f[0]), err)
+	}
+	replace, err := parser.ParseExpr(strings.TrimSpace(f[1]))
+	if err != nil {
+		return rewriteRule{}, fmt.Errorf("parsing replacement %q: %w", strings.TrimSpace(f[1]), err)
+	}
+
+	return rewriteRule{pattern: pattern, replace: replace}, nil
+}
+
+// apply rewrites all matches of the rule in file and returns the result.
+func (r rewriteRule) apply(fset *token.FileSet, file *ast.File) *ast.File {
+	cmap := ast.NewCommentMap(fset, file, file.Comments)
+	m := make(map[string]reflect.Value)
+	pat := reflect.ValueOf(r.pattern)
+	repl := reflect.ValueOf(r.replace)
+
+	var rewriteVal func(val reflect.Value) reflect.Value
+	rewriteVal = func(val reflect.Value) reflect.Value {
+		if !val.IsValid() {
+			return reflect.Value{}
+		}
+		val = applyEach(rewriteVal, val)
+		clear(m)
+		if match(m, pat, val) {
+			val = subst(m, repl, reflect.ValueOf(val.Interface().(ast.Node).Pos()))
+		}
+		return val
+	}
+
+	result := applyEach(rewriteVal, reflect.ValueOf(file)).Interface().(*ast.File)
+	result.Comments = cmap.Filter(result).Comments()
+	return result
+}
+
+// set is a wrapper for x.Set(y) that ignores rewrites producing values of
+// the wrong type for their position in the tree.
+func set(x, y reflect.Value) {
+	if !x.CanSet() || !y.IsValid() {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if s, ok := r.(string); ok &&
+				(strings.Contains(s, "type mismatch") || strings.Contains(s, "not assignable")) {
+				return
+			}
+			panic(r)
+		}
+	}()
+	x.Set(y)
+}
+
+var (
+	objectPtrNil = reflect.ValueOf((*ast.Object)(nil))
+	scopePtrNil  = reflect.ValueOf((*ast.Scope)(nil))
+
+	identType     = reflect.TypeFor[*ast.Ident]()
+	objectPtrType = reflect.TypeFor[*ast.Object]()
+	positionType  = reflect.TypeFor[token.Pos]()
+	callExprType  = reflect.TypeFor[*ast.CallExpr]()
+	scopePtrType  = reflect.TypeFor[*ast.Scope]()
+)
+
+// applyEach replaces each AST field x in val with f(x), returning val.
+func applyEach(f func(reflect.Value) reflect.Value, val reflect.Value) reflect.Value {
+	if !val.IsValid() {
+		return reflect.Value{}
+	}
+
+	// Objects and scopes introduce cycles and are stale after a rewrite,
+	// so drop them instead of following them.
+	if val.Type() == objectPtrType {
+		return objectPtrNil
+	}
+	if val.Type() == scopePtrType {
+		return scopePtrNil
+	}
+
+	switch v := reflect.Indirect(val); v.Kind() {
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			e := v.Index(i)
+			set(e, f(e))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			e := v.Field(i)
+			set(e, f(e))
+		}
+	case reflect.Interface:
+		e := v.Elem()
+		set(v, f(e))
+	}
+	return val
+}
+
+func isWildcard(s string) bool {
+	r, size := utf8.DecodeRuneInString(s)
+	return size == len(s) && unicode.IsLower(r)
+}
+
+// match reports whether pattern matches val, recording wildcard submatches
+// in m. If m is nil, match checks whether pattern equals val.
+func match(m map[string]reflect.Value, pattern, val reflect.Value) bool {
+	if m != nil && pattern.IsValid() && pattern.Type() == identType {
+		name := pattern.Interface().(*ast.Ident).Name
+		if isWildcard(name) && val.IsValid() {
+			if _, ok := val.Interface().(ast.Expr); ok && !val.IsNil() {
+				if old, ok := m[name]; ok {
+					return match(nil, old, val)
+				}
+				m[name] = val
+				return true
+			}
+		}
+	}
+
+	if !pattern.IsValid() || !val.IsValid() {
+		return !pattern.IsValid() && !val.IsValid()
+	}
+	if pattern.Type() != val.Type() {
+		return false
+	}
+
+	switch pattern.Type() {
+	case identType:
+		p := pattern.Interface().(*ast.Ident)
+		v := val.Interface().(*ast.Ident)
+		return p == nil && v == nil || p != nil && v != nil && p.Name == v.Name
+	case objectPtrType, positionType:
+		return true
+	case callExprType:
+		// f(x) and f(x...) differ only in the Ellipsis position.
+		p := pattern.Interface().(*ast.CallExpr)
+		v := val.Interface().(*ast.CallExpr)
+		if p.Ellipsis.IsValid() != v.Ellipsis.IsValid() {
+			return false
+		}
+	}
+
+	p := reflect.Indirect(pattern)
+	v := reflect.Indirect(val)
+	if !p.IsValid() || !v.IsValid() {
+		return !p.IsValid() && !v.IsValid()
+	}
+
+	switch p.Kind() {
+	case reflect.Slice:
+		if p.Len() != v.Len() {
+			return false
+		}
+		for i := 0; i < p.Len(); i++ {
+			if !match(m, p.Index(i), v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < p.NumField(); i++ {
+			if !match(m, p.Field(i), v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Interface:
+		return match(m, p.Elem(), v.Elem())
+	}
+
+	return p.Interface() == v.Interface()
+}
+
+// subst returns a copy of pattern with wildcards replaced by their matches
+// from m and token positions replaced by pos.
+func subst(m map[string]reflect.Value, pattern reflect.Value, pos reflect.Value) reflect.Value {
+	if !pattern.IsValid() {
+		return reflect.Value{}
+	}
+
+	if m != nil && pattern.Type() == identType {
+		name := pattern.Interface().(*ast.Ident).Name
+		if isWildcard(name) {
+			if old, ok := m[name]; ok {
+				return subst(nil, old, reflect.Value{})
+			}
+		}
+	}
+
+	if pos.IsValid() && pattern.Type() == positionType {
+		if old := pattern.Interface().(token.Pos); !old.IsValid() {
+			return pattern
+		}
+		return pos
+	}
+
+	switch p := pattern; p.Kind() {
+	case reflect.Slice:
+		if p.IsNil() {
+			// go/ast relies on some lists staying nil when unpopulated.
+			return reflect.Zero(p.Type())
+		}
+		v := reflect.MakeSlice(p.Type(), p.Len(), p.Len())
+		for i := 0; i < p.Len(); i++ {
+			v.Index(i).Set(subst(m, p.Index(i), pos))
+		}
+		return v
+	case reflect.Struct:
+		v := reflect.New(p.Type()).Elem()
+		for i := 0; i < p.NumField(); i++ {
+			v.Field(i).Set(subst(m, p.Field(i), pos))
+		}
+		return v
+	case reflect.Pointer:
+		v := reflect.New(p.Type()).Elem()
+		if elem := p.Elem(); elem.IsValid() {
+			v.Set(subst(m, elem, pos).Addr())
+		}
+		return v
+	case reflect.Interface:
+		v := reflect.New(p.Type()).Elem()
+		if elem := p.Elem(); elem.IsValid() {
+			v.Set(subst(m, elem, pos))
+		}
+		return v
+	}
+
+	return pattern
+}
@@ -0,0 +1,149 @@
+package forge
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// Styles of the pages that open a pull request, named after the code hosts
+// that use them.
+const (
+	StyleGitHub    = "github"
+	StyleGitLab    = "gitlab"
+	StyleGitea     = "gitea"
+	StyleBitbucket = "bitbucket"
+)
+
+// URLData is the data pull request URL templates are rendered with.
+type URLData struct {
+	// Host, Path, Owner and Name describe the repository the pull request
+	// is opened on.
+	Host  string
+	Path  string
+	Owner string
+	Name  string
+	// HeadPath and HeadOwner describe the repository the branch is pushed
+	// to, which is a fork or the repository itself.
+	HeadPath  string
+	HeadOwner string
+	Branch    string
+	Base      string
+}
+
+// ValidateURLFormat checks that the format is empty, a style or a template
+// that parses.
+func ValidateURLFormat(format string) error {
+	if format == "" || isStyle(format) {
+		return nil
+	}
+	if _, err := template.New("pull request URL").Parse(format); err != nil {
+		return fmt.Errorf("invalid pull request URL template: %w", err)
+	}
+	return nil
+}
+
+// PullRequestURL returns the URL of the page that opens a pull request from
+// the branch of head into the base branch of repo. The format is a style, a
+// template rendered with URLData, or empty to pick the style from the host
+// name. Hosts that are not recognized return an error.
+func PullRequestURL(format string, repo Remote, head Remote, branch string, base string) (string, error) {
+	style := format
+	if style == "" {
+		style = detectStyle(repo.Host)
+		if style == "" {
+			return "", fmt.Errorf("cannot build a pull request URL for %s, set a URL template", repo.Host)
+		}
+	}
+	if !isStyle(style) {
+		return renderURL(format, repo, head, branch, base)
+	}
+
+	fork := head.Path != repo.Path
+	switch style {
+	case StyleGitHub:
+		return compareURL(repo, head, branch, base) + "?expand=1", nil
+	case StyleGitea:
+		return compareURL(repo, head, branch, base), nil
+	case StyleGitLab:
+		// Merge requests are opened from the source project, which targets
+		// the project it was forked from by default
+		query := url.Values{"merge_request[source_branch]": {branch}, "merge_request[target_branch]": {base}}
+		return fmt.Sprintf("https://%s/%s/-/merge_requests/new?%s", repo.Host, head.Path, query.Encode()), nil
+	default:
+		dest := base
+		if fork {
+			dest = repo.Path + "::" + base
+		}
+		query := url.Values{"source": {branch}, "dest": {dest}}
+		return fmt.Sprintf("https://%s/%s/pull-requests/new?%s", repo.Host, head.Path, query.Encode()), nil
+	}
+}
+
+// compareURL returns the compare page of GitHub and Gitea, which name the
+// branch of a fork by its owner.
+func compareURL(repo Remote, head Remote, branch string, base string) string {
+	headRef := branch
+	if head.Path != repo.Path {
+		headRef = head.Owner() + ":" + branch
+	}
+	return fmt.Sprintf("https://%s/%s/compare/%s...%s", repo.Host, repo.Path, escapePath(base), escapePath(headRef))
+}
+
+func isStyle(format string) bool {
+	switch format {
+	case StyleGitHub, StyleGitLab, StyleGitea, StyleBitbucket:
+		return true
+	}
+	return false
+}
+
+// detectStyle returns the style of the code host from its name, or an
+// empty string.
+func detectStyle(host string) string {
+	switch {
+	case host == "github.com" || strings.HasPrefix(host, "github."):
+		return StyleGitHub
+	case strings.Contains(host, "gitlab"):
+		return StyleGitLab
+	case host == "codeberg.org" || strings.Contains(host, "gitea") || strings.Contains(host, "forgejo"):
+		return StyleGitea
+	case strings.Contains(host, "bitbucket"):
+		return StyleBitbucket
+	}
+	return ""
+}
+
+func renderURL(format string, repo Remote, head Remote, branch string, base string) (string, error) {
+	t, err := template.New("pull request URL").Parse(format)
+	if err != nil {
+		return "", fmt.Errorf("invalid pull request URL template: %w", err)
+	}
+	data := URLData{
+		Host:      repo.Host,
+		Path:      repo.Path,
+		Owner:     repo.Owner(),
+		Name:      path.Base(repo.Path),
+		HeadPath:  head.Path,
+		HeadOwner: head.Owner(),
+		Branch:    branch,
+		Base:      base,
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("error rendering pull request URL: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// escapePath escapes a branch name for a URL path, keeping its slashes.
+func escapePath(branch string) string {
+	segments := strings.Split(branch, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,65 @@
+package forge
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPullRequestURL(t *testing.T) {
+	github := Remote{Host: "github.com", Path: "org/repo"}
+	githubFork := Remote{Host: "github.com", Path: "me/repo"}
+	gitlab := Remote{Host: "gitlab.com", Path: "group/sub/repo"}
+	gitea := Remote{Host: "codeberg.org", Path: "org/repo"}
+	bitbucket := Remote{Host: "bitbucket.org", Path: "team/repo"}
+	selfHosted := Remote{Host: "git.example.com", Path: "org/repo"}
+
+	tests := []struct {
+		name   string
+		format string
+		repo   Remote
+		head   Remote
+		want   string
+	}{
+		{"github", "", github, github, "https://github.com/org/repo/compare/main...deps/bump?expand=1"},
+		{"github fork", "", github, githubFork, "https://github.com/org/repo/compare/main...me:deps/bump?expand=1"},
+		{"gitlab", "", gitlab, gitlab, "https://gitlab.com/group/sub/repo/-/merge_requests/new?merge_request%5Bsource_branch%5D=deps%2Fbump&merge_request%5Btarget_branch%5D=main"},
+		{"gitea", "", gitea, gitea, "https://codeberg.org/org/repo/compare/main...deps/bump"},
+		{"bitbucket", "", bitbucket, bitbucket, "https://bitbucket.org/team/repo/pull-requests/new?dest=main&source=deps%2Fbump"},
+		{"self-hosted style", "gitlab", selfHosted, selfHosted, "https://git.example.com/org/repo/-/merge_requests/new?merge_request%5Bsource_branch%5D=deps%2Fbump&merge_request%5Btarget_branch%5D=main"},
+		{
+			"template",
+			"https://{{.Host}}/projects/{{.Owner}}/repos/{{.Name}}/pull-requests?create&sourceBranch={{urlquery .Branch}}&targetBranch={{.Base}}",
+			selfHosted, selfHosted,
+			"https://git.example.com/projects/org/repos/repo/pull-requests?create&sourceBranch=deps%2Fbump&targetBranch=main",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PullRequestURL(tt.format, tt.repo, tt.head, "deps/bump", "main")
+			if err != nil {
+				t.Fatalf("PullRequestURL() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("PullRequestURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := PullRequestURL("", selfHosted, selfHosted, "deps/bump", "main"); err == nil || !strings.Contains(err.Error(), "set a URL template") {
+		t.Errorf("expected an error for an unknown host, got %v", err)
+	}
+	if _, err := PullRequestURL("https://{{.Host}}/{{.Project}}", selfHosted, selfHosted, "deps/bump", "main"); err == nil {
+		t.Error("expected an error for an unknown template field")
+	}
+}
+
+func TestValidateURLFormat(t *testing.T) {
+	for _, format := range []string{"", "github", "bitbucket", "https://{{.Host}}/{{.Path}}/compare/{{.Base}}...{{.Branch}}"} {
+		if err := ValidateURLFormat(format); err != nil {
+			t.Errorf("ValidateURLFormat(%q) error: %v", format, err)
+		}
+	}
+	if err := ValidateURLFormat("https://{{.Host}/"); err == nil {
+		t.Error("expected an error for a template that does not parse")
+	}
+}
@@ -0,0 +1,283 @@
+// Package forge talks to code hosting APIs, like GitHub and GitLab, to find
+// and report on the pull requests of a campaign.
+package forge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Kind identifies a code host API.
+type Kind string
+
+const (
+	GitHub Kind = "github"
+	GitLab Kind = "gitlab"
+)
+
+// ErrNotFound is returned when a branch has no pull request.
+var ErrNotFound = errors.New("no pull request found")
+
+// Pull request states.
+const (
+	StateOpen   = "open"
+	StateMerged = "merged"
+	StateClosed = "closed"
+)
+
+// Review states.
+const (
+	ReviewApproved         = "approved"
+	ReviewChangesRequested = "changes_requested"
+	ReviewPending          = "pending"
+)
+
+// Check states, summarizing all CI checks of the head commit.
+const (
+	ChecksSuccess = "success"
+	ChecksFailure = "failure"
+	ChecksPending = "pending"
+	ChecksNone    = "none"
+)
+
+// Mergeable states.
+const (
+	MergeableClean    = "clean"
+	MergeableConflict = "conflict"
+	MergeableUnknown  = "unknown"
+)
+
+// PullRequest is a pull request, or a GitLab merge request, with its review
+// and CI state.
+type PullRequest struct {
+	Number    int    `json:"number"`
+	URL       string `json:"url"`
+	Title     string `json:"title"`
+	State     string `json:"state"`
+	Draft     bool   `json:"draft"`
+	HeadSHA   string `json:"head_sha"`
+	Review    string `json:"review"`
+	Approvals int    `json:"approvals"`
+	Checks    string `json:"checks"`
+	// FailedChecks are the names of the checks that failed.
+	FailedChecks []string `json:"failed_checks,omitempty"`
+	Mergeable    string   `json:"mergeable"`
+}
+
+// Forge finds and merges pull requests on a code host.
+type Forge interface {
+	// FindPullRequest returns the most recent pull request from the branch,
+	// or ErrNotFound.
+	FindPullRequest(ctx context.Context, branch string) (*PullRequest, error)
+	// Merge merges the pull request, unless its head moved since it was
+	// found.
+	Merge(ctx context.Context, pr *PullRequest, method MergeMethod) error
+}
+
+// Remote is a repository on a code host.
+type Remote struct {
+	Host string
+	// Path is the repository path on the host, like owner/name or
+	// group/subgroup/name.
+	Path string
+}
+
+// Owner returns the first segment of the path.
+func (r Remote) Owner() string {
+	owner, _, _ := strings.Cut(r.Path, "/")
+	return owner
+}
+
+var scpLikeURL = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+// ParseRemote parses a git remote URL in the https://, ssh:// or scp-like
+// user@host:path form.
+func ParseRemote(remoteURL string) (Remote, error) {
+	var remote Remote
+	if strings.Contains(remoteURL, "://") {
+		u, err := url.Parse(remoteURL)
+		if err != nil {
+			return remote, fmt.Errorf("invalid remote URL %q: %w", remoteURL, err)
+		}
+		remote = Remote{Host: u.Hostname(), Path: u.Path}
+	} else if m := scpLikeURL.FindStringSubmatch(remoteURL); m != nil {
+		remote = Remote{Host: m[1], Path: m[2]}
+	}
+
+	remote.Path = strings.TrimSuffix(strings.Trim(remote.Path, "/"), ".git")
+	if remote.Host == "" || !strings.Contains(remote.Path, "/") {
+		return Remote{}, fmt.Errorf("invalid remote URL %q: expected a host and an owner/name path", remoteURL)
+	}
+	return remote, nil
+}
+
+// Detect returns the kind of code host from its name. Hosts that are not
+// recognized, like self-hosted instances without gitlab in their name, need
+// an explicit kind.
+func Detect(host string) (Kind, error) {
+	switch {
+	case host == "github.com" || strings.HasPrefix(host, "github."):
+		return GitHub, nil
+	case strings.Contains(host, "gitlab"):
+		return GitLab, nil
+	}
+	return "", fmt.Errorf("cannot detect the code host of %s, set it with --forge", host)
+}
+
+// ParseKind returns the kind with the given name.
+func ParseKind(name string) (Kind, error) {
+	switch Kind(name) {
+	case GitHub, GitLab:
+		return Kind(name), nil
+	}
+	return "", fmt.Errorf("unknown forge %q, must be github or gitlab", name)
+}
+
+// Options configure the API client.
+type Options struct {
+	// APIURL overrides the API endpoint, which is derived from the remote
+	// host by default.
+	APIURL string
+	// Token authenticates the requests, see TokenFromEnv.
+	Token string
+	// HeadOwner is the owner of the repository pull requests are opened
+	// from, like a fork, which GitHub lists them by. It is the owner of the
+	// remote repository by default. GitLab finds merge requests from forks
+	// by their source branch alone.
+	HeadOwner string
+	Client    *http.Client
+}
+
+// New returns the API client of the kind for the remote repository.
+func New(kind Kind, remote Remote, opts Options) (Forge, error) {
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	api := apiClient{client: opts.Client, token: opts.Token}
+
+	switch kind {
+	case GitHub:
+		api.baseURL = opts.APIURL
+		if api.baseURL == "" {
+			api.baseURL = "https://api.github.com"
+			if remote.Host != "github.com" {
+				// GitHub Enterprise Server
+				api.baseURL = "https://" + remote.Host + "/api/v3"
+			}
+		}
+		api.authHeader = "Authorization"
+		api.authPrefix = "Bearer "
+		headOwner := opts.HeadOwner
+		if headOwner == "" {
+			headOwner = remote.Owner()
+		}
+		return &githubForge{api: api, remote: remote, headOwner: headOwner}, nil
+	case GitLab:
+		api.baseURL = opts.APIURL
+		if api.baseURL == "" {
+			api.baseURL = "https://" + remote.Host + "/api/v4"
+		}
+		api.authHeader = "PRIVATE-TOKEN"
+		return &gitlabForge{api: api, remote: remote}, nil
+	}
+	return nil, fmt.Errorf("unknown forge %q", kind)
+}
+
+// TokenFromEnv returns the API token for the kind of code host, from
+// GITHUB_TOKEN or GH_TOKEN for GitHub and GITLAB_TOKEN for GitLab.
+func TokenFromEnv(kind Kind) string {
+	var names []string
+	switch kind {
+	case GitHub:
+		names = []string{"GITHUB_TOKEN", "GH_TOKEN"}
+	case GitLab:
+		names = []string{"GITLAB_TOKEN"}
+	}
+	for _, name := range names {
+		if token := os.Getenv(name); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+// apiClient sends authenticated JSON requests to a code host API.
+type apiClient struct {
+	client     *http.Client
+	baseURL    string
+	token      string
+	authHeader string
+	authPrefix string
+}
+
+// do sends the request and decodes the JSON response into out, unless out
+// is nil.
+func (c apiClient) do(ctx context.Context, method string, path string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("error encoding request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.baseURL, "/")+path, reader)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set(c.authHeader, c.authPrefix+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error requesting %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response of %s %s: %w", method, path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &APIError{Method: method, Path: path, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("error decoding response of %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+func (c apiClient) get(ctx context.Context, path string, out any) error {
+	return c.do(ctx, http.MethodGet, path, nil, out)
+}
+
+// APIError is an unsuccessful response of a code host API.
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s %s returned %d: %s", e.Method, e.Path, e.StatusCode, e.Body)
+}
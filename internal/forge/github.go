@@ -0,0 +1,214 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+)
+
+type githubForge struct {
+	api       apiClient
+	remote    Remote
+	headOwner string
+}
+
+type githubPull struct {
+	Number         int    `json:"number"`
+	HTMLURL        string `json:"html_url"`
+	Title          string `json:"title"`
+	State          string `json:"state"`
+	Draft          bool   `json:"draft"`
+	Merged         bool   `json:"merged"`
+	MergedAt       string `json:"merged_at"`
+	Mergeable      *bool  `json:"mergeable"`
+	MergeableState string `json:"mergeable_state"`
+	Head           struct {
+		SHA string `json:"sha"`
+	} `json:"head"`
+}
+
+type githubReview struct {
+	User struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	State string `json:"state"`
+}
+
+type githubCheckRuns struct {
+	CheckRuns []struct {
+		Name       string `json:"name"`
+		Status     string `json:"status"`
+		Conclusion string `json:"conclusion"`
+	} `json:"check_runs"`
+}
+
+type githubCombinedStatus struct {
+	Statuses []struct {
+		Context string `json:"context"`
+		State   string `json:"state"`
+	} `json:"statuses"`
+}
+
+func (f *githubForge) repoPath() string {
+	return "/repos/" + f.remote.Path
+}
+
+func (f *githubForge) FindPullRequest(ctx context.Context, branch string) (*PullRequest, error) {
+	query := url.Values{
+		"head":      {f.headOwner + ":" + branch},
+		"state":     {"all"},
+		"sort":      {"created"},
+		"direction": {"desc"},
+	}
+	var pulls []githubPull
+	if err := f.api.get(ctx, f.repoPath()+"/pulls?"+query.Encode(), &pulls); err != nil {
+		return nil, fmt.Errorf("error listing pull requests: %w", err)
+	}
+	if len(pulls) == 0 {
+		return nil, ErrNotFound
+	}
+
+	// The list does not include the merge state, so fetch the pull request
+	var pull githubPull
+	if err := f.api.get(ctx, fmt.Sprintf("%s/pulls/%d", f.repoPath(), pulls[0].Number), &pull); err != nil {
+		return nil, fmt.Errorf("error getting pull request: %w", err)
+	}
+
+	pr := &PullRequest{
+		Number:    pull.Number,
+		URL:       pull.HTMLURL,
+		Title:     pull.Title,
+		State:     StateOpen,
+		Draft:     pull.Draft,
+		HeadSHA:   pull.Head.SHA,
+		Mergeable: githubMergeable(pull),
+	}
+	if pull.Merged || pull.MergedAt != "" {
+		pr.State = StateMerged
+	} else if pull.State == "closed" {
+		pr.State = StateClosed
+	}
+
+	if err := f.addReviews(ctx, pr); err != nil {
+		return nil, err
+	}
+	if err := f.addChecks(ctx, pr); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+func githubMergeable(pull githubPull) string {
+	switch {
+	case pull.MergeableState == "dirty" || (pull.Mergeable != nil && !*pull.Mergeable):
+		return MergeableConflict
+	case pull.Mergeable == nil || pull.MergeableState == "unknown":
+		// GitHub computes mergeability in the background
+		return MergeableUnknown
+	}
+	return MergeableClean
+}
+
+// addReviews sets the review state from the latest review of every
+// reviewer.
+func (f *githubForge) addReviews(ctx context.Context, pr *PullRequest) error {
+	var reviews []githubReview
+	if err := f.api.get(ctx, fmt.Sprintf("%s/pulls/%d/reviews?per_page=100", f.repoPath(), pr.Number), &reviews); err != nil {
+		return fmt.Errorf("error listing reviews: %w", err)
+	}
+
+	latest := make(map[string]string)
+	var reviewers []string
+	for _, review := range reviews {
+		// Comments do not change the verdict of a reviewer
+		if review.State != "APPROVED" && review.State != "CHANGES_REQUESTED" && review.State != "DISMISSED" {
+			continue
+		}
+		if _, ok := latest[review.User.Login]; !ok {
+			reviewers = append(reviewers, review.User.Login)
+		}
+		latest[review.User.Login] = review.State
+	}
+
+	pr.Review = ReviewPending
+	for _, reviewer := range reviewers {
+		switch latest[reviewer] {
+		case "APPROVED":
+			pr.Approvals++
+		case "CHANGES_REQUESTED":
+			pr.Review = ReviewChangesRequested
+		}
+	}
+	if pr.Review == ReviewPending && pr.Approvals > 0 {
+		pr.Review = ReviewApproved
+	}
+	return nil
+}
+
+// addChecks sets the CI state from the check runs and commit statuses of
+// the head commit.
+func (f *githubForge) addChecks(ctx context.Context, pr *PullRequest) error {
+	var runs githubCheckRuns
+	if err := f.api.get(ctx, fmt.Sprintf("%s/commits/%s/check-runs?per_page=100", f.repoPath(), pr.HeadSHA), &runs); err != nil {
+		return fmt.Errorf("error listing check runs: %w", err)
+	}
+	var status githubCombinedStatus
+	if err := f.api.get(ctx, fmt.Sprintf("%s/commits/%s/status", f.repoPath(), pr.HeadSHA), &status); err != nil {
+		return fmt.Errorf("error getting commit status: %w", err)
+	}
+
+	var checks checkSummary
+	for _, run := range runs.CheckRuns {
+		switch {
+		case run.Status != "completed":
+			checks.add(run.Name, ChecksPending)
+		case slices.Contains([]string{"success", "neutral", "skipped"}, run.Conclusion):
+			checks.add(run.Name, ChecksSuccess)
+		default:
+			checks.add(run.Name, ChecksFailure)
+		}
+	}
+	for _, s := range status.Statuses {
+		switch s.State {
+		case "success":
+			checks.add(s.Context, ChecksSuccess)
+		case "pending":
+			checks.add(s.Context, ChecksPending)
+		default:
+			checks.add(s.Context, ChecksFailure)
+		}
+	}
+	pr.Checks, pr.FailedChecks = checks.result()
+	return nil
+}
+
+// checkSummary combines the states of individual checks. Any failure fails
+// the summary, otherwise any pending check keeps it pending.
+type checkSummary struct {
+	count   int
+	pending bool
+	failed  []string
+}
+
+func (s *checkSummary) add(name string, state string) {
+	s.count++
+	switch state {
+	case ChecksFailure:
+		s.failed = append(s.failed, name)
+	case ChecksPending:
+		s.pending = true
+	}
+}
+
+func (s *checkSummary) result() (string, []string) {
+	switch {
+	case s.count == 0:
+		return ChecksNone, nil
+	case len(s.failed) > 0:
+		return ChecksFailure, s.failed
+	case s.pending:
+		return ChecksPending, nil
+	}
+	return ChecksSuccess, nil
+}
@@ -0,0 +1,108 @@
+package forge
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGitHubFindPullRequest(t *testing.T) {
+	api, server := newFakeAPI(t, map[string]string{
+		"GET /repos/org/repo/pulls?direction=desc&head=org%3Adeps%2Fbump&sort=created&state=all": `[{"number": 7}]`,
+		"GET /repos/org/repo/pulls/7": `{
+			"number": 7, "html_url": "https://github.com/org/repo/pull/7", "title": "Bump deps",
+			"state": "open", "draft": false, "mergeable": true, "mergeable_state": "clean",
+			"head": {"sha": "abc123"}
+		}`,
+		"GET /repos/org/repo/pulls/7/reviews?per_page=100": `[
+			{"user": {"login": "alice"}, "state": "CHANGES_REQUESTED"},
+			{"user": {"login": "bob"}, "state": "APPROVED"},
+			{"user": {"login": "alice"}, "state": "COMMENTED"},
+			{"user": {"login": "alice"}, "state": "APPROVED"}
+		]`,
+		"GET /repos/org/repo/commits/abc123/check-runs?per_page=100": `{"check_runs": [
+			{"name": "build", "status": "completed", "conclusion": "success"},
+			{"name": "lint", "status": "completed", "conclusion": "skipped"},
+			{"name": "test", "status": "completed", "conclusion": "failure"}
+		]}`,
+		"GET /repos/org/repo/commits/abc123/status": `{"statuses": [{"context": "ci/legacy", "state": "pending"}]}`,
+	})
+
+	f, err := New(GitHub, Remote{Host: "github.com", Path: "org/repo"}, Options{APIURL: server.URL, Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := f.FindPullRequest(context.Background(), "deps/bump")
+	if err != nil {
+		t.Fatalf("FindPullRequest() error: %v\nrequests: %v", err, api.requests)
+	}
+
+	want := PullRequest{
+		Number: 7, URL: "https://github.com/org/repo/pull/7", Title: "Bump deps", State: StateOpen,
+		HeadSHA: "abc123", Review: ReviewApproved, Approvals: 2, Checks: ChecksFailure,
+		FailedChecks: []string{"test"}, Mergeable: MergeableClean,
+	}
+	if !reflect.DeepEqual(*pr, want) {
+		t.Errorf("FindPullRequest() = %+v, want %+v", *pr, want)
+	}
+	if got := api.headers.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization header = %q", got)
+	}
+}
+
+func TestGitHubFindPullRequestNotFound(t *testing.T) {
+	_, server := newFakeAPI(t, map[string]string{
+		"GET /repos/org/repo/pulls?direction=desc&head=org%3Afeature&sort=created&state=all": `[]`,
+	})
+	f, _ := New(GitHub, Remote{Host: "github.com", Path: "org/repo"}, Options{APIURL: server.URL})
+	if _, err := f.FindPullRequest(context.Background(), "feature"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGitHubFindPullRequestFromFork(t *testing.T) {
+	_, server := newFakeAPI(t, map[string]string{
+		"GET /repos/org/repo/pulls?direction=desc&head=me%3Adeps%2Fbump&sort=created&state=all": `[{"number": 8}]`,
+		"GET /repos/org/repo/pulls/8":                                `{"number": 8, "state": "open", "head": {"sha": "def456"}}`,
+		"GET /repos/org/repo/pulls/8/reviews?per_page=100":           `[]`,
+		"GET /repos/org/repo/commits/def456/check-runs?per_page=100": `{"check_runs": []}`,
+		"GET /repos/org/repo/commits/def456/status":                  `{"statuses": []}`,
+	})
+	f, _ := New(GitHub, Remote{Host: "github.com", Path: "org/repo"}, Options{APIURL: server.URL, HeadOwner: "me"})
+	pr, err := f.FindPullRequest(context.Background(), "deps/bump")
+	if err != nil {
+		t.Fatalf("FindPullRequest() error: %v", err)
+	}
+	if pr.Number != 8 || pr.HeadSHA != "def456" {
+		t.Errorf("expected the pull request from the fork, got %+v", *pr)
+	}
+}
+
+func TestGitHubStates(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		pull githubPull
+		want string
+	}{
+		{githubPull{Mergeable: &yes, MergeableState: "clean"}, MergeableClean},
+		{githubPull{Mergeable: &yes, MergeableState: "blocked"}, MergeableClean},
+		{githubPull{Mergeable: &no, MergeableState: "dirty"}, MergeableConflict},
+		{githubPull{MergeableState: "unknown"}, MergeableUnknown},
+	}
+	for _, tt := range tests {
+		if got := githubMergeable(tt.pull); got != tt.want {
+			t.Errorf("githubMergeable(%+v) = %q, want %q", tt.pull, got, tt.want)
+		}
+	}
+
+	var summary checkSummary
+	if state, _ := summary.result(); state != ChecksNone {
+		t.Errorf("empty summary = %q, want %q", state, ChecksNone)
+	}
+	summary.add("build", ChecksSuccess)
+	summary.add("deploy", ChecksPending)
+	if state, _ := summary.result(); state != ChecksPending {
+		t.Errorf("pending summary = %q, want %q", state, ChecksPending)
+	}
+}
@@ -0,0 +1,134 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+type gitlabForge struct {
+	api    apiClient
+	remote Remote
+}
+
+type gitlabMergeRequest struct {
+	IID                 int    `json:"iid"`
+	WebURL              string `json:"web_url"`
+	Title               string `json:"title"`
+	State               string `json:"state"`
+	Draft               bool   `json:"draft"`
+	SHA                 string `json:"sha"`
+	HasConflicts        bool   `json:"has_conflicts"`
+	DetailedMergeStatus string `json:"detailed_merge_status"`
+	HeadPipeline        *struct {
+		ID     int    `json:"id"`
+		Status string `json:"status"`
+	} `json:"head_pipeline"`
+}
+
+type gitlabApprovals struct {
+	Approved   bool  `json:"approved"`
+	ApprovedBy []any `json:"approved_by"`
+}
+
+type gitlabJob struct {
+	Name string `json:"name"`
+}
+
+func (f *gitlabForge) projectPath() string {
+	return "/projects/" + url.PathEscape(f.remote.Path)
+}
+
+func (f *gitlabForge) FindPullRequest(ctx context.Context, branch string) (*PullRequest, error) {
+	query := url.Values{
+		"source_branch": {branch},
+		"order_by":      {"created_at"},
+		"sort":          {"desc"},
+	}
+	var mrs []gitlabMergeRequest
+	if err := f.api.get(ctx, f.projectPath()+"/merge_requests?"+query.Encode(), &mrs); err != nil {
+		return nil, fmt.Errorf("error listing merge requests: %w", err)
+	}
+	if len(mrs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	// The list does not include the pipeline, so fetch the merge request
+	var mr gitlabMergeRequest
+	if err := f.api.get(ctx, fmt.Sprintf("%s/merge_requests/%d", f.projectPath(), mrs[0].IID), &mr); err != nil {
+		return nil, fmt.Errorf("error getting merge request: %w", err)
+	}
+
+	pr := &PullRequest{
+		Number:    mr.IID,
+		URL:       mr.WebURL,
+		Title:     mr.Title,
+		Draft:     mr.Draft,
+		HeadSHA:   mr.SHA,
+		Mergeable: gitlabMergeable(mr),
+	}
+	switch mr.State {
+	case "merged":
+		pr.State = StateMerged
+	case "opened":
+		pr.State = StateOpen
+	default:
+		pr.State = StateClosed
+	}
+
+	var approvals gitlabApprovals
+	if err := f.api.get(ctx, fmt.Sprintf("%s/merge_requests/%d/approvals", f.projectPath(), mr.IID), &approvals); err != nil {
+		return nil, fmt.Errorf("error getting approvals: %w", err)
+	}
+	pr.Approvals = len(approvals.ApprovedBy)
+	pr.Review = ReviewPending
+	if approvals.Approved && pr.Approvals > 0 {
+		pr.Review = ReviewApproved
+	}
+
+	if err := f.addChecks(ctx, pr, mr); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+func gitlabMergeable(mr gitlabMergeRequest) string {
+	switch {
+	case mr.HasConflicts || mr.DetailedMergeStatus == "conflict":
+		return MergeableConflict
+	case mr.DetailedMergeStatus == "checking" || mr.DetailedMergeStatus == "unchecked":
+		return MergeableUnknown
+	}
+	return MergeableClean
+}
+
+// addChecks sets the CI state from the head pipeline, with the names of the
+// failed jobs.
+func (f *gitlabForge) addChecks(ctx context.Context, pr *PullRequest, mr gitlabMergeRequest) error {
+	if mr.HeadPipeline == nil {
+		pr.Checks = ChecksNone
+		return nil
+	}
+
+	switch mr.HeadPipeline.Status {
+	case "success", "skipped":
+		pr.Checks = ChecksSuccess
+	case "failed", "canceled":
+		pr.Checks = ChecksFailure
+	default:
+		pr.Checks = ChecksPending
+		return nil
+	}
+	if pr.Checks != ChecksFailure {
+		return nil
+	}
+
+	var jobs []gitlabJob
+	if err := f.api.get(ctx, fmt.Sprintf("%s/pipelines/%d/jobs?scope[]=failed&per_page=100", f.projectPath(), mr.HeadPipeline.ID), &jobs); err != nil {
+		return fmt.Errorf("error listing failed jobs: %w", err)
+	}
+	for _, job := range jobs {
+		pr.FailedChecks = append(pr.FailedChecks, job.Name)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package forge
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGitLabFindPullRequest(t *testing.T) {
+	api, server := newFakeAPI(t, map[string]string{
+		"GET /projects/group%2Fsub%2Frepo/merge_requests?order_by=created_at&sort=desc&source_branch=deps%2Fbump": `[{"iid": 3}]`,
+		"GET /projects/group%2Fsub%2Frepo/merge_requests/3": `{
+			"iid": 3, "web_url": "https://gitlab.com/group/sub/repo/-/merge_requests/3", "title": "Bump deps",
+			"state": "opened", "draft": true, "sha": "def456", "has_conflicts": true,
+			"detailed_merge_status": "broken_status", "head_pipeline": {"id": 99, "status": "failed"}
+		}`,
+		"GET /projects/group%2Fsub%2Frepo/merge_requests/3/approvals":                    `{"approved": true, "approved_by": [{"user": {"username": "alice"}}]}`,
+		"GET /projects/group%2Fsub%2Frepo/pipelines/99/jobs?scope[]=failed&per_page=100": `[{"name": "test"}, {"name": "lint"}]`,
+	})
+
+	f, err := New(GitLab, Remote{Host: "gitlab.com", Path: "group/sub/repo"}, Options{APIURL: server.URL, Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := f.FindPullRequest(context.Background(), "deps/bump")
+	if err != nil {
+		t.Fatalf("FindPullRequest() error: %v\nrequests: %v", err, api.requests)
+	}
+
+	if pr.Number != 3 || pr.State != StateOpen || !pr.Draft || pr.HeadSHA != "def456" {
+		t.Errorf("unexpected merge request %+v", *pr)
+	}
+	if pr.Review != ReviewApproved || pr.Approvals != 1 {
+		t.Errorf("unexpected review state %q with %d approvals", pr.Review, pr.Approvals)
+	}
+	if pr.Checks != ChecksFailure || strings.Join(pr.FailedChecks, ",") != "test,lint" {
+		t.Errorf("unexpected checks %q %v", pr.Checks, pr.FailedChecks)
+	}
+	if pr.Mergeable != MergeableConflict {
+		t.Errorf("Mergeable = %q, want %q", pr.Mergeable, MergeableConflict)
+	}
+	if got := api.headers.Get("PRIVATE-TOKEN"); got != "secret" {
+		t.Errorf("PRIVATE-TOKEN header = %q", got)
+	}
+}
+
+func TestGitLabFindPullRequestNotFound(t *testing.T) {
+	_, server := newFakeAPI(t, map[string]string{
+		"GET /projects/org%2Frepo/merge_requests?order_by=created_at&sort=desc&source_branch=feature": `[]`,
+	})
+	f, _ := New(GitLab, Remote{Host: "gitlab.com", Path: "org/repo"}, Options{APIURL: server.URL})
+	if _, err := f.FindPullRequest(context.Background(), "feature"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGitLabStates(t *testing.T) {
+	tests := []struct {
+		mr   gitlabMergeRequest
+		want string
+	}{
+		{gitlabMergeRequest{DetailedMergeStatus: "mergeable"}, MergeableClean},
+		{gitlabMergeRequest{DetailedMergeStatus: "not_approved"}, MergeableClean},
+		{gitlabMergeRequest{DetailedMergeStatus: "checking"}, MergeableUnknown},
+		{gitlabMergeRequest{HasConflicts: true, DetailedMergeStatus: "mergeable"}, MergeableConflict},
+	}
+	for _, tt := range tests {
+		if got := gitlabMergeable(tt.mr); got != tt.want {
+			t.Errorf("gitlabMergeable(%+v) = %q, want %q", tt.mr, got, tt.want)
+		}
+	}
+}
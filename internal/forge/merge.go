@@ -0,0 +1,103 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// MergeMethod is how the changes of a pull request land on the base branch.
+type MergeMethod string
+
+const (
+	MergeMethodMerge  MergeMethod = "merge"
+	MergeMethodSquash MergeMethod = "squash"
+	MergeMethodRebase MergeMethod = "rebase"
+)
+
+// ParseMergeMethod returns the merge method with the given name.
+func ParseMergeMethod(name string) (MergeMethod, error) {
+	switch MergeMethod(name) {
+	case MergeMethodMerge, MergeMethodSquash, MergeMethodRebase:
+		return MergeMethod(name), nil
+	}
+	return "", fmt.Errorf("unknown merge method %q, must be merge, squash or rebase", name)
+}
+
+// MergeConditions decide whether a pull request is ready to be merged.
+type MergeConditions struct {
+	// RequireChecks requires all CI checks to pass. Pull requests without
+	// checks are not merged either.
+	RequireChecks bool
+	// MinApprovals is the number of approvals required.
+	MinApprovals int
+}
+
+// Blockers returns the reasons the pull request cannot be merged, or nil
+// when it meets the conditions.
+func (c MergeConditions) Blockers(pr *PullRequest) []string {
+	if pr.State != StateOpen {
+		return []string{"pull request is " + pr.State}
+	}
+
+	var blockers []string
+	if pr.Draft {
+		blockers = append(blockers, "pull request is a draft")
+	}
+	switch pr.Mergeable {
+	case MergeableConflict:
+		blockers = append(blockers, "has merge conflicts")
+	case MergeableUnknown:
+		blockers = append(blockers, "mergeability is not known yet")
+	}
+	if c.RequireChecks {
+		switch pr.Checks {
+		case ChecksFailure:
+			blockers = append(blockers, "checks failed ("+strings.Join(pr.FailedChecks, ", ")+")")
+		case ChecksPending:
+			blockers = append(blockers, "checks are pending")
+		case ChecksNone:
+			blockers = append(blockers, "has no checks")
+		}
+	}
+	if pr.Review == ReviewChangesRequested {
+		blockers = append(blockers, "changes requested")
+	}
+	if pr.Approvals < c.MinApprovals {
+		blockers = append(blockers, fmt.Sprintf("%d of %d required approvals", pr.Approvals, c.MinApprovals))
+	}
+	return blockers
+}
+
+type githubMergeOptions struct {
+	MergeMethod MergeMethod `json:"merge_method"`
+	SHA         string      `json:"sha,omitempty"`
+}
+
+func (f *githubForge) Merge(ctx context.Context, pr *PullRequest, method MergeMethod) error {
+	// The head SHA makes GitHub refuse the merge if the branch moved since
+	// the pull request was checked
+	body := githubMergeOptions{MergeMethod: method, SHA: pr.HeadSHA}
+	if err := f.api.do(ctx, http.MethodPut, fmt.Sprintf("%s/pulls/%d/merge", f.repoPath(), pr.Number), body, nil); err != nil {
+		return fmt.Errorf("error merging pull request: %w", err)
+	}
+	return nil
+}
+
+type gitlabMergeOptions struct {
+	Squash bool   `json:"squash"`
+	SHA    string `json:"sha,omitempty"`
+}
+
+func (f *gitlabForge) Merge(ctx context.Context, pr *PullRequest, method MergeMethod) error {
+	if method == MergeMethodRebase {
+		// Fast-forward merges are a project setting on GitLab
+		return fmt.Errorf("error merging merge request: the rebase method is not supported by GitLab, set the project merge method instead")
+	}
+	body := gitlabMergeOptions{Squash: method == MergeMethodSquash, SHA: pr.HeadSHA}
+	if err := f.api.do(ctx, http.MethodPut, fmt.Sprintf("%s/merge_requests/%d/merge", f.projectPath(), pr.Number), body, nil); err != nil {
+		return fmt.Errorf("error merging merge request: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package forge
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMergeConditionsBlockers(t *testing.T) {
+	ready := PullRequest{State: StateOpen, Review: ReviewApproved, Approvals: 1, Checks: ChecksSuccess, Mergeable: MergeableClean}
+	conditions := MergeConditions{RequireChecks: true, MinApprovals: 1}
+
+	tests := []struct {
+		name   string
+		modify func(pr *PullRequest)
+		cond   MergeConditions
+		want   string
+	}{
+		{"ready", func(pr *PullRequest) {}, conditions, ""},
+		{"merged", func(pr *PullRequest) { pr.State = StateMerged }, conditions, "pull request is merged"},
+		{"draft", func(pr *PullRequest) { pr.Draft = true }, conditions, "pull request is a draft"},
+		{"conflict", func(pr *PullRequest) { pr.Mergeable = MergeableConflict }, conditions, "has merge conflicts"},
+		{"unknown", func(pr *PullRequest) { pr.Mergeable = MergeableUnknown }, conditions, "mergeability is not known yet"},
+		{"failed checks", func(pr *PullRequest) {
+			pr.Checks = ChecksFailure
+			pr.FailedChecks = []string{"test", "lint"}
+		}, conditions, "checks failed (test, lint)"},
+		{"pending checks", func(pr *PullRequest) { pr.Checks = ChecksPending }, conditions, "checks are pending"},
+		{"no checks", func(pr *PullRequest) { pr.Checks = ChecksNone }, conditions, "has no checks"},
+		{"checks not required", func(pr *PullRequest) { pr.Checks = ChecksFailure }, MergeConditions{MinApprovals: 1}, ""},
+		{"changes requested", func(pr *PullRequest) { pr.Review = ReviewChangesRequested }, conditions, "changes requested"},
+		{"approvals", func(pr *PullRequest) {}, MergeConditions{RequireChecks: true, MinApprovals: 2}, "1 of 2 required approvals"},
+		{"several", func(pr *PullRequest) {
+			pr.Draft = true
+			pr.Approvals = 0
+		}, conditions, "pull request is a draft; 0 of 1 required approvals"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := ready
+			tt.modify(&pr)
+			if got := strings.Join(tt.cond.Blockers(&pr), "; "); got != tt.want {
+				t.Errorf("Blockers() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	api, server := newFakeAPI(t, map[string]string{
+		"PUT /repos/org/repo/pulls/7/merge":               `{"merged": true}`,
+		"PUT /projects/org%2Frepo/merge_requests/3/merge": `{"state": "merged"}`,
+		"PUT /repos/org/conflict/pulls/8/merge":           `{"message": "Head branch was modified"}`,
+	})
+	api.statuses["PUT /repos/org/conflict/pulls/8/merge"] = http.StatusConflict
+
+	github, _ := New(GitHub, Remote{Host: "github.com", Path: "org/repo"}, Options{APIURL: server.URL})
+	if err := github.Merge(context.Background(), &PullRequest{Number: 7, HeadSHA: "abc"}, MergeMethodSquash); err != nil {
+		t.Fatalf("GitHub Merge() error: %v", err)
+	}
+	if body := api.bodies["PUT /repos/org/repo/pulls/7/merge"]; body != `{"merge_method":"squash","sha":"abc"}` {
+		t.Errorf("unexpected GitHub merge body %s", body)
+	}
+
+	gitlab, _ := New(GitLab, Remote{Host: "gitlab.com", Path: "org/repo"}, Options{APIURL: server.URL})
+	if err := gitlab.Merge(context.Background(), &PullRequest{Number: 3, HeadSHA: "def"}, MergeMethodSquash); err != nil {
+		t.Fatalf("GitLab Merge() error: %v", err)
+	}
+	if body := api.bodies["PUT /projects/org%2Frepo/merge_requests/3/merge"]; body != `{"squash":true,"sha":"def"}` {
+		t.Errorf("unexpected GitLab merge body %s", body)
+	}
+	if err := gitlab.Merge(context.Background(), &PullRequest{Number: 3}, MergeMethodRebase); err == nil {
+		t.Error("expected error for the rebase method on GitLab")
+	}
+
+	conflict, _ := New(GitHub, Remote{Host: "github.com", Path: "org/conflict"}, Options{APIURL: server.URL})
+	err := conflict.Merge(context.Background(), &PullRequest{Number: 8}, MergeMethodMerge)
+	if err == nil || !strings.Contains(err.Error(), "returned 409") {
+		t.Errorf("expected a 409 error, got %v", err)
+	}
+}
+
+func TestParseMergeMethod(t *testing.T) {
+	for _, name := range []string{"merge", "squash", "rebase"} {
+		if method, err := ParseMergeMethod(name); err != nil || string(method) != name {
+			t.Errorf("ParseMergeMethod(%q) = %q, %v", name, method, err)
+		}
+	}
+	if _, err := ParseMergeMethod("octopus"); err == nil {
+		t.Error("expected error for an unknown merge method")
+	}
+}
@@ -0,0 +1,81 @@
+package git
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+var (
+	changeIDPattern = regexp.MustCompile(`(?m)^Change-Id: I[0-9a-f]{40}$`)
+	trailerPattern  = regexp.MustCompile(`^[A-Za-z0-9-]+: `)
+)
+
+// ChangeID returns a Gerrit Change-Id derived from the key, so that commits
+// made again for the same key upload new patch sets of the same change
+// instead of new changes.
+func ChangeID(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return "I" + hex.EncodeToString(sum[:])
+}
+
+// WithChangeID adds a Change-Id trailer to the commit message, after the
+// trailers it already has. Messages with a Change-Id are left unchanged.
+func WithChangeID(message string, changeID string) string {
+	if changeIDPattern.MatchString(message) {
+		return message
+	}
+	message = strings.TrimRight(message, " \t\n")
+	paragraphs := strings.Split(message, "\n\n")
+	last := paragraphs[len(paragraphs)-1]
+	separator := "\n\n"
+	if len(paragraphs) > 1 && isTrailerBlock(last) {
+		separator = "\n"
+	}
+	return message + separator + "Change-Id: " + changeID
+}
+
+func isTrailerBlock(paragraph string) bool {
+	for line := range strings.SplitSeq(paragraph, "\n") {
+		if !trailerPattern.MatchString(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReviewOptions are the Gerrit push options the changes are uploaded with.
+type ReviewOptions struct {
+	Topic     string
+	Reviewers []string
+	Hashtags  []string
+}
+
+// PushForReview pushes the checked out commits to refs/for/<base> on the
+// remote, which creates or updates a Gerrit change for each of them.
+func PushForReview(repoPath string, remote string, base string, opts ReviewOptions, noVerify bool) (string, error) {
+	pushArgs := []string{"push"}
+	if noVerify {
+		pushArgs = append(pushArgs, "--no-verify")
+	}
+	if opts.Topic != "" {
+		pushArgs = append(pushArgs, "-o", "topic="+opts.Topic)
+	}
+	for _, reviewer := range opts.Reviewers {
+		pushArgs = append(pushArgs, "-o", "r="+reviewer)
+	}
+	for _, hashtag := range opts.Hashtags {
+		pushArgs = append(pushArgs, "-o", "t="+hashtag)
+	}
+	pushArgs = append(pushArgs, remote, "HEAD:refs/for/"+base)
+	cmd := exec.Command("git", pushArgs...)
+	cmd.Dir = repoPath
+	output, err := runRetried(cmd)
+	if err != nil {
+		return string(output), fmt.Errorf("error pushing for review: %w\n%s", err, string(output))
+	}
+	return string(output), nil
+}
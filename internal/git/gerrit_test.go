@@ -0,0 +1,86 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChangeID(t *testing.T) {
+	id := ChangeID("org/repo\nmain\ndeps/bump\ncampaign")
+	if len(id) != 41 || !strings.HasPrefix(id, "I") {
+		t.Errorf("Expected an I followed by 40 hex digits, got %q", id)
+	}
+	if again := ChangeID("org/repo\nmain\ndeps/bump\ncampaign"); again != id {
+		t.Errorf("Expected the same Change-Id for the same key, got %q and %q", id, again)
+	}
+	if other := ChangeID("org/repo\nmain\ndeps/bump\nstep 1"); other == id {
+		t.Errorf("Expected another Change-Id for another key, got %q", other)
+	}
+}
+
+func TestWithChangeID(t *testing.T) {
+	id := "I" + strings.Repeat("a", 40)
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			name:    "subject only",
+			message: "Update logging\n",
+			want:    "Update logging\n\nChange-Id: " + id,
+		},
+		{
+			name:    "body",
+			message: "Update logging\n\nUse the structured logger.",
+			want:    "Update logging\n\nUse the structured logger.\n\nChange-Id: " + id,
+		},
+		{
+			name:    "existing trailers",
+			message: "Update logging\n\nSigned-off-by: Dev <dev@example.com>",
+			want:    "Update logging\n\nSigned-off-by: Dev <dev@example.com>\nChange-Id: " + id,
+		},
+		{
+			name:    "existing change id",
+			message: "Update logging\n\nChange-Id: I" + strings.Repeat("b", 40),
+			want:    "Update logging\n\nChange-Id: I" + strings.Repeat("b", 40),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WithChangeID(tt.message, id); got != tt.want {
+				t.Errorf("WithChangeID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPushForReview(t *testing.T) {
+	repoPath := createTestRepo(t)
+	remotePath := t.TempDir()
+	runGit(t, remotePath, "init", "--bare")
+	runGit(t, remotePath, "config", "receive.advertisePushOptions", "true")
+	// The hook prints the push options the way Gerrit would apply them
+	hook := "#!/bin/sh\ni=0\nwhile [ $i -lt \"${GIT_PUSH_OPTION_COUNT:-0}\" ]; do\n  eval echo \"option \\$GIT_PUSH_OPTION_$i\"\n  i=$((i+1))\ndone\n"
+	if err := os.WriteFile(filepath.Join(remotePath, "hooks", "pre-receive"), []byte(hook), 0755); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, repoPath, "remote", "add", "origin", remotePath)
+
+	opts := ReviewOptions{Topic: "deps-bump", Reviewers: []string{"dev@example.com"}, Hashtags: []string{"deps"}}
+	output, err := PushForReview(repoPath, "origin", "main", opts, false)
+	if err != nil {
+		t.Fatalf("PushForReview failed: %v", err)
+	}
+	for _, option := range []string{"option topic=deps-bump", "option r=dev@example.com", "option t=deps"} {
+		if !strings.Contains(output, "remote: "+option) {
+			t.Errorf("Expected push option %q in the output, got:\n%s", option, output)
+		}
+	}
+	head := runGit(t, repoPath, "rev-parse", "HEAD")
+	if got := runGit(t, remotePath, "rev-parse", "refs/for/main"); got != head {
+		t.Errorf("Expected refs/for/main to point to %s, got %s", head, got)
+	}
+}
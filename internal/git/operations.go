@@ -3,32 +3,44 @@ package git
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"strings"
 )
 
 func CheckoutBranch(repoPath string, branch string) error {
 	cmd := exec.Command("git", "checkout", "-B", branch)
 	cmd.Dir = repoPath
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := run(cmd, nil); err != nil {
 		return fmt.Errorf("error checking out branch: %w\n%s", err, string(output))
 	}
 	return nil
 }
 
+// HasChanges reports whether the working tree has tracked or untracked changes.
+func HasChanges(repoPath string) (bool, error) {
+	cmd := exec.Command("git", "status", "--porcelain")
+	cmd.Dir = repoPath
+	output, err := run(cmd, nil)
+	if err != nil {
+		return false, fmt.Errorf("git status failed: %w\n%s", err, string(output))
+	}
+	return len(bytes.TrimSpace(output)) > 0, nil
+}
+
 func StashChanges(repoPath string) error {
-	statusCmd := exec.Command("git", "status", "--porcelain")
-	statusCmd.Dir = repoPath
-	statusOutput, err := statusCmd.CombinedOutput()
+	hasChanges, err := HasChanges(repoPath)
 	if err != nil {
-		return fmt.Errorf("git status failed: %w\n%s", err, string(statusOutput))
+		return err
 	}
-	if len(bytes.TrimSpace(statusOutput)) == 0 {
+	if !hasChanges {
 		return nil
 	}
 
 	cmd := exec.Command("git", "stash", "push", "-u")
 	cmd.Dir = repoPath
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := run(cmd, nil); err != nil {
 		return fmt.Errorf("git stash failed: %w\n%s", err, string(output))
 	}
 	return nil
@@ -37,7 +49,7 @@ func StashChanges(repoPath string) error {
 func ApplyPatch(repoPath string, patchFile string) error {
 	cmd := exec.Command("git", "apply", patchFile)
 	cmd.Dir = repoPath
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := run(cmd, nil); err != nil {
 		return fmt.Errorf("patch apply failed: %w\n%s", err, string(output))
 	}
 	return nil
@@ -46,7 +58,7 @@ func ApplyPatch(repoPath string, patchFile string) error {
 func CommitChanges(repoPath string, message string, noVerify bool) error {
 	addCmd := exec.Command("git", "add", ".")
 	addCmd.Dir = repoPath
-	if output, err := addCmd.CombinedOutput(); err != nil {
+	if output, err := run(addCmd, nil); err != nil {
 		return fmt.Errorf("git add failed: %w\n%s", err, string(output))
 	}
 
@@ -57,33 +69,74 @@ func CommitChanges(repoPath string, message string, noVerify bool) error {
 	commitArgs = append(commitArgs, "-m", message)
 	commitCmd := exec.Command("git", commitArgs...)
 	commitCmd.Dir = repoPath
-	if output, err := commitCmd.CombinedOutput(); err != nil {
+	if output, err := run(commitCmd, nil); err != nil {
 		return fmt.Errorf("git commit failed: %w\n%s", err, string(output))
 	}
 	return nil
 }
 
+// AmendCommit adds the changes to the last commit and replaces its message.
+func AmendCommit(repoPath string, message string, noVerify bool) error {
+	addCmd := exec.Command("git", "add", ".")
+	addCmd.Dir = repoPath
+	if output, err := run(addCmd, nil); err != nil {
+		return fmt.Errorf("git add failed: %w\n%s", err, string(output))
+	}
+
+	commitArgs := []string{"commit", "--amend"}
+	if noVerify {
+		commitArgs = append(commitArgs, "--no-verify")
+	}
+	commitArgs = append(commitArgs, "-m", message)
+	commitCmd := exec.Command("git", commitArgs...)
+	commitCmd.Dir = repoPath
+	if output, err := run(commitCmd, nil); err != nil {
+		return fmt.Errorf("git commit --amend failed: %w\n%s", err, string(output))
+	}
+	return nil
+}
+
 func IsGitRepository(repoPath string) error {
 	cmd := exec.Command("git", "-C", repoPath, "rev-parse", "--git-dir")
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := run(cmd, nil); err != nil {
 		return fmt.Errorf("%w\n%s", err, string(output))
 	}
 	return nil
 }
 
-func ExecuteScript(repoPath string, scriptPath string) error {
-	cmd := exec.Command(scriptPath)
+// ExecOptions describe how scripts and commands are run in a repository.
+type ExecOptions struct {
+	// Args are positional arguments, passed to commands as $1, $2 and so on.
+	Args []string
+	// Env are extra KEY=value variables added to the environment of cascade.
+	Env []string
+	// Interpreter runs scripts instead of detecting how to run them, see
+	// ScriptCommand.
+	Interpreter string
+	// Output receives the combined output of the process while it runs.
+	Output io.Writer
+}
+
+func ExecuteScript(repoPath string, scriptPath string, opts ExecOptions) error {
+	command, err := ScriptCommand(scriptPath, opts.Interpreter)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(command[0], append(command[1:], opts.Args...)...)
 	cmd.Dir = repoPath
-	if output, err := cmd.CombinedOutput(); err != nil {
+	cmd.Env = append(os.Environ(), opts.Env...)
+	if output, err := run(cmd, opts.Output); err != nil {
 		return fmt.Errorf("script execution failed: %w\n%s", err, string(output))
 	}
 	return nil
 }
 
-func ExecuteCommand(repoPath string, command string) error {
-	cmd := exec.Command("sh", "-c", command)
+func ExecuteCommand(repoPath string, command string, opts ExecOptions) error {
+	// The word after the command becomes $0, so the arguments start at $1
+	cmd := exec.Command("sh", append([]string{"-c", command, "cascade"}, opts.Args...)...)
 	cmd.Dir = repoPath
-	if output, err := cmd.CombinedOutput(); err != nil {
+	cmd.Env = append(os.Environ(), opts.Env...)
+	if output, err := run(cmd, opts.Output); err != nil {
 		return fmt.Errorf("command execution failed: %w\n%s", err, string(output))
 	}
 	return nil
@@ -92,7 +145,7 @@ func ExecuteCommand(repoPath string, command string) error {
 func CheckoutExistingBranch(repoPath string, branch string) error {
 	cmd := exec.Command("git", "checkout", branch)
 	cmd.Dir = repoPath
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := run(cmd, nil); err != nil {
 		return fmt.Errorf("error checking out branch: %w\n%s", err, string(output))
 	}
 	return nil
@@ -101,23 +154,80 @@ func CheckoutExistingBranch(repoPath string, branch string) error {
 func PullLatest(repoPath string) error {
 	cmd := exec.Command("git", "pull", "--ff-only")
 	cmd.Dir = repoPath
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := runRetried(cmd); err != nil {
 		return fmt.Errorf("error pulling latest changes: %w\n%s", err, string(output))
 	}
 	return nil
 }
 
-func PushChanges(repoPath string, branch string, noVerify bool) (string, error) {
+// PushChanges pushes the branch to the remote and sets it as the upstream.
+func PushChanges(repoPath string, remote string, branch string, noVerify bool) (string, error) {
 	pushArgs := []string{"push"}
 	if noVerify {
 		pushArgs = append(pushArgs, "--no-verify")
 	}
-	pushArgs = append(pushArgs, "-u", "origin", branch)
+	pushArgs = append(pushArgs, "-u", remote, branch)
 	cmd := exec.Command("git", pushArgs...)
 	cmd.Dir = repoPath
-	output, err := cmd.CombinedOutput()
+	output, err := runRetried(cmd)
 	if err != nil {
 		return string(output), fmt.Errorf("error pushing changes: %w\n%s", err, string(output))
 	}
 	return string(output), nil
 }
+
+// DeleteBranch deletes the local branch, whether or not it is merged.
+func DeleteBranch(repoPath string, branch string) error {
+	cmd := exec.Command("git", "branch", "-D", branch)
+	cmd.Dir = repoPath
+	if output, err := run(cmd, nil); err != nil {
+		return fmt.Errorf("error deleting branch: %w\n%s", err, string(output))
+	}
+	return nil
+}
+
+// DeleteRemoteBranch deletes the branch on the remote. The delete is refused
+// if the remote branch no longer points to the expected commit, so commits
+// pushed since it was checked are not lost.
+func DeleteRemoteBranch(repoPath string, remote string, branch string, expected string) error {
+	cmd := exec.Command("git", "push", "--force-with-lease="+branch+":"+expected, remote, "--delete", branch)
+	cmd.Dir = repoPath
+	if output, err := runRetried(cmd); err != nil {
+		return fmt.Errorf("error deleting remote branch: %w\n%s", err, string(output))
+	}
+	return nil
+}
+
+// CurrentBranch returns the name of the checked out branch, or an empty
+// string when HEAD is detached.
+func CurrentBranch(repoPath string) (string, error) {
+	cmd := exec.Command("git", "branch", "--show-current")
+	cmd.Dir = repoPath
+	output, err := run(cmd, nil)
+	if err != nil {
+		return "", fmt.Errorf("error getting current branch: %w\n%s", err, string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// RemoteURL returns the URL of the named remote.
+func RemoteURL(repoPath string, remote string) (string, error) {
+	cmd := exec.Command("git", "remote", "get-url", remote)
+	cmd.Dir = repoPath
+	output, err := run(cmd, nil)
+	if err != nil {
+		return "", fmt.Errorf("error getting remote URL: %w\n%s", err, string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// HeadCommit returns the SHA of the checked out commit.
+func HeadCommit(repoPath string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = repoPath
+	output, err := run(cmd, nil)
+	if err != nil {
+		return "", fmt.Errorf("error getting head commit: %w\n%s", err, string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -83,6 +83,24 @@ func TestCommitChanges(t *testing.T) {
 	}
 }
 
+func TestAmendCommit(t *testing.T) {
+	repoPath := createTestRepo(t)
+	os.WriteFile(filepath.Join(repoPath, "a.txt"), []byte("a"), 0644)
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Add a")
+	os.WriteFile(filepath.Join(repoPath, "b.txt"), []byte("b"), 0644)
+
+	if err := AmendCommit(repoPath, "Add a and b", false); err != nil {
+		t.Fatalf("AmendCommit failed: %v", err)
+	}
+	if log := runGit(t, repoPath, "log", "--pretty=%s"); log != "Add a and b\nInitial commit\n" {
+		t.Errorf("Expected the last commit to be amended, got:\n%s", log)
+	}
+	if files := runGit(t, repoPath, "show", "--name-only", "--pretty="); files != "a.txt\nb.txt\n" {
+		t.Errorf("Expected both files in the amended commit, got %q", files)
+	}
+}
+
 func TestCommitChangesNoVerifySkipsHook(t *testing.T) {
 	repoPath := createTestRepo(t)
 	commitMessage := "Skip hooks\n"
@@ -141,6 +159,64 @@ func TestStashChanges(t *testing.T) {
 	}
 }
 
+func TestExecuteScript(t *testing.T) {
+	repoPath := createTestRepo(t)
+	scriptPath := filepath.Join(t.TempDir(), "script.sh")
+	script := "#!/bin/sh\necho \"$CASCADE_REPO_NAME $1 $2\" > out.txt\n"
+	if err := os.WriteFile(scriptPath, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	err := ExecuteScript(repoPath, scriptPath, ExecOptions{
+		Args: []string{"one", "two words"},
+		Env:  []string{"CASCADE_REPO_NAME=repo"},
+	})
+	if err != nil {
+		t.Fatalf("ExecuteScript failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(repoPath, "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "repo one two words\n"; string(content) != want {
+		t.Errorf("Expected output %q, got %q", want, string(content))
+	}
+}
+
+func TestExecuteCommand(t *testing.T) {
+	repoPath := createTestRepo(t)
+
+	err := ExecuteCommand(repoPath, `echo "$CASCADE_BRANCH $1" > out.txt`, ExecOptions{
+		Args: []string{"arg"},
+		Env:  []string{"CASCADE_BRANCH=feature"},
+	})
+	if err != nil {
+		t.Fatalf("ExecuteCommand failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(repoPath, "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "feature arg\n"; string(content) != want {
+		t.Errorf("Expected output %q, got %q", want, string(content))
+	}
+
+	if err := ExecuteCommand(repoPath, "exit 3", ExecOptions{}); err == nil {
+		t.Error("Expected error for failing command, got nil")
+	}
+
+	var output strings.Builder
+	err = ExecuteCommand(repoPath, "echo out; echo err >&2; exit 1", ExecOptions{Output: &output})
+	if err == nil || !strings.Contains(err.Error(), "out\nerr") {
+		t.Errorf("Expected error with command output, got %v", err)
+	}
+	if output.String() != "out\nerr\n" {
+		t.Errorf("Expected streamed output %q, got %q", "out\nerr\n", output.String())
+	}
+}
+
 func TestIsGitRepository(t *testing.T) {
 	t.Run("valid repository", func(t *testing.T) {
 		repoPath := createTestRepo(t)
@@ -166,6 +242,100 @@ func TestIsGitRepository(t *testing.T) {
 	})
 }
 
+func TestCurrentBranch(t *testing.T) {
+	repoPath := createTestRepo(t)
+	runGit(t, repoPath, "checkout", "-b", "feature")
+
+	branch, err := CurrentBranch(repoPath)
+	if err != nil {
+		t.Fatalf("CurrentBranch failed: %v", err)
+	}
+	if branch != "feature" {
+		t.Errorf("Expected branch %q, got %q", "feature", branch)
+	}
+}
+
+func TestRemoteURL(t *testing.T) {
+	repoPath := createTestRepo(t)
+	runGit(t, repoPath, "remote", "add", "origin", "git@example.com:org/repo.git")
+
+	url, err := RemoteURL(repoPath, "origin")
+	if err != nil {
+		t.Fatalf("RemoteURL failed: %v", err)
+	}
+	if url != "git@example.com:org/repo.git" {
+		t.Errorf("Expected remote URL %q, got %q", "git@example.com:org/repo.git", url)
+	}
+
+	if _, err := RemoteURL(repoPath, "missing"); err == nil {
+		t.Error("RemoteURL should fail for a missing remote")
+	}
+}
+
+func TestHeadCommit(t *testing.T) {
+	repoPath := createTestRepo(t)
+	want := strings.TrimSpace(runGit(t, repoPath, "rev-parse", "HEAD"))
+
+	sha, err := HeadCommit(repoPath)
+	if err != nil {
+		t.Fatalf("HeadCommit failed: %v", err)
+	}
+	if sha != want || len(sha) != 40 {
+		t.Errorf("Expected commit %q, got %q", want, sha)
+	}
+
+	if _, err := HeadCommit(t.TempDir()); err == nil {
+		t.Error("HeadCommit should fail outside of a repository")
+	}
+}
+
+func TestDeleteBranches(t *testing.T) {
+	repoPath := createTestRepo(t)
+	remotePath := t.TempDir()
+	runGit(t, remotePath, "init", "--bare")
+	runGit(t, repoPath, "remote", "add", "origin", remotePath)
+	base := currentBranch(t, repoPath)
+
+	// An unmerged branch, which branch -d would refuse to delete
+	runGit(t, repoPath, "checkout", "-b", "feature")
+	os.WriteFile(filepath.Join(repoPath, "a.txt"), []byte("a"), 0644)
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Add a")
+	runGit(t, repoPath, "push", "origin", "feature")
+	runGit(t, repoPath, "checkout", base)
+
+	if err := DeleteBranch(repoPath, "feature"); err != nil {
+		t.Fatalf("DeleteBranch failed: %v", err)
+	}
+	if output := runGit(t, repoPath, "branch", "--list", "feature"); output != "" {
+		t.Errorf("Expected the local branch to be deleted, got %q", output)
+	}
+
+	// The lease refuses the delete when the remote branch moved
+	pushed := strings.TrimSpace(runGit(t, remotePath, "rev-parse", "feature"))
+	stale := strings.TrimSpace(runGit(t, repoPath, "rev-parse", base))
+	if err := DeleteRemoteBranch(repoPath, "origin", "feature", stale); err == nil {
+		t.Error("DeleteRemoteBranch should fail when the remote branch is not at the expected commit")
+	}
+	if output := runGit(t, remotePath, "branch", "--list", "feature"); output == "" {
+		t.Error("Expected the remote branch to be kept")
+	}
+
+	if err := DeleteRemoteBranch(repoPath, "origin", "feature", pushed); err != nil {
+		t.Fatalf("DeleteRemoteBranch failed: %v", err)
+	}
+	if output := runGit(t, remotePath, "branch", "--list", "feature"); output != "" {
+		t.Errorf("Expected the remote branch to be deleted, got %q", output)
+	}
+
+	if err := DeleteBranch(repoPath, "missing"); err == nil {
+		t.Error("DeleteBranch should fail for a missing branch")
+	}
+	if err := DeleteRemoteBranch(repoPath, "origin", "missing", pushed); err == nil {
+		t.Error("DeleteRemoteBranch should fail for a missing branch")
+	}
+}
+
 // Helper functions
 func createTestRepo(t *testing.T) string {
 	repoPath := t.TempDir()
@@ -0,0 +1,85 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ConflictError is returned by Rebase when commits of the branch conflict
+// with the new base. The rebase is aborted, so the branch is unchanged.
+type ConflictError struct {
+	Files []string
+}
+
+func (e *ConflictError) Error() string {
+	return "rebase conflicts in " + strings.Join(e.Files, ", ")
+}
+
+// Rebase rebases the checked out branch onto upstream, a branch or ref. On
+// failure the rebase is aborted.
+func Rebase(repoPath string, upstream string) error {
+	cmd := exec.Command("git", "rebase", upstream)
+	cmd.Dir = repoPath
+	output, err := run(cmd, nil)
+	if err == nil {
+		return nil
+	}
+
+	files, filesErr := conflictedFiles(repoPath)
+	abort := exec.Command("git", "rebase", "--abort")
+	abort.Dir = repoPath
+	if abortOutput, abortErr := run(abort, nil); abortErr != nil {
+		return fmt.Errorf("error aborting rebase: %w\n%s", abortErr, string(abortOutput))
+	}
+	if filesErr == nil && len(files) > 0 {
+		return &ConflictError{Files: files}
+	}
+	return fmt.Errorf("error rebasing onto %s: %w\n%s", upstream, err, string(output))
+}
+
+func conflictedFiles(repoPath string) ([]string, error) {
+	cmd := exec.Command("git", "diff", "--name-only", "--diff-filter=U")
+	cmd.Dir = repoPath
+	output, err := run(cmd, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error listing conflicts: %w\n%s", err, string(output))
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// IsAncestor reports whether the commit ref points to is reachable from the
+// other ref, so that nothing of ref is missing from it.
+func IsAncestor(repoPath string, ref string, of string) (bool, error) {
+	cmd := exec.Command("git", "merge-base", "--is-ancestor", ref, of)
+	cmd.Dir = repoPath
+	output, err := run(cmd, nil)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error comparing %s and %s: %w\n%s", ref, of, err, string(output))
+	}
+	return true, nil
+}
+
+// ForcePushChanges pushes the branch to the remote, replacing its history.
+// The push is refused unless the remote branch is at the expected commit,
+// or does not exist when expected is empty, so commits pushed since the
+// branch was checked are not lost.
+func ForcePushChanges(repoPath string, remote string, branch string, expected string, noVerify bool) (string, error) {
+	pushArgs := []string{"push", "--force-with-lease=" + branch + ":" + expected}
+	if noVerify {
+		pushArgs = append(pushArgs, "--no-verify")
+	}
+	pushArgs = append(pushArgs, "-u", remote, branch)
+	cmd := exec.Command("git", pushArgs...)
+	cmd.Dir = repoPath
+	output, err := runRetried(cmd)
+	if err != nil {
+		return string(output), fmt.Errorf("error force pushing changes: %w\n%s", err, string(output))
+	}
+	return string(output), nil
+}
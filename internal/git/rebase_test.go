@@ -0,0 +1,105 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRebase(t *testing.T) {
+	repoPath := createTestRepo(t)
+	base := currentBranch(t, repoPath)
+
+	runGit(t, repoPath, "checkout", "-b", "feature")
+	os.WriteFile(filepath.Join(repoPath, "a.txt"), []byte("feature"), 0644)
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Add a")
+	runGit(t, repoPath, "checkout", base)
+	os.WriteFile(filepath.Join(repoPath, "b.txt"), []byte("base"), 0644)
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Add b")
+
+	upToDate, err := IsAncestor(repoPath, base, "feature")
+	if err != nil {
+		t.Fatalf("IsAncestor failed: %v", err)
+	}
+	if upToDate {
+		t.Error("Expected the base branch to have moved past the feature branch")
+	}
+
+	runGit(t, repoPath, "checkout", "feature")
+	if err := Rebase(repoPath, base); err != nil {
+		t.Fatalf("Rebase failed: %v", err)
+	}
+	if upToDate, err = IsAncestor(repoPath, base, "feature"); err != nil || !upToDate {
+		t.Errorf("Expected the feature branch to contain the base branch, got %v, %v", upToDate, err)
+	}
+}
+
+func TestRebaseConflict(t *testing.T) {
+	repoPath := createTestRepo(t)
+	base := currentBranch(t, repoPath)
+
+	runGit(t, repoPath, "checkout", "-b", "feature")
+	os.WriteFile(filepath.Join(repoPath, "README.md"), []byte("feature"), 0644)
+	runGit(t, repoPath, "commit", "-am", "Change README on feature")
+	runGit(t, repoPath, "checkout", base)
+	os.WriteFile(filepath.Join(repoPath, "README.md"), []byte("base"), 0644)
+	runGit(t, repoPath, "commit", "-am", "Change README on base")
+	runGit(t, repoPath, "checkout", "feature")
+	head := runGit(t, repoPath, "rev-parse", "HEAD")
+
+	err := Rebase(repoPath, base)
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("Expected a conflict error, got %v", err)
+	}
+	if strings.Join(conflictErr.Files, ",") != "README.md" {
+		t.Errorf("Expected a conflict in README.md, got %v", conflictErr.Files)
+	}
+
+	// The rebase is aborted, leaving the branch as it was
+	if got := runGit(t, repoPath, "rev-parse", "HEAD"); got != head {
+		t.Errorf("Expected HEAD %s after the aborted rebase, got %s", head, got)
+	}
+	if status := runGit(t, repoPath, "status", "--porcelain"); status != "" {
+		t.Errorf("Expected a clean working tree, got %q", status)
+	}
+}
+
+func TestForcePushChanges(t *testing.T) {
+	repoPath := createTestRepo(t)
+	remotePath := t.TempDir()
+	runGit(t, remotePath, "init", "--bare")
+	runGit(t, repoPath, "remote", "add", "origin", remotePath)
+	runGit(t, repoPath, "checkout", "-b", "feature")
+	runGit(t, repoPath, "push", "origin", "feature")
+
+	// Rewrite the pushed commit, which a normal push refuses
+	runGit(t, repoPath, "commit", "--amend", "-m", "Rewritten commit")
+	if _, err := PushChanges(repoPath, "origin", "feature", false); err == nil {
+		t.Fatal("Expected a normal push of a rewritten branch to fail")
+	}
+	pushed := strings.TrimSpace(runGit(t, remotePath, "rev-parse", "feature"))
+	if _, err := ForcePushChanges(repoPath, "origin", "feature", "", false); err == nil {
+		t.Fatal("Expected the push to be refused when the remote branch exists but was expected not to")
+	}
+	if _, err := ForcePushChanges(repoPath, "origin", "feature", pushed, false); err != nil {
+		t.Fatalf("ForcePushChanges failed: %v", err)
+	}
+	if subject := runGit(t, remotePath, "log", "-1", "--format=%s", "feature"); strings.TrimSpace(subject) != "Rewritten commit" {
+		t.Errorf("Expected the rewritten commit on the remote, got %q", subject)
+	}
+
+	// The lease refuses the push when the remote branch moved since the
+	// expected commit was seen
+	runGit(t, repoPath, "commit", "--amend", "-m", "Rewritten again")
+	if _, err := ForcePushChanges(repoPath, "origin", "feature", pushed, false); err == nil {
+		t.Error("Expected the push to be refused when the remote branch moved")
+	}
+	if subject := runGit(t, remotePath, "log", "-1", "--format=%s", "feature"); strings.TrimSpace(subject) != "Rewritten commit" {
+		t.Errorf("Expected the remote branch to be kept, got %q", subject)
+	}
+}
@@ -0,0 +1,62 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// PushRemote returns the remote git pushes the branch to: the
+// branch.<name>.pushRemote setting, then remote.pushDefault, then origin.
+func PushRemote(repoPath string, branch string) (string, error) {
+	for _, key := range []string{"branch." + branch + ".pushRemote", "remote.pushDefault"} {
+		value, err := configValue(repoPath, key)
+		if err != nil {
+			return "", err
+		}
+		if value != "" {
+			return value, nil
+		}
+	}
+	return "origin", nil
+}
+
+// configValue returns the value of the git config key, or an empty string
+// when it is not set.
+func configValue(repoPath string, key string) (string, error) {
+	cmd := exec.Command("git", "config", "--get", key)
+	cmd.Dir = repoPath
+	output, err := run(cmd, nil)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading git config %s: %w\n%s", key, err, string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// EnsureRemote adds the remote with the URL, unless it already exists with
+// that URL. A remote of that name pointing elsewhere is an error, so that a
+// remote configured by hand is never changed.
+func EnsureRemote(repoPath string, name string, url string) error {
+	current, err := configValue(repoPath, "remote."+name+".url")
+	if err != nil {
+		return err
+	}
+	if current == url {
+		return nil
+	}
+	if current != "" {
+		return fmt.Errorf("error adding remote %s: it already points to %s", name, current)
+	}
+
+	cmd := exec.Command("git", "remote", "add", name, url)
+	cmd.Dir = repoPath
+	if output, err := run(cmd, nil); err != nil {
+		return fmt.Errorf("error adding remote %s: %w\n%s", name, err, string(output))
+	}
+	return nil
+}
@@ -2,45 +2,62 @@ package git
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
 	"regexp"
 	"runtime"
 	"strings"
 )
 
-var remoteURLPattern = regexp.MustCompile(`https?://\S+`)
+var (
+	remoteURLPattern = regexp.MustCompile(`https?://\S+`)
+	// Gerrit lists every uploaded change as its URL followed by the subject
+	reviewURLPattern = regexp.MustCompile(`^remote:\s+(https?://\S+)\s+\S`)
+)
 
-// OpenLastRemoteURL opens the last URL from git output in the default browser.
-func OpenLastRemoteURL(output string) error {
-	url := lastRemoteURL(output)
-	if url == "" {
-		return nil
+// OpenURL opens the URL with the browser command, which is split on spaces
+// and gets the URL as its last argument. An empty browser uses $BROWSER,
+// then open on macOS and xdg-open elsewhere.
+func OpenURL(browser string, url string) error {
+	if browser == "" {
+		browser = os.Getenv("BROWSER")
 	}
-
-	var cmd *exec.Cmd
-	if runtime.GOOS == "darwin" {
-		cmd = exec.Command("open", url)
-	} else {
-		cmd = exec.Command("xdg-open", url)
+	args := strings.Fields(browser)
+	if len(args) == 0 {
+		args = []string{"xdg-open"}
+		if runtime.GOOS == "darwin" {
+			args = []string{"open"}
+		}
 	}
+
+	cmd := exec.Command(args[0], append(args[1:], url)...)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("open browser failed: %w\n%s", err, string(output))
 	}
 	return nil
 }
 
-func lastRemoteURL(output string) string {
-	var lastMatch string
+// LastRemoteURL returns the last URL printed by the remote in git push
+// output, or an empty string. When the remote is Gerrit, it is the URL of
+// the last change listed, rather than of hints printed after it.
+func LastRemoteURL(output string) string {
+	var lastMatch, lastReview string
 	for line := range strings.SplitSeq(output, "\n") {
 		trimmed := strings.TrimLeft(line, " \t")
 		if !strings.HasPrefix(trimmed, "remote:") {
 			continue
 		}
+		if review := reviewURLPattern.FindStringSubmatch(trimmed); review != nil {
+			lastReview = review[1]
+		}
 		matches := remoteURLPattern.FindAllString(trimmed, -1)
 		if len(matches) == 0 {
 			continue
 		}
 		lastMatch = matches[len(matches)-1]
 	}
+	if lastReview != "" {
+		return lastReview
+	}
 	return lastMatch
 }
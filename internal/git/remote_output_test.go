@@ -1,6 +1,11 @@
 package git
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 func TestLastRemoteURL(t *testing.T) {
 	tests := []struct {
@@ -31,13 +36,67 @@ func TestLastRemoteURL(t *testing.T) {
 				"hint: https://example.com/ignored",
 			want: "https://example.com/second",
 		},
+		{
+			name: "gerrit_changes",
+			output: "remote: Processing changes: refs: 1, new: 2, done\n" +
+				"remote: \n" +
+				"remote: SUCCESS\n" +
+				"remote: \n" +
+				"remote:   https://review.example.com/c/repo/+/101 Update logging [NEW]\n" +
+				"remote:   https://review.example.com/c/repo/+/102 Bump dependencies [NEW]\n" +
+				"remote: \n" +
+				"remote: Hint: see https://review.example.com/Documentation/user-upload.html\n" +
+				"To ssh://review.example.com:29418/repo\n" +
+				" * [new reference]   HEAD -> refs/for/main",
+			want: "https://review.example.com/c/repo/+/102",
+		},
+		{
+			name: "url_only_lines_are_not_changes",
+			output: "remote: Create a pull request for 'feature' on GitHub by visiting:\n" +
+				"remote:      https://github.com/org/repo/pull/new/feature",
+			want: "https://github.com/org/repo/pull/new/feature",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := lastRemoteURL(tt.output); got != tt.want {
+			if got := LastRemoteURL(tt.output); got != tt.want {
 				t.Fatalf("LastRemoteURL() = %q, want %q", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestOpenURL(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "opened")
+	browser := filepath.Join(dir, "browser")
+	script := "#!/bin/sh\necho \"$@\" > \"" + marker + "\"\n"
+	if err := os.WriteFile(browser, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	opened := func() string {
+		t.Helper()
+		content, err := os.ReadFile(marker)
+		if err != nil {
+			t.Fatalf("Expected the browser to run: %v", err)
+		}
+		return strings.TrimSpace(string(content))
+	}
+
+	if err := OpenURL(browser+" --new-tab", "https://example.com/pull/1"); err != nil {
+		t.Fatalf("OpenURL failed: %v", err)
+	}
+	if got := opened(); got != "--new-tab https://example.com/pull/1" {
+		t.Errorf("Expected the browser arguments followed by the URL, got %q", got)
+	}
+
+	t.Setenv("BROWSER", browser)
+	if err := OpenURL("", "https://example.com/pull/2"); err != nil {
+		t.Fatalf("OpenURL failed: %v", err)
+	}
+	if got := opened(); got != "https://example.com/pull/2" {
+		t.Errorf("Expected $BROWSER to open the URL, got %q", got)
+	}
+}
@@ -0,0 +1,59 @@
+package git
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPushRemote(t *testing.T) {
+	repoPath := createTestRepo(t)
+
+	tests := []struct {
+		name   string
+		config [][]string
+		want   string
+	}{
+		{name: "origin by default", want: "origin"},
+		{name: "push default", config: [][]string{{"remote.pushDefault", "mine"}}, want: "mine"},
+		{name: "branch push remote", config: [][]string{{"branch.feature.pushRemote", "fork"}}, want: "fork"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, kv := range tt.config {
+				runGit(t, repoPath, "config", kv[0], kv[1])
+			}
+			remote, err := PushRemote(repoPath, "feature")
+			if err != nil {
+				t.Fatalf("PushRemote failed: %v", err)
+			}
+			if remote != tt.want {
+				t.Errorf("Expected remote %q, got %q", tt.want, remote)
+			}
+		})
+	}
+
+	// The branch setting wins over the default of every branch
+	if remote, _ := PushRemote(repoPath, "other"); remote != "mine" {
+		t.Errorf("Expected remote.pushDefault for other branches, got %q", remote)
+	}
+}
+
+func TestEnsureRemote(t *testing.T) {
+	repoPath := createTestRepo(t)
+
+	if err := EnsureRemote(repoPath, "fork", "git@example.com:me/repo.git"); err != nil {
+		t.Fatalf("EnsureRemote failed: %v", err)
+	}
+	if url := strings.TrimSpace(runGit(t, repoPath, "remote", "get-url", "fork")); url != "git@example.com:me/repo.git" {
+		t.Errorf("Expected the fork remote to be added, got %q", url)
+	}
+
+	// Adding it again is fine, pointing it elsewhere is not
+	if err := EnsureRemote(repoPath, "fork", "git@example.com:me/repo.git"); err != nil {
+		t.Errorf("EnsureRemote failed for an existing remote: %v", err)
+	}
+	err := EnsureRemote(repoPath, "fork", "git@example.com:other/repo.git")
+	if err == nil || !strings.Contains(err.Error(), "already points to git@example.com:me/repo.git") {
+		t.Errorf("Expected an error for a remote with another URL, got %v", err)
+	}
+}
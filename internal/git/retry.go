@@ -0,0 +1,104 @@
+package git
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os/exec"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// RetryPolicy sets how fetches, pulls and pushes are retried after
+// transient errors, like connection resets, server errors of the host or
+// lock files left by a concurrent git process.
+type RetryPolicy struct {
+	// Retries is the number of attempts after the first one, 0 disables
+	// retries.
+	Retries int
+	// Delay is the delay before the first retry. It doubles for every
+	// further retry, up to MaxDelay, and is randomized by up to half.
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+var (
+	retryMu     sync.RWMutex
+	retryPolicy RetryPolicy
+
+	// sleep is replaced in tests.
+	sleep = time.Sleep
+)
+
+// SetRetryPolicy sets the policy of all network commands.
+func SetRetryPolicy(p RetryPolicy) {
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	retryPolicy = p
+}
+
+// transientPattern matches the output of git commands that may succeed when
+// run again. Of the lock errors only a lock file held by another git process
+// is transient; a ref that is not at the expected commit is not, and neither
+// is an RPC failure with a client error status.
+var transientPattern = regexp.MustCompile(`(?i)connection reset|connection timed out|operation timed out|` +
+	`could not resolve host|temporary failure in name resolution|remote end hung up unexpectedly|early eof|` +
+	`unexpected disconnect|gnutls|ssl_read|` +
+	`\b(?:http|error:?|returned error:) 5\d\d\b|internal server error|bad gateway|service unavailable|gateway time-?out|` +
+	`unable to create '[^']*\.lock': file exists`)
+
+// isTransient reports whether the output of a failed command points to an
+// error that may go away when it is run again.
+func isTransient(output string) bool {
+	return transientPattern.MatchString(output)
+}
+
+// runRetried runs the command like run, and runs it again after transient
+// errors as the retry policy says. Every attempt is reported to the
+// observer with its number and the delay before the next one.
+func runRetried(cmd *exec.Cmd) ([]byte, error) {
+	retryMu.RLock()
+	policy := retryPolicy
+	retryMu.RUnlock()
+
+	for attempt := 1; ; attempt++ {
+		rec, output, err := execute(cmd, nil)
+		rec.Attempt = attempt
+		if err == nil || attempt > policy.Retries || !isTransient(string(output)) {
+			notify(rec)
+			if err != nil && attempt > 1 {
+				err = fmt.Errorf("%w (after %d attempts)", err, attempt)
+			}
+			return output, err
+		}
+
+		rec.Retried = true
+		rec.RetryDelay = backoff(policy, attempt)
+		notify(rec)
+		sleep(rec.RetryDelay)
+
+		// A command runs only once, so the next attempt gets a copy
+		next := exec.Command(cmd.Args[0], cmd.Args[1:]...)
+		next.Dir = cmd.Dir
+		next.Env = cmd.Env
+		cmd = next
+	}
+}
+
+// backoff returns the delay before the retry after the attempt: the delay
+// of the policy doubled for every earlier retry, capped at its maximum,
+// of which a random half is taken away so that repositories retrying at the
+// same time spread out.
+func backoff(policy RetryPolicy, attempt int) time.Duration {
+	delay := policy.Delay
+	for i := 1; i < attempt && (policy.MaxDelay == 0 || delay < policy.MaxDelay); i++ {
+		delay *= 2
+	}
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	if delay <= 1 {
+		return delay
+	}
+	return delay - time.Duration(rand.Int64N(int64(delay/2)+1))
+}
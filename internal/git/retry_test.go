@@ -0,0 +1,111 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		output string
+		want   bool
+	}{
+		{"fatal: unable to access 'https://example.com/repo.git/': Connection reset by peer", true},
+		{"ssh: Could not resolve hostname example.com: Temporary failure in name resolution", true},
+		{"fatal: unable to access 'https://example.com/repo.git/': Could not resolve host: example.com", true},
+		{"error: RPC failed; HTTP 502 curl 22 The requested URL returned error: 502", true},
+		{"fatal: unable to access 'https://example.com/repo.git/': The requested URL returned error: 503", true},
+		{"remote: Service Unavailable\nfatal: the remote end hung up unexpectedly", true},
+		{"fatal: Unable to create '/repo/.git/index.lock': File exists.", true},
+		{"error: cannot lock ref 'refs/remotes/origin/main': Unable to create '/repo/.git/refs/remotes/origin/main.lock': File exists.", true},
+		{"error: cannot lock ref 'refs/remotes/origin/main': is at abc but expected def", false},
+		{"error: cannot lock ref 'refs/heads/feature/x': 'refs/heads/feature' exists; cannot create 'refs/heads/feature/x'", false},
+		{"error: RPC failed; HTTP 413 curl 22 The requested URL returned error: 413", false},
+		{"error: RPC failed; HTTP 403 curl 22 The requested URL returned error: 403", false},
+		{"remote: Permission to org/repo.git denied to user.\nfatal: unable to access: The requested URL returned error: 403", false},
+		{"! [rejected]        feature -> feature (non-fast-forward)", false},
+		{"fatal: Not possible to fast-forward, aborting.", false},
+		{"fatal: couldn't find remote ref feature", false},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.output); got != tt.want {
+			t.Errorf("isTransient(%q) = %v, want %v", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{Retries: 5, Delay: time.Second, MaxDelay: 5 * time.Second}
+	for attempt, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		for range 20 {
+			delay := backoff(policy, attempt+1)
+			if delay < max/2 || delay > max {
+				t.Fatalf("backoff after attempt %d = %s, want between %s and %s", attempt+1, delay, max/2, max)
+			}
+		}
+	}
+}
+
+func TestRunRetried(t *testing.T) {
+	var delays []time.Duration
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	defer func() { sleep = time.Sleep }()
+	SetRetryPolicy(RetryPolicy{Retries: 2, Delay: time.Second, MaxDelay: time.Minute})
+	defer SetRetryPolicy(RetryPolicy{})
+	var records []CommandRecord
+	SetObserver(func(rec CommandRecord) { records = append(records, rec) })
+	defer SetObserver(nil)
+
+	// The remote rejects the first pushes as if it was unavailable
+	repoPath := createTestRepo(t)
+	remotePath := t.TempDir()
+	runGit(t, remotePath, "init", "--bare")
+	hook := "#!/bin/sh\nn=$(cat attempts 2>/dev/null || echo 0)\nn=$((n+1))\necho $n > attempts\n" +
+		"if [ $n -lt $FAILURES ]; then echo 'Service Unavailable'; exit 1; fi\n"
+	if err := os.WriteFile(filepath.Join(remotePath, "hooks", "pre-receive"), []byte(hook), 0755); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, repoPath, "remote", "add", "origin", remotePath)
+	branch := currentBranch(t, repoPath)
+
+	t.Setenv("FAILURES", "3")
+	if _, err := PushChanges(repoPath, "origin", branch, false); err != nil {
+		t.Fatalf("Expected the push to succeed on the third attempt: %v", err)
+	}
+	if len(records) != 3 || len(delays) != 2 {
+		t.Fatalf("Expected 3 attempts and 2 delays, got %d and %d", len(records), len(delays))
+	}
+	for i, rec := range records {
+		if rec.Attempt != i+1 {
+			t.Errorf("Expected attempt %d, got %d", i+1, rec.Attempt)
+		}
+		if i < 2 && (!rec.Retried || rec.RetryDelay != delays[i] || rec.ExitCode == 0) {
+			t.Errorf("Expected failed attempt %d to record the delay %s, got %+v", i+1, delays[i], rec)
+		}
+	}
+	if records[2].Retried || records[2].RetryDelay != 0 || records[2].ExitCode != 0 {
+		t.Errorf("Expected the last attempt to succeed without delay, got %+v", records[2])
+	}
+
+	// Retries run out
+	runGit(t, repoPath, "commit", "--allow-empty", "-m", "Another commit")
+	os.Remove(filepath.Join(remotePath, "attempts"))
+	records = nil
+	t.Setenv("FAILURES", "10")
+	_, err := PushChanges(repoPath, "origin", branch, false)
+	if err == nil || !strings.Contains(err.Error(), "(after 3 attempts)") {
+		t.Errorf("Expected the push to fail after 3 attempts, got %v", err)
+	}
+
+	// Errors that are not transient are not retried
+	records = nil
+	if err := Fetch(repoPath, "missing"); err == nil {
+		t.Fatal("Expected a fetch from a missing remote to fail")
+	}
+	if len(records) != 1 || records[0].Attempt != 1 || records[0].InRetry() {
+		t.Errorf("Expected a single attempt, got %+v", records)
+	}
+}
@@ -0,0 +1,97 @@
+package git
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// CommandRecord describes a command run in a repository.
+type CommandRecord struct {
+	// Dir is the directory the command ran in, as passed by the caller.
+	Dir      string
+	Args     []string
+	Start    time.Time
+	Duration time.Duration
+	// ExitCode is -1 when the command could not be started.
+	ExitCode int
+	Output   string
+	// Attempt is the number of the attempt of a command that is retried
+	// after transient errors, starting at 1, and 0 for other commands.
+	Attempt int
+	// Retried is set when the attempt failed with a transient error and the
+	// command is run again after RetryDelay.
+	Retried    bool
+	RetryDelay time.Duration
+}
+
+// InRetry reports whether the record is an attempt of a command that was
+// retried, either one that failed and is run again or a later attempt.
+func (r CommandRecord) InRetry() bool {
+	return r.Retried || r.Attempt > 1
+}
+
+// Observer is notified after every command run by this package.
+type Observer func(CommandRecord)
+
+var (
+	observerMu sync.RWMutex
+	observer   Observer
+)
+
+// SetObserver installs the observer for all commands, or removes it when nil.
+func SetObserver(o Observer) {
+	observerMu.Lock()
+	defer observerMu.Unlock()
+	observer = o
+}
+
+// run runs the command and returns its combined output, copying it to the
+// output writer as it is produced when that is not nil.
+func run(cmd *exec.Cmd, output io.Writer) ([]byte, error) {
+	rec, out, err := execute(cmd, output)
+	notify(rec)
+	return out, err
+}
+
+// execute runs the command and describes it, without notifying the
+// observer.
+func execute(cmd *exec.Cmd, output io.Writer) (CommandRecord, []byte, error) {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	if output != nil {
+		w = io.MultiWriter(&buf, output)
+	}
+	// A single writer for both streams makes exec serialize the writes
+	cmd.Stdout = w
+	cmd.Stderr = w
+
+	start := time.Now()
+	err := cmd.Run()
+	duration := time.Since(start)
+
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+	rec := CommandRecord{
+		Dir:      cmd.Dir,
+		Args:     cmd.Args,
+		Start:    start,
+		Duration: duration,
+		ExitCode: exitCode,
+		Output:   buf.String(),
+	}
+	return rec, buf.Bytes(), err
+}
+
+func notify(rec CommandRecord) {
+	observerMu.RLock()
+	o := observer
+	observerMu.RUnlock()
+	if o != nil {
+		o(rec)
+	}
+}
@@ -0,0 +1,45 @@
+package git
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetObserver(t *testing.T) {
+	repoPath := createTestRepo(t)
+
+	var records []CommandRecord
+	SetObserver(func(rec CommandRecord) {
+		records = append(records, rec)
+	})
+	defer SetObserver(nil)
+
+	if _, err := CurrentBranch(repoPath); err != nil {
+		t.Fatalf("CurrentBranch failed: %v", err)
+	}
+	_ = ExecuteCommand(repoPath, "echo failing; exit 3", ExecOptions{})
+
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	if got := strings.Join(records[0].Args, " "); got != "git branch --show-current" {
+		t.Errorf("Unexpected args %q", got)
+	}
+	if records[0].Dir != repoPath || records[0].ExitCode != 0 {
+		t.Errorf("Unexpected record %+v", records[0])
+	}
+	if records[1].ExitCode != 3 || records[1].Output != "failing\n" {
+		t.Errorf("Unexpected record %+v", records[1])
+	}
+	if records[1].Start.IsZero() || records[1].Duration <= 0 {
+		t.Errorf("Expected timing in record %+v", records[1])
+	}
+
+	SetObserver(nil)
+	if _, err := CurrentBranch(repoPath); err != nil {
+		t.Fatalf("CurrentBranch failed: %v", err)
+	}
+	if len(records) != 2 {
+		t.Errorf("Expected no records after removing the observer, got %d", len(records))
+	}
+}
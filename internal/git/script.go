@@ -0,0 +1,76 @@
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// extensionInterpreters are used for scripts without the executable bit.
+var extensionInterpreters = map[string][]string{
+	".go": {"go", "run"},
+	".py": {"python3"},
+	".js": {"node"},
+	".sh": {"sh"},
+}
+
+// ScriptCommand returns the command line that runs the script. An explicit
+// interpreter is always used. Otherwise Go files run with `go run`, executable
+// scripts run directly, and other scripts run through their shebang line or
+// the interpreter for their extension.
+func ScriptCommand(scriptPath string, interpreter string) ([]string, error) {
+	if interpreter != "" {
+		fields := strings.Fields(interpreter)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("interpreter is empty")
+		}
+		return append(fields, scriptPath), nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(scriptPath))
+	if ext == ".go" {
+		return append(append([]string{}, extensionInterpreters[ext]...), scriptPath), nil
+	}
+
+	info, err := os.Stat(scriptPath)
+	if err != nil {
+		return nil, fmt.Errorf("error accessing script file: %w", err)
+	}
+	if info.Mode()&0111 != 0 {
+		return []string{scriptPath}, nil
+	}
+
+	shebang, err := readShebang(scriptPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(shebang) > 0 {
+		return append(shebang, scriptPath), nil
+	}
+	if command, ok := extensionInterpreters[ext]; ok {
+		return append(append([]string{}, command...), scriptPath), nil
+	}
+
+	return nil, fmt.Errorf("script file is not executable and has no shebang line or known extension, use --interpreter: %s", scriptPath)
+}
+
+// readShebang returns the interpreter and arguments from the first line of
+// the script, or nil if it has no shebang line.
+func readShebang(scriptPath string) ([]string, error) {
+	file, err := os.Open(scriptPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading script file: %w", err)
+	}
+	defer file.Close()
+
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && line == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(line, "#!") {
+		return nil, nil
+	}
+	return strings.Fields(strings.TrimPrefix(line, "#!")), nil
+}
@@ -0,0 +1,80 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScriptCommand(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string, mode os.FileMode) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	executable := write("run", "#!/bin/sh\necho test\n", 0755)
+	shebang := write("update", "#!/usr/bin/env python3 -u\nprint('test')\n", 0644)
+	python := write("update.py", "print('test')\n", 0644)
+	node := write("update.js", "console.log('test')\n", 0644)
+	goFile := write("main.go", "package main\n", 0755)
+	unknown := write("update.txt", "echo test\n", 0644)
+
+	tests := []struct {
+		name        string
+		path        string
+		interpreter string
+		want        []string
+		wantErr     bool
+	}{
+		{name: "executable", path: executable, want: []string{executable}},
+		{name: "explicit interpreter", path: executable, interpreter: "bash -e", want: []string{"bash", "-e", executable}},
+		{name: "shebang", path: shebang, want: []string{"/usr/bin/env", "python3", "-u", shebang}},
+		{name: "python", path: python, want: []string{"python3", python}},
+		{name: "node", path: node, want: []string{"node", node}},
+		{name: "go", path: goFile, want: []string{"go", "run", goFile}},
+		{name: "unknown", path: unknown, wantErr: true},
+		{name: "missing", path: filepath.Join(dir, "missing"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ScriptCommand(tt.path, tt.interpreter)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ScriptCommand() = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ScriptCommand() failed: %v", err)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("ScriptCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteScriptWithoutExecutableBit(t *testing.T) {
+	repoPath := createTestRepo(t)
+	scriptPath := filepath.Join(t.TempDir(), "update.sh")
+	if err := os.WriteFile(scriptPath, []byte("echo \"$1\" > out.txt\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ExecuteScript(repoPath, scriptPath, ExecOptions{Args: []string{"sh"}}); err != nil {
+		t.Fatalf("ExecuteScript failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(repoPath, "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "sh\n" {
+		t.Errorf("Expected output %q, got %q", "sh\n", string(content))
+	}
+}
@@ -0,0 +1,240 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// BranchStatus describes the state of a campaign branch in a repository.
+type BranchStatus struct {
+	Branch string
+	// Base is the branch the campaign branch is compared against.
+	Base string
+	// Local and Remote report whether the branch exists locally and as a
+	// remote-tracking branch of the remote it is pushed to.
+	Local  bool
+	Remote bool
+	// LocalSHA and RemoteSHA are the commits the local branch and the
+	// remote-tracking branch point to, when they exist.
+	LocalSHA  string
+	RemoteSHA string
+	// Ahead and Behind count the commits of the branch missing from the base
+	// branch and the other way around.
+	Ahead      int
+	Behind     int
+	CheckedOut bool
+	Dirty      bool
+	// LastCommit is the abbreviated SHA, subject and age of the last commit
+	// on the branch.
+	LastCommit string
+}
+
+// Exists reports whether the branch exists locally or on the remote.
+func (s BranchStatus) Exists() bool {
+	return s.Local || s.Remote
+}
+
+// Fetch updates the remote-tracking branches of the remote.
+func Fetch(repoPath string, remote string) error {
+	cmd := exec.Command("git", "fetch", "--prune", remote)
+	cmd.Dir = repoPath
+	if output, err := runRetried(cmd); err != nil {
+		return fmt.Errorf("error fetching from remote: %w\n%s", err, string(output))
+	}
+	return nil
+}
+
+// RefExists reports whether the fully qualified ref, like refs/heads/main,
+// exists.
+func RefExists(repoPath string, ref string) (bool, error) {
+	cmd := exec.Command("git", "show-ref", "--verify", "--quiet", ref)
+	cmd.Dir = repoPath
+	output, err := run(cmd, nil)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error checking ref %s: %w\n%s", ref, err, string(output))
+	}
+	return true, nil
+}
+
+// DefaultBranch returns the branch origin/HEAD points to, falling back to a
+// local main or master branch.
+func DefaultBranch(repoPath string) (string, error) {
+	cmd := exec.Command("git", "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+	cmd.Dir = repoPath
+	if output, err := run(cmd, nil); err == nil {
+		return strings.TrimPrefix(strings.TrimSpace(string(output)), "origin/"), nil
+	}
+
+	for _, name := range []string{"main", "master"} {
+		exists, err := RefExists(repoPath, "refs/heads/"+name)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("error detecting default branch: origin/HEAD is not set and there is no main or master branch")
+}
+
+// GetBranchStatus returns the state of the branch compared to the base
+// branch, or to the default branch when base is empty. The branch is looked
+// up on the remote it is pushed to and the base branch on origin. Remote
+// state comes from the remote-tracking branches, so it is as fresh as the
+// last fetch.
+func GetBranchStatus(repoPath string, remote string, branch string, base string) (BranchStatus, error) {
+	status := BranchStatus{Branch: branch, Base: base}
+
+	var err error
+	if status.Base == "" {
+		if status.Base, err = DefaultBranch(repoPath); err != nil {
+			return status, err
+		}
+	}
+	if status.Local, err = RefExists(repoPath, "refs/heads/"+branch); err != nil {
+		return status, err
+	}
+	if status.Remote, err = RefExists(repoPath, "refs/remotes/"+remote+"/"+branch); err != nil {
+		return status, err
+	}
+
+	if status.Local {
+		if status.LocalSHA, err = RefCommit(repoPath, "refs/heads/"+branch); err != nil {
+			return status, err
+		}
+	}
+	if status.Remote {
+		if status.RemoteSHA, err = RefCommit(repoPath, "refs/remotes/"+remote+"/"+branch); err != nil {
+			return status, err
+		}
+	}
+
+	current, err := CurrentBranch(repoPath)
+	if err != nil {
+		return status, err
+	}
+	status.CheckedOut = current == branch
+	if status.Dirty, err = HasChanges(repoPath); err != nil {
+		return status, err
+	}
+
+	if !status.Exists() {
+		return status, nil
+	}
+	branchRef := "refs/heads/" + branch
+	if !status.Local {
+		branchRef = "refs/remotes/" + remote + "/" + branch
+	}
+	baseRef, err := resolveBranch(repoPath, status.Base)
+	if err != nil {
+		return status, err
+	}
+	if status.Ahead, status.Behind, err = aheadBehind(repoPath, branchRef, baseRef); err != nil {
+		return status, err
+	}
+	if status.LastCommit, err = lastCommit(repoPath, branchRef); err != nil {
+		return status, err
+	}
+	return status, nil
+}
+
+// MergedInto reports whether every commit of the ref, like refs/heads/feat
+// or refs/remotes/origin/feat, is in the base branch, locally or on origin.
+// The local branch and its remote-tracking branch can point to different
+// commits, so each is checked on its own.
+func MergedInto(repoPath string, ref string, base string) (bool, error) {
+	for _, baseRef := range []string{"refs/heads/" + base, "refs/remotes/origin/" + base} {
+		exists, err := RefExists(repoPath, baseRef)
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			continue
+		}
+		merged, err := IsAncestor(repoPath, ref, baseRef)
+		if err != nil {
+			return false, err
+		}
+		if merged {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// resolveBranch returns the local ref of the branch, or its remote-tracking
+// ref when there is no local branch.
+func resolveBranch(repoPath string, branch string) (string, error) {
+	for _, ref := range []string{"refs/heads/" + branch, "refs/remotes/origin/" + branch} {
+		exists, err := RefExists(repoPath, ref)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return ref, nil
+		}
+	}
+	return "", fmt.Errorf("base branch %s not found", branch)
+}
+
+// RefCommit returns the SHA of the commit the ref points to.
+func RefCommit(repoPath string, ref string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--verify", ref+"^{commit}")
+	cmd.Dir = repoPath
+	output, err := run(cmd, nil)
+	if err != nil {
+		return "", fmt.Errorf("error resolving %s: %w\n%s", ref, err, string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func aheadBehind(repoPath string, ref string, baseRef string) (int, int, error) {
+	cmd := exec.Command("git", "rev-list", "--left-right", "--count", ref+"..."+baseRef)
+	cmd.Dir = repoPath
+	output, err := run(cmd, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error comparing branches: %w\n%s", err, string(output))
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("error comparing branches: unexpected output %q", string(output))
+	}
+	ahead, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("error comparing branches: %w", err)
+	}
+	behind, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("error comparing branches: %w", err)
+	}
+	return ahead, behind, nil
+}
+
+func lastCommit(repoPath string, ref string) (string, error) {
+	cmd := exec.Command("git", "log", "-1", "--format=%h %s (%cr)", ref)
+	cmd.Dir = repoPath
+	output, err := run(cmd, nil)
+	if err != nil {
+		return "", fmt.Errorf("error getting last commit: %w\n%s", err, string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// DiffStat returns the git diff --shortstat summary of the changes of head
+// since it forked from base, like "2 files changed, 5 insertions(+)".
+func DiffStat(repoPath string, base string, head string) (string, error) {
+	cmd := exec.Command("git", "diff", "--shortstat", base+"..."+head)
+	cmd.Dir = repoPath
+	output, err := run(cmd, nil)
+	if err != nil {
+		return "", fmt.Errorf("error computing diffstat: %w\n%s", err, string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
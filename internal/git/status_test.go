@@ -0,0 +1,192 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetBranchStatus(t *testing.T) {
+	repoPath := createTestRepo(t)
+	base := currentBranch(t, repoPath)
+	remotePath := t.TempDir()
+	runGit(t, remotePath, "init", "--bare")
+	runGit(t, repoPath, "remote", "add", "origin", remotePath)
+	runGit(t, repoPath, "push", "origin", base)
+
+	status, err := GetBranchStatus(repoPath, "origin", "feature", "")
+	if err != nil {
+		t.Fatalf("GetBranchStatus failed: %v", err)
+	}
+	if status.Base != base || status.Exists() || status.Dirty || status.LastCommit != "" {
+		t.Errorf("Unexpected status for a missing branch: %+v", status)
+	}
+
+	// Two commits on the branch, one of them pushed, and one on the base
+	runGit(t, repoPath, "checkout", "-b", "feature")
+	os.WriteFile(filepath.Join(repoPath, "a.txt"), []byte("a"), 0644)
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Add a")
+	runGit(t, repoPath, "push", "origin", "feature")
+	os.WriteFile(filepath.Join(repoPath, "b.txt"), []byte("b"), 0644)
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Add b")
+	runGit(t, repoPath, "checkout", base)
+	os.WriteFile(filepath.Join(repoPath, "c.txt"), []byte("c"), 0644)
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Add c")
+	runGit(t, repoPath, "checkout", "feature")
+	os.WriteFile(filepath.Join(repoPath, "dirty.txt"), []byte("dirty"), 0644)
+
+	status, err = GetBranchStatus(repoPath, "origin", "feature", base)
+	if err != nil {
+		t.Fatalf("GetBranchStatus failed: %v", err)
+	}
+	if !status.Local || !status.Remote || !status.CheckedOut || !status.Dirty {
+		t.Errorf("Unexpected status flags: %+v", status)
+	}
+	if status.LocalSHA != strings.TrimSpace(runGit(t, repoPath, "rev-parse", "feature")) || status.RemoteSHA != strings.TrimSpace(runGit(t, repoPath, "rev-parse", "origin/feature")) {
+		t.Errorf("Unexpected commits %s and %s of the local and remote branch", status.LocalSHA, status.RemoteSHA)
+	}
+	if status.Ahead != 2 || status.Behind != 1 {
+		t.Errorf("Expected 2 ahead and 1 behind, got %d ahead and %d behind", status.Ahead, status.Behind)
+	}
+	if !strings.Contains(status.LastCommit, "Add b") {
+		t.Errorf("Expected last commit 'Add b', got %q", status.LastCommit)
+	}
+
+	// A branch that only exists on the remote is compared through origin
+	runGit(t, repoPath, "checkout", base)
+	runGit(t, repoPath, "branch", "-D", "feature")
+	status, err = GetBranchStatus(repoPath, "origin", "feature", base)
+	if err != nil {
+		t.Fatalf("GetBranchStatus failed: %v", err)
+	}
+	if status.Local || !status.Remote || status.CheckedOut || status.Ahead != 1 || !strings.Contains(status.LastCommit, "Add a") {
+		t.Errorf("Unexpected status for a remote branch: %+v", status)
+	}
+
+	// A branch pushed to a fork is looked up there, the base branch on origin
+	forkPath := t.TempDir()
+	runGit(t, forkPath, "init", "--bare")
+	runGit(t, repoPath, "remote", "add", "fork", forkPath)
+	runGit(t, repoPath, "push", "fork", "refs/remotes/origin/feature:refs/heads/feature")
+	runGit(t, repoPath, "push", "origin", "--delete", "feature")
+	runGit(t, repoPath, "fetch", "fork")
+	status, err = GetBranchStatus(repoPath, "fork", "feature", base)
+	if err != nil {
+		t.Fatalf("GetBranchStatus failed: %v", err)
+	}
+	if status.Local || !status.Remote || status.Ahead != 1 || !strings.Contains(status.LastCommit, "Add a") {
+		t.Errorf("Unexpected status for a branch on a fork: %+v", status)
+	}
+	if status, err = GetBranchStatus(repoPath, "origin", "feature", base); err != nil || status.Exists() {
+		t.Errorf("Expected the branch to be missing on origin, got %+v, %v", status, err)
+	}
+
+	if _, err := GetBranchStatus(repoPath, "fork", "feature", "missing"); err == nil {
+		t.Error("Expected error for a missing base branch")
+	}
+}
+
+func TestDefaultBranch(t *testing.T) {
+	repoPath := createTestRepo(t)
+	runGit(t, repoPath, "branch", "-m", "main")
+
+	branch, err := DefaultBranch(repoPath)
+	if err != nil || branch != "main" {
+		t.Errorf("DefaultBranch() = %q, %v, want main", branch, err)
+	}
+
+	runGit(t, repoPath, "update-ref", "refs/remotes/origin/trunk", "HEAD")
+	runGit(t, repoPath, "symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/trunk")
+	branch, err = DefaultBranch(repoPath)
+	if err != nil || branch != "trunk" {
+		t.Errorf("DefaultBranch() = %q, %v, want trunk", branch, err)
+	}
+
+	runGit(t, repoPath, "symbolic-ref", "--delete", "refs/remotes/origin/HEAD")
+	runGit(t, repoPath, "branch", "-m", "develop")
+	if _, err := DefaultBranch(repoPath); err == nil {
+		t.Error("Expected error without a default branch")
+	}
+}
+
+func TestMergedInto(t *testing.T) {
+	repoPath := createTestRepo(t)
+	base := currentBranch(t, repoPath)
+	remotePath := t.TempDir()
+	runGit(t, remotePath, "init", "--bare")
+	runGit(t, repoPath, "remote", "add", "origin", remotePath)
+
+	runGit(t, repoPath, "checkout", "-b", "feature")
+	os.WriteFile(filepath.Join(repoPath, "a.txt"), []byte("a"), 0644)
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Add a")
+	runGit(t, repoPath, "checkout", base)
+
+	merged, err := MergedInto(repoPath, "refs/heads/feature", base)
+	if err != nil {
+		t.Fatalf("MergedInto failed: %v", err)
+	}
+	if merged {
+		t.Error("Expected the branch not to be merged")
+	}
+
+	// Merged on origin, while the local base branch is behind
+	runGit(t, repoPath, "push", "origin", "feature:"+base)
+	runGit(t, repoPath, "fetch", "origin")
+	merged, err = MergedInto(repoPath, "refs/heads/feature", base)
+	if err != nil {
+		t.Fatalf("MergedInto failed: %v", err)
+	}
+	if !merged {
+		t.Error("Expected the branch to be merged on origin")
+	}
+
+	// The remote branch got another commit, while the local one is merged
+	runGit(t, repoPath, "checkout", "-b", "review", "feature")
+	os.WriteFile(filepath.Join(repoPath, "b.txt"), []byte("b"), 0644)
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Add b")
+	runGit(t, repoPath, "push", "origin", "review:feature")
+	runGit(t, repoPath, "checkout", base)
+	runGit(t, repoPath, "fetch", "origin")
+	if merged, err := MergedInto(repoPath, "refs/remotes/origin/feature", base); err != nil || merged {
+		t.Errorf("MergedInto() of the remote branch = %v, %v, want not merged", merged, err)
+	}
+	if merged, err := MergedInto(repoPath, "refs/heads/feature", base); err != nil || !merged {
+		t.Errorf("MergedInto() of the local branch = %v, %v, want merged", merged, err)
+	}
+}
+
+func TestDiffStat(t *testing.T) {
+	repoPath := createTestRepo(t)
+	base := currentBranch(t, repoPath)
+
+	runGit(t, repoPath, "checkout", "-b", "feature")
+	os.WriteFile(filepath.Join(repoPath, "a.txt"), []byte("a\nb\n"), 0644)
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Add a")
+	// Commits on the base branch after the fork are not counted
+	runGit(t, repoPath, "checkout", base)
+	os.WriteFile(filepath.Join(repoPath, "b.txt"), []byte("b\n"), 0644)
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Add b")
+
+	stat, err := DiffStat(repoPath, base, "feature")
+	if err != nil {
+		t.Fatalf("DiffStat failed: %v", err)
+	}
+	if stat != "1 file changed, 2 insertions(+)" {
+		t.Errorf("Expected the changes of the feature branch, got %q", stat)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	repoPath := createTestRepo(t)
+	if err := Fetch(repoPath, "origin"); err == nil {
+		t.Error("Expected error when fetching a missing remote")
+	}
+}
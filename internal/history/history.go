@@ -0,0 +1,179 @@
+// Package history keeps a record of past apply runs in the user's state
+// directory, so that campaigns can be inspected and replayed later.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Run is the record of a single apply run.
+type Run struct {
+	ID       string    `json:"id"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	// Dir is the working directory the run was started from, relative paths
+	// in Args and Repos are resolved against it.
+	Dir string `json:"dir"`
+	// Args are the apply flags set on the command line, in the --name=value
+	// form.
+	Args      []string `json:"args"`
+	Branch    string   `json:"branch"`
+	Message   string   `json:"message,omitempty"`
+	LogPath   string   `json:"log_path,omitempty"`
+	RunLogDir string   `json:"run_log_dir,omitempty"`
+	Repos     []Repo   `json:"repos"`
+}
+
+// Repo is the result of a run for a single repository.
+type Repo struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Branch is the branch name rendered for the repository.
+	Branch string `json:"branch,omitempty"`
+	Commit string `json:"commit,omitempty"`
+	PRURL  string `json:"pr_url,omitempty"`
+}
+
+// Failed returns the number of repositories that failed.
+func (r Run) Failed() int {
+	failed := 0
+	for _, repo := range r.Repos {
+		if repo.Status != "ok" {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Dir returns the cascade state directory, $XDG_STATE_HOME/cascade or
+// ~/.local/state/cascade when XDG_STATE_HOME is not set.
+func Dir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "cascade"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error finding state directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "state", "cascade"), nil
+}
+
+// Store keeps runs as JSON files in a directory.
+type Store struct {
+	dir string
+
+	// Warn is called with the error of every run that List skips because
+	// it cannot be read or decoded.
+	Warn func(err error)
+}
+
+// Open returns the store in the cascade state directory.
+func Open() (*Store, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(filepath.Join(dir, "runs")), nil
+}
+
+// NewStore returns a store keeping runs in dir.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Save writes the run, replacing an earlier record with the same ID.
+func (s *Store) Save(run Run) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("error creating history directory: %w", err)
+	}
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding run: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, run.ID+".json"), data, 0644); err != nil {
+		return fmt.Errorf("error writing run: %w", err)
+	}
+	return nil
+}
+
+// List returns all runs, the most recent first. Runs that cannot be read are
+// skipped, so one broken file does not hide the rest of the history.
+func (s *Store) List() ([]Run, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %w", err)
+	}
+
+	var runs []Run
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		run, err := s.read(id)
+		if err != nil {
+			if s.Warn != nil {
+				s.Warn(err)
+			}
+			continue
+		}
+		runs = append(runs, run)
+	}
+	slices.SortFunc(runs, func(a, b Run) int {
+		return b.Started.Compare(a.Started)
+	})
+	return runs, nil
+}
+
+// Load returns the run with the given ID, or with the only ID starting with
+// it.
+func (s *Store) Load(id string) (Run, error) {
+	if id == "" {
+		return Run{}, fmt.Errorf("run ID is empty")
+	}
+	runs, err := s.List()
+	if err != nil {
+		return Run{}, err
+	}
+
+	var matches []Run
+	for _, run := range runs {
+		if run.ID == id {
+			return run, nil
+		}
+		if strings.HasPrefix(run.ID, id) {
+			matches = append(matches, run)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Run{}, fmt.Errorf("run %q not found", id)
+	case 1:
+		return matches[0], nil
+	}
+	return Run{}, fmt.Errorf("run ID %q is ambiguous, it matches %d runs", id, len(matches))
+}
+
+func (s *Store) read(id string) (Run, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, id+".json"))
+	if err != nil {
+		return Run{}, fmt.Errorf("error reading run %s: %w", id, err)
+	}
+	var run Run
+	if err := json.Unmarshal(data, &run); err != nil {
+		return Run{}, fmt.Errorf("error decoding run %s: %w", id, err)
+	}
+	return run, nil
+}
@@ -0,0 +1,102 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	runs, err := store.List()
+	if err != nil || len(runs) != 0 {
+		t.Fatalf("List() on an empty store = %v, %v", runs, err)
+	}
+
+	started := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	for i, id := range []string{"20261018-100000-aaaaaa", "20261018-110000-bbbbbb", "20261018-110000-bbbbcc"} {
+		run := Run{
+			ID:      id,
+			Started: started.Add(time.Duration(i) * time.Hour),
+			Args:    []string{"--branch=deps/bump"},
+			Repos: []Repo{
+				{Path: "repo1", Status: "ok", Commit: "abc123"},
+				{Path: "repo2", Status: "fail", Error: "push failed"},
+			},
+		}
+		if err := store.Save(run); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+	}
+
+	runs, err = store.List()
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	var ids []string
+	for _, run := range runs {
+		ids = append(ids, run.ID)
+	}
+	if want := "20261018-110000-bbbbcc 20261018-110000-bbbbbb 20261018-100000-aaaaaa"; strings.Join(ids, " ") != want {
+		t.Errorf("List() order = %v, want %s", ids, want)
+	}
+
+	writeCorruptRun(t, store, "20261018-120000-broken")
+	var warnings []error
+	store.Warn = func(err error) { warnings = append(warnings, err) }
+	runs, err = store.List()
+	if err != nil || len(runs) != 3 {
+		t.Fatalf("List() with a corrupt run = %d runs, %v, want the 3 readable runs", len(runs), err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Error(), "20261018-120000-broken") {
+		t.Errorf("List() warnings = %v, want one for the corrupt run", warnings)
+	}
+
+	run, err := store.Load("20261018-10")
+	if err != nil {
+		t.Fatalf("Load() by prefix error: %v", err)
+	}
+	if run.ID != "20261018-100000-aaaaaa" || run.Repos[0].Commit != "abc123" || run.Failed() != 1 {
+		t.Errorf("unexpected run %+v", run)
+	}
+	if run, err := store.Load("20261018-110000-bbbbbb"); err != nil || run.ID != "20261018-110000-bbbbbb" {
+		t.Errorf("Load() by exact ID = %v, %v", run.ID, err)
+	}
+
+	tests := []struct {
+		id     string
+		errMsg string
+	}{
+		{"20261018-11", "ambiguous"},
+		{"2025", "not found"},
+		{"", "empty"},
+	}
+	for _, tt := range tests {
+		if _, err := store.Load(tt.id); err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+			t.Errorf("Load(%q) error = %v, want %q", tt.id, err, tt.errMsg)
+		}
+	}
+}
+
+func TestDir(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/state")
+	if dir, err := Dir(); err != nil || dir != filepath.Join("/state", "cascade") {
+		t.Errorf("Dir() = %q, %v", dir, err)
+	}
+
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("HOME", "/home/user")
+	if dir, err := Dir(); err != nil || dir != filepath.Join("/home/user", ".local", "state", "cascade") {
+		t.Errorf("Dir() = %q, %v", dir, err)
+	}
+}
+
+func writeCorruptRun(t *testing.T, store *Store, id string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(store.dir, id+".json"), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,171 @@
+package keyedit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func setJSON(data []byte, path []string, value string) ([]byte, error) {
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("parse JSON: document is not valid JSON")
+	}
+
+	start, end, err := findJSONValue(data, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var replacement string
+	switch {
+	case data[start] == '{' || data[start] == '[':
+		return nil, fmt.Errorf("key %q is not a scalar value", strings.Join(path, "."))
+	case data[start] != '"' && isJSONScalar(value):
+		replacement = strings.TrimSpace(value)
+	default:
+		replacement, err = jsonString(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out := splice(data, start, end, replacement)
+	if !json.Valid(out) {
+		return nil, fmt.Errorf("edited document is not valid JSON")
+	}
+	return out, nil
+}
+
+// isJSONScalar reports whether value is a JSON literal other than an object
+// or array, such as a number or boolean.
+func isJSONScalar(value string) bool {
+	v := strings.TrimSpace(value)
+	return json.Valid([]byte(v)) && v[0] != '{' && v[0] != '['
+}
+
+func jsonString(value string) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// findJSONValue returns the byte span of the value at path in a valid JSON
+// document.
+func findJSONValue(data []byte, path []string) (int, int, error) {
+	s := jsonScanner{data: data}
+	pos := s.skipSpace(0)
+	for i, segment := range path {
+		notFound := notFoundError{key: strings.Join(path[:i+1], ".")}
+		switch data[pos] {
+		case '{':
+			next, ok := s.objectMember(pos, segment)
+			if !ok {
+				return 0, 0, notFound
+			}
+			pos = next
+		case '[':
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 {
+				return 0, 0, notFound
+			}
+			next, ok := s.arrayElement(pos, index)
+			if !ok {
+				return 0, 0, notFound
+			}
+			pos = next
+		default:
+			return 0, 0, notFound
+		}
+	}
+	return pos, s.skipValue(pos), nil
+}
+
+// jsonScanner navigates a document that is already known to be valid JSON.
+type jsonScanner struct {
+	data []byte
+}
+
+func (s jsonScanner) skipSpace(pos int) int {
+	for pos < len(s.data) && strings.IndexByte(" \t\r\n", s.data[pos]) >= 0 {
+		pos++
+	}
+	return pos
+}
+
+// objectMember returns the offset of the value for key in the object at pos.
+func (s jsonScanner) objectMember(pos int, key string) (int, bool) {
+	pos = s.skipSpace(pos + 1)
+	for pos < len(s.data) && s.data[pos] != '}' {
+		keyEnd := s.skipValue(pos)
+		var name string
+		_ = json.Unmarshal(s.data[pos:keyEnd], &name)
+		valueStart := s.skipSpace(s.skipSpace(keyEnd) + 1)
+		if name == key {
+			return valueStart, true
+		}
+		pos = s.skipSpace(s.skipValue(valueStart))
+		if pos < len(s.data) && s.data[pos] == ',' {
+			pos = s.skipSpace(pos + 1)
+		}
+	}
+	return 0, false
+}
+
+// arrayElement returns the offset of the element at index in the array at pos.
+func (s jsonScanner) arrayElement(pos int, index int) (int, bool) {
+	pos = s.skipSpace(pos + 1)
+	for i := 0; pos < len(s.data) && s.data[pos] != ']'; i++ {
+		if i == index {
+			return pos, true
+		}
+		pos = s.skipSpace(s.skipValue(pos))
+		if pos < len(s.data) && s.data[pos] == ',' {
+			pos = s.skipSpace(pos + 1)
+		}
+	}
+	return 0, false
+}
+
+// skipValue returns the offset just past the value that starts at pos.
+func (s jsonScanner) skipValue(pos int) int {
+	switch s.data[pos] {
+	case '"':
+		for i := pos + 1; i < len(s.data); i++ {
+			switch s.data[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1
+			}
+		}
+		return len(s.data)
+	case '{', '[':
+		depth := 0
+		for i := pos; i < len(s.data); i++ {
+			switch s.data[i] {
+			case '"':
+				i = s.skipValue(i) - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		return len(s.data)
+	}
+
+	end := pos
+	for end < len(s.data) && strings.IndexByte(" \t\r\n,]}", s.data[end]) < 0 {
+		end++
+	}
+	return end
+}
@@ -0,0 +1,143 @@
+package keyedit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Assignment describes a single `file:path.to.key=value` edit.
+type Assignment struct {
+	File  string
+	Path  []string
+	Value string
+}
+
+// ParseAssignment parses an assignment in the `file:path.to.key=value` form.
+// Dots inside a key can be escaped as `\.`.
+func ParseAssignment(spec string) (Assignment, error) {
+	file, rest, ok := strings.Cut(spec, ":")
+	if !ok || file == "" {
+		return Assignment{}, fmt.Errorf("assignment must be of the form 'file:path.to.key=value': %q", spec)
+	}
+	key, value, ok := strings.Cut(rest, "=")
+	if !ok || key == "" {
+		return Assignment{}, fmt.Errorf("assignment must be of the form 'file:path.to.key=value': %q", spec)
+	}
+
+	path := splitKey(key)
+	for _, segment := range path {
+		if segment == "" {
+			return Assignment{}, fmt.Errorf("key path contains an empty segment: %q", key)
+		}
+	}
+	if filepath.IsAbs(file) || !filepath.IsLocal(file) {
+		return Assignment{}, fmt.Errorf("file must be a relative path inside the repository: %q", file)
+	}
+	if _, err := formatOf(file); err != nil {
+		return Assignment{}, err
+	}
+
+	return Assignment{File: file, Path: path, Value: value}, nil
+}
+
+// Key returns the dotted key path of the assignment.
+func (a Assignment) Key() string {
+	return strings.Join(a.Path, ".")
+}
+
+func splitKey(key string) []string {
+	var segments []string
+	var current strings.Builder
+	for i := 0; i < len(key); i++ {
+		switch {
+		case key[i] == '\\' && i+1 < len(key) && key[i+1] == '.':
+			current.WriteByte('.')
+			i++
+		case key[i] == '.':
+			segments = append(segments, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(key[i])
+		}
+	}
+	return append(segments, current.String())
+}
+
+type format int
+
+const (
+	formatYAML format = iota
+	formatJSON
+	formatTOML
+)
+
+func formatOf(file string) (format, error) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return formatYAML, nil
+	case ".json":
+		return formatJSON, nil
+	case ".toml":
+		return formatTOML, nil
+	}
+	return 0, fmt.Errorf("unsupported file format for %s, expected .yaml, .yml, .json or .toml", file)
+}
+
+// Apply edits the key in the repository file and writes it back in place.
+// Only the edited value is rewritten, so comments and formatting elsewhere in
+// the document are preserved.
+func Apply(repoPath string, a Assignment) error {
+	f, err := formatOf(a.File)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(repoPath, a.File)
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("file does not exist: %s", a.File)
+		}
+		return fmt.Errorf("error accessing %s: %w", a.File, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", a.File, err)
+	}
+
+	var out []byte
+	switch f {
+	case formatYAML:
+		out, err = setYAML(data, a.Path, a.Value)
+	case formatJSON:
+		out, err = setJSON(data, a.Path, a.Value)
+	case formatTOML:
+		out, err = setTOML(data, a.Path, a.Value)
+	}
+	if err != nil {
+		return fmt.Errorf("error setting %s in %s: %w", a.Key(), a.File, err)
+	}
+
+	if err := os.WriteFile(path, out, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("error writing %s: %w", a.File, err)
+	}
+	return nil
+}
+
+// splice replaces data[start:end] with replacement.
+func splice(data []byte, start, end int, replacement string) []byte {
+	out := make([]byte, 0, len(data)-(end-start)+len(replacement))
+	out = append(out, data[:start]...)
+	out = append(out, replacement...)
+	return append(out, data[end:]...)
+}
+
+type notFoundError struct {
+	key string
+}
+
+func (e notFoundError) Error() string {
+	return fmt.Sprintf("key %q not found", e.key)
+}
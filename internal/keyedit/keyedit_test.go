@@ -0,0 +1,309 @@
+package keyedit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseAssignment(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    Assignment
+		wantErr bool
+	}{
+		{
+			name: "nested_key",
+			spec: "values.yaml:image.tag=1.2.3",
+			want: Assignment{File: "values.yaml", Path: []string{"image", "tag"}, Value: "1.2.3"},
+		},
+		{
+			name: "value_with_separators",
+			spec: "config/app.toml:server.url=http://host:8080/?a=b",
+			want: Assignment{File: "config/app.toml", Path: []string{"server", "url"}, Value: "http://host:8080/?a=b"},
+		},
+		{
+			name: "escaped_dot",
+			spec: `package.json:dependencies.lodash\.merge=4.6.2`,
+			want: Assignment{File: "package.json", Path: []string{"dependencies", "lodash.merge"}, Value: "4.6.2"},
+		},
+		{name: "missing_file", spec: ":a=b", wantErr: true},
+		{name: "missing_value", spec: "a.yaml:key", wantErr: true},
+		{name: "empty_segment", spec: "a.yaml:a..b=c", wantErr: true},
+		{name: "outside_repo", spec: "../a.yaml:a=b", wantErr: true},
+		{name: "unsupported_format", spec: "a.ini:a=b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAssignment(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseAssignment(%q) expected error", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAssignment(%q) error: %v", tt.spec, err)
+			}
+			if got.File != tt.want.File || got.Value != tt.want.Value || !slices.Equal(got.Path, tt.want.Path) {
+				t.Errorf("ParseAssignment(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "yaml_keeps_comments",
+			file: "values.yaml",
+			content: "# Chart values\n" +
+				"image:\n" +
+				"  repository: nginx # upstream image\n" +
+				"  tag: \"1.0.0\"\n" +
+				"replicas: 2\n",
+			spec: "values.yaml:image.tag=1.2.3",
+			want: "# Chart values\n" +
+				"image:\n" +
+				"  repository: nginx # upstream image\n" +
+				"  tag: \"1.2.3\"\n" +
+				"replicas: 2\n",
+		},
+		{
+			name:    "yaml_number",
+			file:    "values.yaml",
+			content: "replicas: 2 # default\nname: app\n",
+			spec:    "values.yaml:replicas=5",
+			want:    "replicas: 5 # default\nname: app\n",
+		},
+		{
+			name:    "yaml_string_stays_string",
+			file:    "values.yaml",
+			content: "name: app\n",
+			spec:    "values.yaml:name=42",
+			want:    "name: \"42\"\n",
+		},
+		{
+			name:    "yaml_sequence_index",
+			file:    "values.yaml",
+			content: "hosts:\n  - a.example.com\n  - b.example.com\n",
+			spec:    "values.yaml:hosts.1=c.example.com",
+			want:    "hosts:\n  - a.example.com\n  - c.example.com\n",
+		},
+		{
+			name:    "yaml_empty_value",
+			file:    "values.yaml",
+			content: "version:\nname: app\n",
+			spec:    "values.yaml:version=2",
+			want:    "version: 2\nname: app\n",
+		},
+		{
+			name:    "yaml_missing_key",
+			file:    "values.yaml",
+			content: "image:\n  tag: 1\n",
+			spec:    "values.yaml:image.digest=sha",
+			wantErr: true,
+		},
+		{
+			name:    "yaml_block_scalar",
+			file:    "values.yaml",
+			content: "script: |\n  echo hi\n",
+			spec:    "values.yaml:script=echo bye",
+			wantErr: true,
+		},
+		{
+			name: "json_keeps_formatting",
+			file: "package.json",
+			content: "{\n" +
+				"    \"name\": \"app\",\n" +
+				"    \"dependencies\": {\"react\": \"^18.0.0\", \"lodash\": \"^4.17.0\"},\n" +
+				"    \"private\": true\n" +
+				"}\n",
+			spec: "package.json:dependencies.lodash=^4.17.21",
+			want: "{\n" +
+				"    \"name\": \"app\",\n" +
+				"    \"dependencies\": {\"react\": \"^18.0.0\", \"lodash\": \"^4.17.21\"},\n" +
+				"    \"private\": true\n" +
+				"}\n",
+		},
+		{
+			name:    "json_literal",
+			file:    "package.json",
+			content: "{\"private\": true, \"workers\": [1, 2]}",
+			spec:    "package.json:workers.1=8",
+			want:    "{\"private\": true, \"workers\": [1, 8]}",
+		},
+		{
+			name:    "json_not_scalar",
+			file:    "package.json",
+			content: "{\"scripts\": {\"test\": \"jest\"}}",
+			spec:    "package.json:scripts=none",
+			wantErr: true,
+		},
+		{
+			name:    "json_missing_key",
+			file:    "package.json",
+			content: "{\"name\": \"app\"}",
+			spec:    "package.json:version=1.0.0",
+			wantErr: true,
+		},
+		{
+			name: "toml_table_key",
+			file: "config.toml",
+			content: "# App config\n" +
+				"title = \"app\"\n\n" +
+				"[server]\n" +
+				"host = 'localhost' # bind address\n" +
+				"port = 8080\n",
+			spec: "config.toml:server.port=9090",
+			want: "# App config\n" +
+				"title = \"app\"\n\n" +
+				"[server]\n" +
+				"host = 'localhost' # bind address\n" +
+				"port = 9090\n",
+		},
+		{
+			name:    "toml_literal_string",
+			file:    "config.toml",
+			content: "[server]\nhost = 'localhost' # bind address\n",
+			spec:    "config.toml:server.host=0.0.0.0",
+			want:    "[server]\nhost = '0.0.0.0' # bind address\n",
+		},
+		{
+			name:    "toml_dotted_key",
+			file:    "config.toml",
+			content: "tool.version = \"1.0\"\n",
+			spec:    "config.toml:tool.version=2.0",
+			want:    "tool.version = \"2.0\"\n",
+		},
+		{
+			name: "toml_inline_table",
+			file: "Cargo.toml",
+			content: "[dependencies]\n" +
+				"serde = { version = \"1.0\", features = [\"derive\"] }\n" +
+				"tokio = \"1\"\n",
+			spec: "Cargo.toml:dependencies.serde.version=1.0.200",
+			want: "[dependencies]\n" +
+				"serde = { version = \"1.0.200\", features = [\"derive\"] }\n" +
+				"tokio = \"1\"\n",
+		},
+		{
+			name:    "toml_array_element",
+			file:    "Cargo.toml",
+			content: "[dependencies]\nserde = { version = \"1.0\", features = [\"derive\", \"rc\"] }\n",
+			spec:    "Cargo.toml:dependencies.serde.features.1=std",
+			want:    "[dependencies]\nserde = { version = \"1.0\", features = [\"derive\", \"std\"] }\n",
+		},
+		{
+			name: "toml_array_of_tables",
+			file: "Cargo.toml",
+			content: "[[bin]]\n" +
+				"name = \"first\"\n\n" +
+				"[[bin]]\n" +
+				"name = \"second\"\n" +
+				"path = \"src/second.rs\"\n\n" +
+				"[bin.metadata]\n" +
+				"level = 1\n",
+			spec: "Cargo.toml:bin.1.metadata.level=2",
+			want: "[[bin]]\n" +
+				"name = \"first\"\n\n" +
+				"[[bin]]\n" +
+				"name = \"second\"\n" +
+				"path = \"src/second.rs\"\n\n" +
+				"[bin.metadata]\n" +
+				"level = 2\n",
+		},
+		{
+			name:    "toml_array_of_tables_first",
+			file:    "Cargo.toml",
+			content: "[[bin]]\nname = \"first\"\n\n[[bin]]\nname = \"second\"\n",
+			spec:    "Cargo.toml:bin.0.name=main",
+			want:    "[[bin]]\nname = \"main\"\n\n[[bin]]\nname = \"second\"\n",
+		},
+		{
+			name:    "toml_multiline_string",
+			file:    "config.toml",
+			content: "description = \"\"\"\nold\ntext\"\"\"\nname = \"app\"\n",
+			spec:    "config.toml:description=new text",
+			want:    "description = \"new text\"\nname = \"app\"\n",
+		},
+		{
+			name:    "toml_inline_table_not_scalar",
+			file:    "Cargo.toml",
+			content: "[dependencies]\nserde = { version = \"1.0\" }\n",
+			spec:    "Cargo.toml:dependencies.serde=1.0",
+			wantErr: true,
+		},
+		{
+			name:    "toml_missing_key",
+			file:    "config.toml",
+			content: "[server]\nport = 8080\n",
+			spec:    "config.toml:server.host=x",
+			wantErr: true,
+		},
+		{
+			name:    "missing_file",
+			file:    "other.yaml",
+			content: "a: 1\n",
+			spec:    "values.yaml:a=2",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoPath := t.TempDir()
+			path := filepath.Join(repoPath, tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			a, err := ParseAssignment(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseAssignment(%q) error: %v", tt.spec, err)
+			}
+			err = Apply(repoPath, a)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Apply(%q) expected error", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply(%q) error: %v", tt.spec, err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("Apply(%q) produced:\n%s\nwant:\n%s", tt.spec, data, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyMissingKeyError(t *testing.T) {
+	repoPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoPath, "values.yaml"), []byte("image:\n  tag: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Apply(repoPath, Assignment{File: "values.yaml", Path: []string{"image", "digest"}, Value: "x"})
+	var notFound notFoundError
+	if !errors.As(err, &notFound) || notFound.key != "image.digest" {
+		t.Fatalf("expected key not found error for image.digest, got %v", err)
+	}
+}
@@ -0,0 +1,388 @@
+package keyedit
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+func setTOML(data []byte, path []string, value string) ([]byte, error) {
+	var doc map[string]any
+	if _, err := toml.Decode(string(data), &doc); err != nil {
+		return nil, fmt.Errorf("parse TOML: %w", err)
+	}
+	if err := tomlLookup(doc, path); err != nil {
+		return nil, err
+	}
+
+	start, end, ok := findTOMLValue(data, path)
+	if !ok {
+		return nil, fmt.Errorf("key %q cannot be edited in place", strings.Join(path, "."))
+	}
+
+	var replacement string
+	existing := data[start:end]
+	switch {
+	case existing[0] == '[' || existing[0] == '{':
+		return nil, fmt.Errorf("key %q is not a scalar value", strings.Join(path, "."))
+	case strings.ContainsAny(value, "\r\n"):
+		return nil, fmt.Errorf("multi-line values are not supported")
+	case existing[0] == '\'' && !strings.Contains(value, "'"):
+		replacement = "'" + value + "'"
+	case existing[0] != '"' && existing[0] != '\'' && isTOMLScalar(value):
+		replacement = strings.TrimSpace(value)
+	default:
+		// JSON string escapes are a subset of TOML basic string escapes.
+		var err error
+		if replacement, err = jsonString(value); err != nil {
+			return nil, err
+		}
+	}
+
+	out := splice(data, start, end, replacement)
+	var check map[string]any
+	if _, err := toml.Decode(string(out), &check); err != nil {
+		return nil, fmt.Errorf("edited document is not valid TOML: %w", err)
+	}
+	return out, nil
+}
+
+// isTOMLScalar reports whether value is a TOML literal other than a string,
+// array or inline table, such as a number, boolean or date.
+func isTOMLScalar(value string) bool {
+	var doc map[string]any
+	if _, err := toml.Decode("v = "+value, &doc); err != nil {
+		return false
+	}
+	switch doc["v"].(type) {
+	case string, []any, map[string]any:
+		return false
+	}
+	return true
+}
+
+// tomlLookup checks that path exists in the decoded document.
+func tomlLookup(doc map[string]any, path []string) error {
+	var node any = doc
+	for i, segment := range path {
+		notFound := notFoundError{key: strings.Join(path[:i+1], ".")}
+		switch v := node.(type) {
+		case map[string]any:
+			next, ok := v[segment]
+			if !ok {
+				return notFound
+			}
+			node = next
+		case []map[string]any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return notFound
+			}
+			node = v[index]
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return notFound
+			}
+			node = v[index]
+		default:
+			return notFound
+		}
+	}
+	return nil
+}
+
+// findTOMLValue returns the byte span of the value at path. Values are found
+// through table and array of tables headers, dotted keys, inline tables and
+// arrays.
+func findTOMLValue(data []byte, path []string) (int, int, bool) {
+	var table []string
+	arrays := make(map[string]int)
+
+	pos := 0
+	for pos < len(data) {
+		pos = skipTOMLSpace(data, pos, true)
+		if pos >= len(data) {
+			break
+		}
+
+		switch data[pos] {
+		case '#':
+			pos = endOfLine(data, pos)
+			continue
+		case '[':
+			arrayTable := pos+1 < len(data) && data[pos+1] == '['
+			keyStart := pos + 1
+			if arrayTable {
+				keyStart++
+			}
+			keys, after, ok := parseTOMLKey(data, keyStart)
+			if !ok {
+				return 0, 0, false
+			}
+			table = tomlTablePath(arrays, keys, arrayTable)
+			pos = endOfLine(data, after)
+			continue
+		}
+
+		keys, after, ok := parseTOMLKey(data, pos)
+		if !ok {
+			return 0, 0, false
+		}
+		eq := skipTOMLSpace(data, after, false)
+		if eq >= len(data) || data[eq] != '=' {
+			return 0, 0, false
+		}
+		start := skipTOMLSpace(data, eq+1, false)
+		end := skipTOMLValue(data, start)
+
+		full := append(slices.Clone(table), keys...)
+		if start, end, ok := findInTOMLValue(data, start, end, full, path); ok {
+			return start, end, true
+		}
+		pos = end
+	}
+	return 0, 0, false
+}
+
+// tomlTablePath returns the path of the table opened by a header, with the
+// index of the current element after every array of tables on the way. An
+// array of tables header adds a new element to its array, which arrays
+// counts by path.
+func tomlTablePath(arrays map[string]int, keys []string, arrayTable bool) []string {
+	var path []string
+	for i, key := range keys {
+		path = append(path, key)
+		id := strings.Join(path, "\x00")
+		if arrayTable && i == len(keys)-1 {
+			arrays[id]++
+		}
+		if n, ok := arrays[id]; ok {
+			path = append(path, strconv.Itoa(n-1))
+		}
+	}
+	return path
+}
+
+// findInTOMLValue returns the byte span of the value at path within the value
+// between start and end, which is found at the path at. Inline tables and
+// arrays are searched for keys and indexes below at.
+func findInTOMLValue(data []byte, start, end int, at, path []string) (int, int, bool) {
+	if start >= end || len(at) > len(path) || !slices.Equal(at, path[:len(at)]) {
+		return 0, 0, false
+	}
+	if len(at) == len(path) {
+		return start, end, true
+	}
+
+	switch data[start] {
+	case '{':
+		pos := start + 1
+		for {
+			pos = skipTOMLSpace(data, pos, false)
+			if pos >= end || data[pos] == '}' {
+				return 0, 0, false
+			}
+			keys, after, ok := parseTOMLKey(data, pos)
+			if !ok {
+				return 0, 0, false
+			}
+			eq := skipTOMLSpace(data, after, false)
+			if eq >= end || data[eq] != '=' {
+				return 0, 0, false
+			}
+			valueStart := skipTOMLSpace(data, eq+1, false)
+			valueEnd := skipTOMLValue(data, valueStart)
+			full := append(slices.Clone(at), keys...)
+			if s, e, ok := findInTOMLValue(data, valueStart, valueEnd, full, path); ok {
+				return s, e, true
+			}
+			pos = skipTOMLSpace(data, valueEnd, false)
+			if pos < end && data[pos] == ',' {
+				pos++
+			}
+		}
+	case '[':
+		pos := start + 1
+		for index := 0; ; index++ {
+			pos = skipTOMLBlank(data, pos)
+			if pos >= end || data[pos] == ']' {
+				return 0, 0, false
+			}
+			valueEnd := skipTOMLValue(data, pos)
+			if valueEnd == pos {
+				return 0, 0, false
+			}
+			full := append(slices.Clone(at), strconv.Itoa(index))
+			if s, e, ok := findInTOMLValue(data, pos, valueEnd, full, path); ok {
+				return s, e, true
+			}
+			pos = skipTOMLBlank(data, valueEnd)
+			if pos < end && data[pos] == ',' {
+				pos++
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+func skipTOMLSpace(data []byte, pos int, newlines bool) int {
+	for pos < len(data) {
+		switch data[pos] {
+		case ' ', '\t':
+		case '\r', '\n':
+			if !newlines {
+				return pos
+			}
+		default:
+			return pos
+		}
+		pos++
+	}
+	return pos
+}
+
+// skipTOMLBlank skips whitespace, newlines and comments.
+func skipTOMLBlank(data []byte, pos int) int {
+	for {
+		pos = skipTOMLSpace(data, pos, true)
+		if pos >= len(data) || data[pos] != '#' {
+			return pos
+		}
+		pos = endOfLine(data, pos)
+	}
+}
+
+func endOfLine(data []byte, pos int) int {
+	for pos < len(data) && data[pos] != '\n' {
+		pos++
+	}
+	return pos
+}
+
+// parseTOMLKey parses a possibly dotted and quoted key starting at pos.
+func parseTOMLKey(data []byte, pos int) ([]string, int, bool) {
+	var keys []string
+	for {
+		pos = skipTOMLSpace(data, pos, false)
+		if pos >= len(data) {
+			return nil, pos, false
+		}
+
+		switch data[pos] {
+		case '"':
+			end := skipTOMLValue(data, pos)
+			var key string
+			if err := json.Unmarshal(data[pos:end], &key); err != nil {
+				return nil, pos, false
+			}
+			keys = append(keys, key)
+			pos = end
+		case '\'':
+			end := skipTOMLValue(data, pos)
+			if end-pos < 2 {
+				return nil, pos, false
+			}
+			keys = append(keys, string(data[pos+1:end-1]))
+			pos = end
+		default:
+			start := pos
+			for pos < len(data) && isBareKeyChar(data[pos]) {
+				pos++
+			}
+			if pos == start {
+				return nil, pos, false
+			}
+			keys = append(keys, string(data[start:pos]))
+		}
+
+		pos = skipTOMLSpace(data, pos, false)
+		if pos < len(data) && data[pos] == '.' {
+			pos++
+			continue
+		}
+		if pos < len(data) && data[pos] == ']' {
+			pos++
+		}
+		return keys, pos, true
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// skipTOMLValue returns the offset just past the value that starts at pos.
+func skipTOMLValue(data []byte, pos int) int {
+	if pos >= len(data) {
+		return pos
+	}
+	rest := string(data[pos:])
+
+	switch {
+	case strings.HasPrefix(rest, `"""`):
+		for i := pos + 3; i < len(data); i++ {
+			if data[i] == '\\' {
+				i++
+				continue
+			}
+			if strings.HasPrefix(string(data[i:]), `"""`) {
+				return i + 3
+			}
+		}
+		return len(data)
+	case strings.HasPrefix(rest, `'''`):
+		if i := strings.Index(rest[3:], `'''`); i >= 0 {
+			return pos + 3 + i + 3
+		}
+		return len(data)
+	case data[pos] == '"':
+		for i := pos + 1; i < len(data); i++ {
+			switch data[i] {
+			case '\\':
+				i++
+			case '"', '\n':
+				return i + 1
+			}
+		}
+		return len(data)
+	case data[pos] == '\'':
+		for i := pos + 1; i < len(data); i++ {
+			if data[i] == '\'' || data[i] == '\n' {
+				return i + 1
+			}
+		}
+		return len(data)
+	case data[pos] == '[' || data[pos] == '{':
+		depth := 0
+		for i := pos; i < len(data); i++ {
+			switch data[i] {
+			case '"', '\'':
+				i = skipTOMLValue(data, i) - 1
+			case '#':
+				i = endOfLine(data, i) - 1
+			case '[', '{':
+				depth++
+			case ']', '}':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		return len(data)
+	}
+
+	end := pos
+	for end < len(data) && strings.IndexByte("#,]}\r\n", data[end]) < 0 {
+		end++
+	}
+	for end > pos && (data[end-1] == ' ' || data[end-1] == '\t') {
+		end--
+	}
+	return end
+}
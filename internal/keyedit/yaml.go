@@ -0,0 +1,228 @@
+package keyedit
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+func setYAML(data []byte, path []string, value string) ([]byte, error) {
+	root, err := parseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	key, node, parent, err := findYAMLNode(root, path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+	case yaml.AliasNode:
+		return nil, fmt.Errorf("key %q is an alias and cannot be edited in place", strings.Join(path, "."))
+	default:
+		return nil, fmt.Errorf("key %q is not a scalar value", strings.Join(path, "."))
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return nil, fmt.Errorf("multi-line values are not supported")
+	}
+
+	flow := parent.Style&yaml.FlowStyle != 0
+	replacement, err := yamlScalar(node, value, flow)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []byte
+	if node.Value == "" && node.Tag == "!!null" && key != nil {
+		// An empty value has no text of its own, so insert after the key.
+		offset, err := yamlColonOffset(data, key)
+		if err != nil {
+			return nil, err
+		}
+		out = splice(data, offset, offset, " "+replacement)
+	} else {
+		start := lineColumnOffset(data, node.Line, node.Column)
+		end, err := yamlScalarEnd(data, start, node, flow)
+		if err != nil {
+			return nil, err
+		}
+		out = splice(data, start, end, replacement)
+	}
+
+	// Make sure the splice produced the intended document.
+	checkRoot, err := parseYAML(out)
+	if err != nil {
+		return nil, fmt.Errorf("edited document is not valid YAML: %w", err)
+	}
+	if _, check, _, err := findYAMLNode(checkRoot, path); err != nil || check.Value != value {
+		return nil, fmt.Errorf("key %q cannot be edited in place", strings.Join(path, "."))
+	}
+	return out, nil
+}
+
+func parseYAML(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse YAML: %w", err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, fmt.Errorf("document is empty")
+	}
+	return doc.Content[0], nil
+}
+
+// findYAMLNode walks the key path and returns the mapping key (if any), the
+// value node and its parent collection.
+func findYAMLNode(root *yaml.Node, path []string) (key, node, parent *yaml.Node, err error) {
+	node = root
+	for i, segment := range path {
+		parent = node
+		switch node.Kind {
+		case yaml.MappingNode:
+			var next *yaml.Node
+			for j := 0; j+1 < len(node.Content); j += 2 {
+				if node.Content[j].Value == segment {
+					key, next = node.Content[j], node.Content[j+1]
+					break
+				}
+			}
+			if next == nil {
+				return nil, nil, nil, notFoundError{key: strings.Join(path[:i+1], ".")}
+			}
+			node = next
+		case yaml.SequenceNode:
+			index, convErr := strconv.Atoi(segment)
+			if convErr != nil || index < 0 || index >= len(node.Content) {
+				return nil, nil, nil, notFoundError{key: strings.Join(path[:i+1], ".")}
+			}
+			key, node = nil, node.Content[index]
+		default:
+			return nil, nil, nil, notFoundError{key: strings.Join(path[:i+1], ".")}
+		}
+	}
+	return key, node, parent, nil
+}
+
+// yamlScalar renders value as a scalar, keeping the quoting style of the node
+// it replaces and keeping string values strings.
+func yamlScalar(node *yaml.Node, value string, flow bool) (string, error) {
+	if node.Tag != "!!str" && !yamlResolvesToString(value) {
+		return value, nil
+	}
+
+	switch node.Style {
+	case yaml.DoubleQuotedStyle:
+		return strconv.Quote(value), nil
+	case yaml.SingleQuotedStyle:
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'", nil
+	}
+
+	encoded, err := yaml.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	scalar := strings.TrimSuffix(string(encoded), "\n")
+	if strings.Contains(scalar, "\n") || (flow && strings.ContainsAny(scalar, ",[]{}")) {
+		return strconv.Quote(value), nil
+	}
+	return scalar, nil
+}
+
+func yamlResolvesToString(value string) bool {
+	var v any
+	if err := yaml.Unmarshal([]byte(value), &v); err != nil {
+		return true
+	}
+	_, ok := v.(string)
+	return ok
+}
+
+// yamlScalarEnd returns the offset just past the scalar that starts at start.
+func yamlScalarEnd(data []byte, start int, node *yaml.Node, flow bool) (int, error) {
+	unsupported := fmt.Errorf("only single-line scalar values can be edited in place")
+	if start >= len(data) {
+		return 0, unsupported
+	}
+
+	switch node.Style {
+	case yaml.DoubleQuotedStyle:
+		for i := start + 1; i < len(data); i++ {
+			switch data[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1, nil
+			case '\n':
+				return 0, unsupported
+			}
+		}
+		return 0, unsupported
+	case yaml.SingleQuotedStyle:
+		for i := start + 1; i < len(data); i++ {
+			switch data[i] {
+			case '\'':
+				if i+1 < len(data) && data[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1, nil
+			case '\n':
+				return 0, unsupported
+			}
+		}
+		return 0, unsupported
+	case yaml.LiteralStyle, yaml.FoldedStyle:
+		return 0, unsupported
+	}
+
+	end := start
+	for end < len(data) && data[end] != '\n' && data[end] != '\r' {
+		if data[end] == '#' && end > start && (data[end-1] == ' ' || data[end-1] == '\t') {
+			break
+		}
+		if flow && bytes.IndexByte([]byte(",]}"), data[end]) >= 0 {
+			break
+		}
+		end++
+	}
+	for end > start && (data[end-1] == ' ' || data[end-1] == '\t') {
+		end--
+	}
+	if string(data[start:end]) != node.Value {
+		return 0, unsupported
+	}
+	return end, nil
+}
+
+// yamlColonOffset returns the offset just past the colon following key.
+func yamlColonOffset(data []byte, key *yaml.Node) (int, error) {
+	offset := lineColumnOffset(data, key.Line, key.Column)
+	for i := offset; i < len(data) && data[i] != '\n'; i++ {
+		if data[i] == ':' {
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("cannot locate value for key %q", key.Value)
+}
+
+// lineColumnOffset converts a 1-based line and rune column to a byte offset.
+func lineColumnOffset(data []byte, line, column int) int {
+	offset := 0
+	for l := 1; l < line && offset < len(data); l++ {
+		next := bytes.IndexByte(data[offset:], '\n')
+		if next < 0 {
+			return len(data)
+		}
+		offset += next + 1
+	}
+	for c := 1; c < column && offset < len(data); c++ {
+		_, size := utf8.DecodeRune(data[offset:])
+		offset += size
+	}
+	return offset
+}
@@ -1,24 +1,84 @@
 package log
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"os"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/vpukhanov/cascade/internal/git"
 )
 
-// ApplyLogger writes detailed apply errors to a file for later inspection.
+// Format selects how the apply log is written.
+type Format string
+
+const (
+	// FormatText writes human-readable entries with an indented error chain.
+	FormatText Format = "text"
+	// FormatJSON writes one JSON event per line for log pipelines.
+	FormatJSON Format = "json"
+)
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(name string) (Format, error) {
+	switch Format(name) {
+	case FormatText, FormatJSON:
+		return Format(name), nil
+	}
+	return "", fmt.Errorf("unknown log format %q, must be text or json", name)
+}
+
+// ApplyLogger writes detailed apply errors, and optionally the output of
+// successful repositories, to a file for later inspection. In the JSON
+// format it also records every command and the result of every repository.
 type ApplyLogger struct {
 	mu     sync.Mutex
 	file   *os.File
 	logger *log.Logger
+	format Format
+	runID  string
+	enc    *json.Encoder
+}
+
+// Event is a single line of the JSON log.
+type Event struct {
+	Time    time.Time     `json:"time"`
+	RunID   string        `json:"run_id"`
+	Repo    string        `json:"repo"`
+	Event   string        `json:"event"`
+	Step    string        `json:"step,omitempty"`
+	Status  string        `json:"status,omitempty"`
+	Detail  string        `json:"detail,omitempty"`
+	Errors  []string      `json:"errors,omitempty"`
+	Output  string        `json:"output,omitempty"`
+	Command *CommandEvent `json:"command,omitempty"`
+}
+
+// CommandEvent describes a command in the JSON log.
+type CommandEvent struct {
+	Args       []string  `json:"args"`
+	Dir        string    `json:"dir,omitempty"`
+	Started    time.Time `json:"started"`
+	DurationMS int64     `json:"duration_ms"`
+	ExitCode   int       `json:"exit_code"`
+	Output     string    `json:"output,omitempty"`
+	// Attempt and RetryDelayMS are set for fetches, pulls and pushes,
+	// which are retried after transient errors.
+	Attempt      int   `json:"attempt,omitempty"`
+	RetryDelayMS int64 `json:"retry_delay_ms,omitempty"`
 }
 
 // NewApplyLogger creates a log file in the OS temp directory.
-func NewApplyLogger() (*ApplyLogger, error) {
-	file, err := os.CreateTemp("", "cascade-apply-*.log")
+func NewApplyLogger(format Format, runID string) (*ApplyLogger, error) {
+	pattern := "cascade-apply-*.log"
+	if format == FormatJSON {
+		pattern = "cascade-apply-*.jsonl"
+	}
+	file, err := os.CreateTemp("", pattern)
 	if err != nil {
 		return nil, fmt.Errorf("create apply log file: %w", err)
 	}
@@ -26,6 +86,9 @@ func NewApplyLogger() (*ApplyLogger, error) {
 	return &ApplyLogger{
 		file:   file,
 		logger: log.New(file, "", log.LstdFlags),
+		format: format,
+		runID:  runID,
+		enc:    json.NewEncoder(file),
 	}, nil
 }
 
@@ -39,8 +102,9 @@ func (l *ApplyLogger) Close() error {
 	return l.file.Close()
 }
 
-// LogRepoError records the full error chain for a repository.
-func (l *ApplyLogger) LogRepoError(repo string, err error) {
+// LogRepoError records the full error chain for a repository, along with
+// the step that failed.
+func (l *ApplyLogger) LogRepoError(repo string, step string, err error) {
 	if err == nil {
 		return
 	}
@@ -48,6 +112,11 @@ func (l *ApplyLogger) LogRepoError(repo string, err error) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	if l.format == FormatJSON {
+		l.write(Event{Repo: repo, Event: "error", Step: step, Errors: errorChain(err)})
+		return
+	}
+
 	l.logger.Printf("repo: %s", repo)
 	for _, line := range formatErrorChain(err) {
 		l.logger.Print(line)
@@ -55,30 +124,152 @@ func (l *ApplyLogger) LogRepoError(repo string, err error) {
 	l.logger.Print("")
 }
 
+// LogRepoOutput records the output of scripts and commands that ran in a
+// repository.
+func (l *ApplyLogger) LogRepoOutput(repo string, output string) {
+	if output == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.format == FormatJSON {
+		l.write(Event{Repo: repo, Event: "output", Output: output})
+		return
+	}
+
+	l.logger.Printf("repo: %s", repo)
+	l.logger.Print("output:")
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		l.logger.Printf("  %s", line)
+	}
+	l.logger.Print("")
+}
+
+// LogRepoResult records the status of a repository. Only the JSON format
+// records results, the text log only holds details.
+func (l *ApplyLogger) LogRepoResult(repo string, detail string, err error) {
+	if l.format != FormatJSON {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	status := "ok"
+	if err != nil {
+		status = "fail"
+	}
+	l.write(Event{Repo: repo, Event: "result", Status: status, Detail: detail})
+}
+
+// LogCommand records a command that ran in a repository. The JSON format
+// records every command, the text format only the attempts of retried
+// fetches, pulls and pushes, use a RunDir for text transcripts.
+func (l *ApplyLogger) LogCommand(rec git.CommandRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.format != FormatJSON {
+		l.logAttempt(rec)
+		return
+	}
+
+	l.write(Event{
+		Repo:  rec.Dir,
+		Event: "command",
+		Command: &CommandEvent{
+			Args:         rec.Args,
+			Dir:          rec.Dir,
+			Started:      rec.Start,
+			DurationMS:   rec.Duration.Milliseconds(),
+			ExitCode:     rec.ExitCode,
+			Output:       rec.Output,
+			Attempt:      rec.Attempt,
+			RetryDelayMS: rec.RetryDelay.Milliseconds(),
+		},
+	})
+}
+
+// logAttempt records an attempt of a retried command in the text log, so
+// that commands which only succeeded after retries leave a trace. Attempts
+// that are neither retried nor retries are left out. The caller holds l.mu.
+func (l *ApplyLogger) logAttempt(rec git.CommandRecord) {
+	if !rec.InRetry() {
+		return
+	}
+
+	l.logger.Printf("repo: %s", rec.Dir)
+	l.logger.Printf("command: %s", strings.Join(rec.Args, " "))
+	attempt := fmt.Sprintf("attempt: %d, exit code: %d", rec.Attempt, rec.ExitCode)
+	if rec.Retried {
+		attempt += fmt.Sprintf(", retrying in %s", rec.RetryDelay.Round(time.Millisecond))
+	}
+	l.logger.Print(attempt)
+	if rec.Output != "" {
+		l.logger.Print("output:")
+		for _, line := range strings.Split(strings.TrimSuffix(rec.Output, "\n"), "\n") {
+			l.logger.Printf("  %s", line)
+		}
+	}
+	l.logger.Print("")
+}
+
+// write encodes the event as a line of the JSON log. The caller holds l.mu.
+func (l *ApplyLogger) write(event Event) {
+	event.Time = time.Now()
+	event.RunID = l.runID
+	_ = l.enc.Encode(event)
+}
+
+// errorChain returns the messages of the error and the errors it wraps, in
+// the order formatErrorChain lists them.
+func errorChain(err error) []string {
+	var messages []string
+	walkErrorChain(err, func(message string, _ int) {
+		messages = append(messages, message)
+	})
+	return messages
+}
+
 func formatErrorChain(err error) []string {
 	var lines []string
-	var visit func(err error, depth int)
+	walkErrorChain(err, func(message string, depth int) {
+		lines = append(lines, fmt.Sprintf("%s- %s", strings.Repeat("  ", depth), message))
+	})
+	return lines
+}
+
+// walkErrorChain calls fn for the error and every error it wraps. Wrappers
+// with the same message as the error they wrap add no context and are
+// skipped.
+func walkErrorChain(err error, fn func(message string, depth int)) {
+	var visit func(err error, parent string, depth int)
 
-	visit = func(err error, depth int) {
+	visit = func(err error, parent string, depth int) {
 		if err == nil {
 			return
 		}
 
-		indent := strings.Repeat("  ", depth)
-		lines = append(lines, fmt.Sprintf("%s- %s", indent, err.Error()))
+		message := err.Error()
+		if message == parent {
+			depth--
+		} else {
+			fn(message, depth)
+		}
 
 		if unwrapped, ok := err.(interface{ Unwrap() []error }); ok {
 			for _, nested := range unwrapped.Unwrap() {
-				visit(nested, depth+1)
+				visit(nested, message, depth+1)
 			}
 			return
 		}
 
 		if next := errors.Unwrap(err); next != nil {
-			visit(next, depth+1)
+			visit(next, message, depth+1)
 		}
 	}
 
-	visit(err, 0)
-	return lines
+	visit(err, "", 0)
 }
@@ -1,15 +1,19 @@
 package log
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/vpukhanov/cascade/internal/git"
 )
 
 func TestNewApplyLogger(t *testing.T) {
-	logger, err := NewApplyLogger()
+	logger, err := NewApplyLogger(FormatText, "run-1")
 	if err != nil {
 		t.Fatalf("NewApplyLogger() error: %v", err)
 	}
@@ -34,14 +38,14 @@ func TestNewApplyLogger(t *testing.T) {
 }
 
 func TestApplyLoggerLogRepoError(t *testing.T) {
-	logger, err := NewApplyLogger()
+	logger, err := NewApplyLogger(FormatText, "run-1")
 	if err != nil {
 		t.Fatalf("NewApplyLogger() error: %v", err)
 	}
 
 	path := logger.Path()
 	repoErr := fmt.Errorf("outer: %w", errors.New("inner"))
-	logger.LogRepoError("repo1", repoErr)
+	logger.LogRepoError("repo1", "patch", repoErr)
 
 	if err := logger.Close(); err != nil {
 		t.Fatalf("Close() error: %v", err)
@@ -65,3 +69,210 @@ func TestApplyLoggerLogRepoError(t *testing.T) {
 
 	_ = os.Remove(path)
 }
+
+func TestApplyLoggerLogRepoOutput(t *testing.T) {
+	logger, err := NewApplyLogger(FormatText, "run-1")
+	if err != nil {
+		t.Fatalf("NewApplyLogger() error: %v", err)
+	}
+
+	path := logger.Path()
+	logger.LogRepoOutput("repo1", "first\nsecond\n")
+	logger.LogRepoOutput("repo2", "")
+
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log file: %v", err)
+	}
+	text := string(data)
+
+	for _, want := range []string{"repo: repo1", "output:", "  first", "  second"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("expected log output to contain %q, got:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "repo2") {
+		t.Errorf("expected empty output to be skipped, got:\n%s", text)
+	}
+
+	_ = os.Remove(path)
+}
+
+func TestApplyLoggerJSON(t *testing.T) {
+	logger, err := NewApplyLogger(FormatJSON, "run-1")
+	if err != nil {
+		t.Fatalf("NewApplyLogger() error: %v", err)
+	}
+
+	path := logger.Path()
+	if !strings.HasSuffix(path, ".jsonl") {
+		t.Errorf("expected a .jsonl log file, got %s", path)
+	}
+	logger.LogCommand(git.CommandRecord{
+		Dir:      "repo1",
+		Args:     []string{"git", "apply", "fix.patch"},
+		Start:    time.Now(),
+		Duration: 1500 * time.Millisecond,
+		ExitCode: 1,
+		Output:   "error: patch failed\n",
+	})
+	logger.LogRepoError("repo1", "patch", fmt.Errorf("patch application failed: %w", errors.New("exit status 1")))
+	logger.LogRepoResult("repo1", "", errors.New("failed"))
+	logger.LogRepoOutput("repo2", "generated\n")
+	logger.LogRepoResult("repo2", "3 files edited", nil)
+
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	defer os.Remove(path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 events, got %d:\n%s", len(lines), data)
+	}
+	events := make([]Event, len(lines))
+	for i, line := range lines {
+		if err := json.Unmarshal([]byte(line), &events[i]); err != nil {
+			t.Fatalf("event %d is not valid JSON: %v\n%s", i, err, line)
+		}
+		if events[i].RunID != "run-1" || events[i].Time.IsZero() {
+			t.Errorf("event %d has no run ID or time: %s", i, line)
+		}
+	}
+
+	command := events[0].Command
+	if events[0].Event != "command" || command == nil || command.ExitCode != 1 || command.DurationMS != 1500 || command.Args[1] != "apply" {
+		t.Errorf("unexpected command event: %s", lines[0])
+	}
+	if events[0].Repo != "repo1" {
+		t.Errorf("expected command repo from its directory, got %q", events[0].Repo)
+	}
+	if events[1].Event != "error" || events[1].Step != "patch" || strings.Join(events[1].Errors, "|") != "patch application failed: exit status 1|exit status 1" {
+		t.Errorf("unexpected error event: %s", lines[1])
+	}
+	if events[2].Status != "fail" || events[4].Status != "ok" || events[4].Detail != "3 files edited" {
+		t.Errorf("unexpected result events: %s, %s", lines[2], lines[4])
+	}
+	if events[3].Event != "output" || events[3].Output != "generated\n" {
+		t.Errorf("unexpected output event: %s", lines[3])
+	}
+}
+
+func TestApplyLoggerJSONAttempts(t *testing.T) {
+	logger, err := NewApplyLogger(FormatJSON, "run-1")
+	if err != nil {
+		t.Fatalf("NewApplyLogger() error: %v", err)
+	}
+	path := logger.Path()
+	defer os.Remove(path)
+
+	logger.LogCommand(git.CommandRecord{Dir: "repo1", Args: []string{"git", "push"}, ExitCode: 128, Attempt: 1, RetryDelay: 2 * time.Second})
+	logger.LogCommand(git.CommandRecord{Dir: "repo1", Args: []string{"git", "push"}, Attempt: 2})
+	logger.LogCommand(git.CommandRecord{Dir: "repo1", Args: []string{"git", "commit"}})
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 events, got %d:\n%s", len(lines), data)
+	}
+	if !strings.Contains(lines[0], `"attempt":1,"retry_delay_ms":2000`) {
+		t.Errorf("expected the first attempt with its retry delay, got %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"attempt":2`) || strings.Contains(lines[1], "retry_delay_ms") {
+		t.Errorf("expected the second attempt without a retry, got %s", lines[1])
+	}
+	if strings.Contains(lines[2], "attempt") {
+		t.Errorf("expected no attempt for a command that is not retried, got %s", lines[2])
+	}
+}
+
+func TestApplyLoggerTextAttempts(t *testing.T) {
+	logger, err := NewApplyLogger(FormatText, "run-1")
+	if err != nil {
+		t.Fatalf("NewApplyLogger() error: %v", err)
+	}
+	path := logger.Path()
+	defer os.Remove(path)
+
+	logger.LogCommand(git.CommandRecord{Dir: "repo1", Args: []string{"git", "fetch"}, Attempt: 1})
+	logger.LogCommand(git.CommandRecord{Dir: "repo1", Args: []string{"git", "push", "origin"}, ExitCode: 128, Output: "fatal: the remote end hung up unexpectedly\n", Attempt: 1, Retried: true, RetryDelay: 1500 * time.Millisecond})
+	logger.LogCommand(git.CommandRecord{Dir: "repo1", Args: []string{"git", "push", "origin"}, Attempt: 2})
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log file: %v", err)
+	}
+	content := string(data)
+	if strings.Contains(content, "git fetch") {
+		t.Errorf("expected no entry for a command that succeeded at once, got:\n%s", content)
+	}
+	for _, want := range []string{
+		"command: git push origin\n",
+		"attempt: 1, exit code: 128, retrying in 1.5s\n",
+		"  fatal: the remote end hung up unexpectedly\n",
+		"attempt: 2, exit code: 0\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("expected %q in the text log, got:\n%s", want, content)
+		}
+	}
+}
+
+func TestApplyLoggerTextSkipsCommandsAndResults(t *testing.T) {
+	logger, err := NewApplyLogger(FormatText, "run-1")
+	if err != nil {
+		t.Fatalf("NewApplyLogger() error: %v", err)
+	}
+	path := logger.Path()
+	defer os.Remove(path)
+
+	logger.LogCommand(git.CommandRecord{Dir: "repo1", Args: []string{"git", "status"}})
+	logger.LogRepoResult("repo1", "", nil)
+	_ = logger.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log file: %v", err)
+	}
+	if len(data) != 0 {
+		t.Errorf("expected an empty text log, got:\n%s", data)
+	}
+}
+
+func TestFormatErrorChainSkipsTransparentWrappers(t *testing.T) {
+	inner := errors.New("inner")
+	err := fmt.Errorf("outer: %w", fmt.Errorf("%w", inner))
+
+	got := strings.Join(formatErrorChain(err), "\n")
+	if want := "- outer: inner\n  - inner"; got != want {
+		t.Errorf("formatErrorChain() = %q, want %q", got, want)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, name := range []string{"text", "json"} {
+		if format, err := ParseFormat(name); err != nil || string(format) != name {
+			t.Errorf("ParseFormat(%q) = %q, %v", name, format, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected error for unknown format, got nil")
+	}
+}
@@ -0,0 +1,169 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vpukhanov/cascade/internal/git"
+)
+
+// RunDir keeps the logs of a single run in its own directory: a transcript
+// per repository with every command that ran in it, and an index file
+// summarizing the run.
+type RunDir struct {
+	mu      sync.Mutex
+	path    string
+	runID   string
+	started time.Time
+	current *os.File
+	entries []runEntry
+}
+
+type runEntry struct {
+	repo       string
+	transcript string
+	detail     string
+	err        error
+}
+
+// unsafeFileChars matches characters that are replaced in transcript names.
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// NewRunDir creates the directory for the run inside parent.
+func NewRunDir(parent string, runID string) (*RunDir, error) {
+	path := filepath.Join(parent, runID)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, fmt.Errorf("create run log directory: %w", err)
+	}
+	return &RunDir{path: path, runID: runID, started: time.Now()}, nil
+}
+
+// Path returns the run directory path.
+func (d *RunDir) Path() string {
+	return d.path
+}
+
+// StartRepo opens the transcript of a repository. Commands logged until
+// FinishRepo are written to it.
+func (d *RunDir) StartRepo(repo string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	name := fmt.Sprintf("%02d-%s.log", len(d.entries)+1, unsafeFileChars.ReplaceAllString(filepath.Base(repo), "_"))
+	file, err := os.Create(filepath.Join(d.path, name))
+	if err != nil {
+		return fmt.Errorf("create transcript: %w", err)
+	}
+	fmt.Fprintf(file, "repo: %s\nstarted: %s\n\n", repo, time.Now().Format(time.RFC3339))
+	d.current = file
+	d.entries = append(d.entries, runEntry{repo: repo, transcript: name})
+	return nil
+}
+
+// LogCommand appends a command to the transcript of the current repository.
+// Commands that run outside of a repository are ignored.
+func (d *RunDir) LogCommand(rec git.CommandRecord) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.current == nil {
+		return
+	}
+	fmt.Fprintf(d.current, "[%s] $ %s\n", rec.Start.Format("15:04:05.000"), strings.Join(rec.Args, " "))
+	if rec.Dir != "" {
+		fmt.Fprintf(d.current, "dir: %s\n", rec.Dir)
+	}
+	fmt.Fprintf(d.current, "exit code: %d, duration: %s\n", rec.ExitCode, rec.Duration.Round(time.Millisecond))
+	if rec.Attempt > 0 {
+		fmt.Fprintf(d.current, "attempt: %d", rec.Attempt)
+		if rec.Retried {
+			fmt.Fprintf(d.current, ", retrying in %s", rec.RetryDelay.Round(time.Millisecond))
+		}
+		fmt.Fprintln(d.current)
+	}
+	if rec.Output != "" {
+		fmt.Fprintln(d.current, "output:")
+		for _, line := range strings.Split(strings.TrimSuffix(rec.Output, "\n"), "\n") {
+			fmt.Fprintf(d.current, "  %s\n", line)
+		}
+	}
+	fmt.Fprintln(d.current)
+}
+
+// FinishRepo records the result of the current repository and closes its
+// transcript.
+func (d *RunDir) FinishRepo(detail string, err error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.current == nil {
+		return nil
+	}
+	entry := &d.entries[len(d.entries)-1]
+	entry.detail = detail
+	entry.err = err
+
+	if err != nil {
+		fmt.Fprintln(d.current, "result: fail")
+		for _, line := range formatErrorChain(err) {
+			fmt.Fprintln(d.current, line)
+		}
+	} else if detail != "" {
+		fmt.Fprintf(d.current, "result: ok (%s)\n", detail)
+	} else {
+		fmt.Fprintln(d.current, "result: ok")
+	}
+
+	closeErr := d.current.Close()
+	d.current = nil
+	if closeErr != nil {
+		return fmt.Errorf("close transcript: %w", closeErr)
+	}
+	return nil
+}
+
+// Close writes the index file of the run.
+func (d *RunDir) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.current != nil {
+		_ = d.current.Close()
+		d.current = nil
+	}
+
+	var b strings.Builder
+	failed := 0
+	for _, entry := range d.entries {
+		if entry.err != nil {
+			failed++
+		}
+	}
+	fmt.Fprintf(&b, "run: %s\n", d.runID)
+	fmt.Fprintf(&b, "started: %s\n", d.started.Format(time.RFC3339))
+	fmt.Fprintf(&b, "finished: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "repositories: %d, failed: %d\n\n", len(d.entries), failed)
+	for _, entry := range d.entries {
+		status := "ok"
+		if entry.err != nil {
+			status = "fail"
+		}
+		fmt.Fprintf(&b, "%-4s %s -> %s\n", status, entry.repo, entry.transcript)
+		if entry.err != nil {
+			// The first line is enough here, the transcript has the chain
+			fmt.Fprintf(&b, "     %s\n", strings.SplitN(entry.err.Error(), "\n", 2)[0])
+		} else if entry.detail != "" {
+			fmt.Fprintf(&b, "     %s\n", entry.detail)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(d.path, "index.txt"), []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("write run index: %w", err)
+	}
+	return nil
+}
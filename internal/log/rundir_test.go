@@ -0,0 +1,116 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vpukhanov/cascade/internal/git"
+)
+
+func TestRunDir(t *testing.T) {
+	parent := t.TempDir()
+	runDir, err := NewRunDir(parent, "run-1")
+	if err != nil {
+		t.Fatalf("NewRunDir() error: %v", err)
+	}
+	if runDir.Path() != filepath.Join(parent, "run-1") {
+		t.Errorf("Path() = %q", runDir.Path())
+	}
+
+	// Commands outside of a repository are not logged anywhere
+	runDir.LogCommand(git.CommandRecord{Args: []string{"git", "version"}})
+
+	if err := runDir.StartRepo("/work/repo1"); err != nil {
+		t.Fatalf("StartRepo() error: %v", err)
+	}
+	runDir.LogCommand(git.CommandRecord{
+		Dir:      "/work/repo1",
+		Args:     []string{"git", "checkout", "-B", "feature"},
+		Start:    time.Now(),
+		Duration: 12 * time.Millisecond,
+		ExitCode: 0,
+		Output:   "Switched to a new branch 'feature'\n",
+	})
+	if err := runDir.FinishRepo("3 files edited", nil); err != nil {
+		t.Fatalf("FinishRepo() error: %v", err)
+	}
+
+	if err := runDir.StartRepo("/work/repo2"); err != nil {
+		t.Fatalf("StartRepo() error: %v", err)
+	}
+	runDir.LogCommand(git.CommandRecord{
+		Dir:        "/work/repo2",
+		Args:       []string{"git", "pull", "--ff-only"},
+		ExitCode:   1,
+		Output:     "fatal: Could not resolve host: example.com\n",
+		Attempt:    1,
+		Retried:    true,
+		RetryDelay: 1500 * time.Millisecond,
+	})
+	runDir.LogCommand(git.CommandRecord{
+		Dir:      "/work/repo2",
+		Args:     []string{"git", "apply", "fix.patch"},
+		ExitCode: 1,
+		Output:   "error: patch failed\n",
+	})
+	if err := runDir.FinishRepo("", fmt.Errorf("patch application failed: %w", errors.New("exit status 1"))); err != nil {
+		t.Fatalf("FinishRepo() error: %v", err)
+	}
+
+	if err := runDir.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{"01-repo1.log", []string{
+			"repo: /work/repo1",
+			"$ git checkout -B feature",
+			"dir: /work/repo1",
+			"exit code: 0, duration: 12ms",
+			"  Switched to a new branch 'feature'",
+			"result: ok (3 files edited)",
+		}},
+		{"02-repo2.log", []string{
+			"$ git pull --ff-only",
+			"attempt: 1, retrying in 1.5s",
+			"$ git apply fix.patch",
+			"exit code: 1",
+			"  error: patch failed",
+			"result: fail",
+			"- patch application failed: exit status 1",
+			"  - exit status 1",
+		}},
+		{"index.txt", []string{
+			"run: run-1",
+			"repositories: 2, failed: 1",
+			"ok   /work/repo1 -> 01-repo1.log",
+			"     3 files edited",
+			"fail /work/repo2 -> 02-repo2.log",
+			"     patch application failed: exit status 1",
+		}},
+	}
+	for _, tt := range tests {
+		data, err := os.ReadFile(filepath.Join(runDir.Path(), tt.file))
+		if err != nil {
+			t.Fatalf("read %s: %v", tt.file, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("%s does not contain %q:\n%s", tt.file, want, data)
+			}
+		}
+	}
+
+	data, _ := os.ReadFile(filepath.Join(runDir.Path(), "01-repo1.log"))
+	if strings.Contains(string(data), "git version") {
+		t.Errorf("command outside of a repository was logged:\n%s", data)
+	}
+}
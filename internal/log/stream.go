@@ -0,0 +1,76 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Stream writes the output of several repositories to one writer, prefixing
+// every line with the repository. Lines are written whole, so output from
+// repositories running at the same time never interleaves within a line.
+type Stream struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewStream creates a stream writing to w.
+func NewStream(w io.Writer) *Stream {
+	return &Stream{w: w}
+}
+
+// Writer returns a writer that prefixes lines with `[name] `. Call Flush
+// once done to write a trailing line without a newline.
+func (s *Stream) Writer(name string) *PrefixWriter {
+	return &PrefixWriter{stream: s, prefix: []byte("[" + name + "] ")}
+}
+
+func (s *Stream) writeLine(prefix, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.w.Write(prefix); err != nil {
+		return err
+	}
+	_, err := s.w.Write(line)
+	return err
+}
+
+// PrefixWriter buffers partial lines and writes complete lines to its stream.
+type PrefixWriter struct {
+	mu     sync.Mutex
+	stream *Stream
+	prefix []byte
+	buf    []byte
+}
+
+func (p *PrefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := p.stream.writeLine(p.prefix, p.buf[:i+1]); err != nil {
+			return len(b), err
+		}
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// Flush writes any buffered partial line, terminated with a newline.
+func (p *PrefixWriter) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.buf) == 0 {
+		return nil
+	}
+	line := append(p.buf, '\n')
+	p.buf = nil
+	return p.stream.writeLine(p.prefix, line)
+}
@@ -0,0 +1,62 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestStreamPrefixesLines(t *testing.T) {
+	var out bytes.Buffer
+	stream := NewStream(&out)
+	w := stream.Writer("repo1")
+
+	fmt.Fprint(w, "first line\nsecond ")
+	fmt.Fprint(w, "line\npartial")
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("second Flush() error: %v", err)
+	}
+
+	want := "[repo1] first line\n[repo1] second line\n[repo1] partial\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestStreamConcurrentWriters(t *testing.T) {
+	var out bytes.Buffer
+	stream := NewStream(&out)
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"repo1", "repo2", "repo3"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			w := stream.Writer(name)
+			for i := 0; i < 100; i++ {
+				// Write each line in two parts to exercise buffering
+				fmt.Fprintf(w, "%s line ", name)
+				fmt.Fprintf(w, "%d\n", i)
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 300 {
+		t.Fatalf("expected 300 lines, got %d", len(lines))
+	}
+	linePattern := regexp.MustCompile(`^\[(repo\d)\] (repo\d) line \d+$`)
+	for _, line := range lines {
+		m := linePattern.FindStringSubmatch(line)
+		if m == nil || m[1] != m[2] {
+			t.Errorf("interleaved line: %q", line)
+		}
+	}
+}
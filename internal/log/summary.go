@@ -0,0 +1,124 @@
+package log
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Summary is the overview of a campaign run, with a row per repository.
+type Summary struct {
+	RunID   string
+	Branch  string
+	Message string
+	// Template is set when Branch and Message are the templates, because
+	// they render differently for the repositories.
+	Template bool
+	Finished time.Time
+	Repos    []SummaryRepo
+}
+
+// SummaryRepo is the outcome of a run for a single repository.
+type SummaryRepo struct {
+	Repo   string
+	Status string
+	Branch string
+	Detail string
+	// DiffStat is the git diff --shortstat of the campaign branch.
+	DiffStat string
+	// URL opens the pull request of the branch.
+	URL string
+}
+
+// Summary file names.
+const (
+	SummaryHTML     = "summary.html"
+	SummaryMarkdown = "summary.md"
+)
+
+var summaryTemplate = template.Must(template.New("summary").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>cascade {{.Branch}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+.fail { color: #b00; }
+</style>
+</head>
+<body>
+<h1>{{.Branch}}</h1>
+<p>{{.Message}}</p>
+{{if .Template}}<p>Branch and message templates, rendered for every repository</p>
+{{end}}
+<p>Run {{.RunID}}, finished {{.Finished.Format "2006-01-02 15:04:05"}}</p>
+<table>
+<tr><th>Repository</th><th>Status</th><th>Branch</th><th>Changes</th><th>Pull request</th><th>Details</th></tr>
+{{range .Repos}}<tr class="{{.Status}}"><td>{{.Repo}}</td><td>{{.Status}}</td><td>{{.Branch}}</td><td>{{.DiffStat}}</td><td>{{if .URL}}<a href="{{.URL}}">{{.URL}}</a>{{end}}</td><td>{{.Detail}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// WriteSummary writes the summary as an HTML page and a Markdown file into
+// dir, and returns the path of the HTML page.
+func WriteSummary(dir string, s Summary) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("create summary directory: %w", err)
+	}
+
+	var page strings.Builder
+	if err := summaryTemplate.Execute(&page, s); err != nil {
+		return "", fmt.Errorf("render summary: %w", err)
+	}
+	htmlPath := filepath.Join(dir, SummaryHTML)
+	if err := os.WriteFile(htmlPath, []byte(page.String()), 0644); err != nil {
+		return "", fmt.Errorf("write summary: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, SummaryMarkdown), []byte(summaryMarkdown(s)), 0644); err != nil {
+		return "", fmt.Errorf("write summary: %w", err)
+	}
+	return htmlPath, nil
+}
+
+func summaryMarkdown(s Summary) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", s.Branch)
+	if s.Message != "" {
+		fmt.Fprintf(&b, "%s\n\n", firstLine(s.Message))
+	}
+	if s.Template {
+		b.WriteString("Branch and message templates, rendered for every repository\n\n")
+	}
+	fmt.Fprintf(&b, "Run %s, finished %s\n\n", s.RunID, s.Finished.Format("2006-01-02 15:04:05"))
+	b.WriteString("| Repository | Status | Branch | Changes | Pull request | Details |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, repo := range s.Repos {
+		url := repo.URL
+		if url != "" {
+			url = "[" + url + "](" + url + ")"
+		}
+		cells := []string{repo.Repo, repo.Status, repo.Branch, repo.DiffStat, url, repo.Detail}
+		for i, cell := range cells {
+			cells[i] = markdownCell(cell)
+		}
+		fmt.Fprintf(&b, "| %s |\n", strings.Join(cells, " | "))
+	}
+	return b.String()
+}
+
+// markdownCell keeps the value on one line and its pipes out of the table
+// syntax.
+func markdownCell(value string) string {
+	return strings.ReplaceAll(firstLine(value), "|", `\|`)
+}
+
+func firstLine(value string) string {
+	line, _, _ := strings.Cut(value, "\n")
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,91 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteSummary(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "summary")
+	summary := Summary{
+		RunID:    "20240102-030405-abcdef",
+		Branch:   "deps/bump",
+		Message:  "Bump <deps>\n\nDetails",
+		Finished: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Repos: []SummaryRepo{
+			{Repo: "./repo1", Status: "ok", Branch: "deps/bump", DiffStat: "1 file changed, 2 insertions(+)", URL: "https://github.com/org/repo1/compare/main...deps/bump?expand=1"},
+			{Repo: "./repo2", Status: "fail", Branch: "deps/bump", Detail: "command failed: a | b\nexit status 1"},
+		},
+	}
+
+	path, err := WriteSummary(dir, summary)
+	if err != nil {
+		t.Fatalf("WriteSummary failed: %v", err)
+	}
+	if path != filepath.Join(dir, SummaryHTML) {
+		t.Errorf("Expected the HTML page path, got %s", path)
+	}
+
+	page, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<h1>deps/bump</h1>",
+		"Bump &lt;deps&gt;",
+		`<a href="https://github.com/org/repo1/compare/main...deps/bump?expand=1">`,
+		`<tr class="fail"><td>./repo2</td>`,
+		"1 file changed, 2 insertions(&#43;)",
+	} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("Expected %q in the HTML summary, got:\n%s", want, page)
+		}
+	}
+
+	markdown, err := os.ReadFile(filepath.Join(dir, SummaryMarkdown))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# deps/bump\n\nBump <deps>\n\n",
+		"| ./repo1 | ok | deps/bump | 1 file changed, 2 insertions(+) | [https://github.com/org/repo1/compare/main...deps/bump?expand=1](https://github.com/org/repo1/compare/main...deps/bump?expand=1) |  |\n",
+		`| ./repo2 | fail | deps/bump |  |  | command failed: a \| b |` + "\n",
+	} {
+		if !strings.Contains(string(markdown), want) {
+			t.Errorf("Expected %q in the Markdown summary, got:\n%s", want, markdown)
+		}
+	}
+}
+
+func TestWriteSummaryTemplate(t *testing.T) {
+	dir := t.TempDir()
+	summary := Summary{
+		RunID:    "20240102-030405-abcdef",
+		Branch:   "deps/{{.RepoName}}",
+		Message:  "Bump {{.RepoName}}",
+		Template: true,
+		Repos:    []SummaryRepo{{Repo: "./repo1", Status: "ok", Branch: "deps/repo1"}},
+	}
+
+	path, err := WriteSummary(dir, summary)
+	if err != nil {
+		t.Fatalf("WriteSummary failed: %v", err)
+	}
+	page, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), "<p>Branch and message templates, rendered for every repository</p>") {
+		t.Errorf("Expected the heading to be labeled as the templates, got:\n%s", page)
+	}
+	markdown, err := os.ReadFile(filepath.Join(dir, SummaryMarkdown))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(markdown), "Bump {{.RepoName}}\n\nBranch and message templates, rendered for every repository\n\n") {
+		t.Errorf("Expected the heading to be labeled as the templates, got:\n%s", markdown)
+	}
+}
@@ -0,0 +1,106 @@
+package recipe
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Input declares a named value that the recipe expects to be passed with
+// --var. Inputs are available to templates as `{{.Vars.name}}`.
+type Input struct {
+	// Type is one of string (the default), semver, int or bool.
+	Type        string `yaml:"type"`
+	Required    bool   `yaml:"required"`
+	Default     string `yaml:"default"`
+	Description string `yaml:"description"`
+}
+
+var inputTypes = []string{"string", "semver", "int", "bool"}
+
+// semverPattern matches semantic versions with an optional `v` prefix.
+var semverPattern = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+	`(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+	`(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+func (in Input) typeName() string {
+	if in.Type == "" {
+		return "string"
+	}
+	return in.Type
+}
+
+func (in Input) validate() error {
+	if !slices.Contains(inputTypes, in.typeName()) {
+		return fmt.Errorf("unknown type %q, expected one of %s", in.Type, strings.Join(inputTypes, ", "))
+	}
+	if in.Default != "" {
+		if err := in.check(in.Default); err != nil {
+			return fmt.Errorf("invalid default: %w", err)
+		}
+	}
+	return nil
+}
+
+// check validates value against the input type.
+func (in Input) check(value string) error {
+	switch in.typeName() {
+	case "semver":
+		if !semverPattern.MatchString(value) {
+			return fmt.Errorf("%q is not a semantic version", value)
+		}
+	case "int":
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+	case "bool":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+	}
+	return nil
+}
+
+// ResolveVars validates the passed variables against the declared inputs and
+// fills in defaults. All problems are reported together.
+func (r *Recipe) ResolveVars(vars map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(r.Inputs))
+	var errs []error
+
+	for name := range vars {
+		if _, ok := r.Inputs[name]; !ok {
+			errs = append(errs, fmt.Errorf("unknown variable %q", name))
+		}
+	}
+
+	names := make([]string, 0, len(r.Inputs))
+	for name := range r.Inputs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		in := r.Inputs[name]
+		value, ok := vars[name]
+		if !ok {
+			if in.Required {
+				errs = append(errs, fmt.Errorf("variable %q is required", name))
+				continue
+			}
+			value = in.Default
+		} else if err := in.check(value); err != nil {
+			errs = append(errs, fmt.Errorf("variable %q: %w", name, err))
+			continue
+		}
+		resolved[name] = value
+	}
+
+	if len(errs) > 0 {
+		slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+		return nil, errors.Join(errs...)
+	}
+	return resolved, nil
+}
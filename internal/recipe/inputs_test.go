@@ -0,0 +1,53 @@
+package recipe
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveVars(t *testing.T) {
+	r := Recipe{Inputs: map[string]Input{
+		"version": {Type: "semver", Required: true},
+		"ticket":  {Required: true},
+		"retries": {Type: "int", Default: "3"},
+		"dry_run": {Type: "bool"},
+	}}
+
+	vars, err := r.ResolveVars(map[string]string{"version": "v1.2.3-rc.1", "ticket": "OPS-1"})
+	if err != nil {
+		t.Fatalf("ResolveVars error: %v", err)
+	}
+	if vars["version"] != "v1.2.3-rc.1" || vars["ticket"] != "OPS-1" || vars["retries"] != "3" {
+		t.Errorf("unexpected resolved vars: %v", vars)
+	}
+	if v, ok := vars["dry_run"]; !ok || v != "" {
+		t.Errorf("expected optional input without default to resolve to empty string, got %q", v)
+	}
+
+	_, err = r.ResolveVars(map[string]string{"version": "1.2", "retries": "many", "extra": "x"})
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		`unknown variable "extra"`,
+		`variable "ticket" is required`,
+		`variable "version": "1.2" is not a semantic version`,
+		`variable "retries": "many" is not an integer`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestInputValidate(t *testing.T) {
+	if err := (Input{Type: "float"}).validate(); err == nil {
+		t.Error("expected error for unknown input type")
+	}
+	if err := (Input{Type: "bool", Default: "maybe"}).validate(); err == nil {
+		t.Error("expected error for invalid default")
+	}
+	if err := (Input{Type: "semver", Default: "1.0.0"}).validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,294 @@
+package recipe
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/vpukhanov/cascade/internal/codemod"
+	"github.com/vpukhanov/cascade/internal/keyedit"
+	"github.com/vpukhanov/cascade/internal/tmpl"
+	"github.com/vpukhanov/cascade/internal/transform"
+	"github.com/vpukhanov/cascade/internal/validation"
+)
+
+// Recipe describes a campaign as an ordered list of steps together with the
+// options used to apply them.
+type Recipe struct {
+	Branch        string           `yaml:"branch"`
+	Message       string           `yaml:"message"`
+	BaseBranch    string           `yaml:"base_branch"`
+	Pull          bool             `yaml:"pull"`
+	Push          bool             `yaml:"push"`
+	NoVerify      bool             `yaml:"no_verify"`
+	Stash         bool             `yaml:"stash"`
+	OpenRemoteURL bool             `yaml:"open_remote_url"`
+	Update        string           `yaml:"update"`
+	OnExisting    string           `yaml:"on_existing"`
+	Remote        string           `yaml:"remote"`
+	ForkURL       string           `yaml:"fork_url"`
+	PRURLTemplate string           `yaml:"pr_url_template"`
+	ReviewSystem  string           `yaml:"review_system"`
+	Topic         string           `yaml:"topic"`
+	Reviewers     []string         `yaml:"reviewers"`
+	Hashtags      []string         `yaml:"hashtags"`
+	Inputs        map[string]Input `yaml:"inputs"`
+	Steps         []Step           `yaml:"steps"`
+}
+
+// Step is a single change applied to each repository. Exactly one action
+// field must be set.
+type Step struct {
+	Name     string                  `yaml:"name"`
+	Patch    string                  `yaml:"patch"`
+	Script   string                  `yaml:"script"`
+	Command  string                  `yaml:"command"`
+	Starlark string                  `yaml:"starlark"`
+	Wasm     string                  `yaml:"wasm"`
+	Go       *codemod.GoOptions      `yaml:"go"`
+	Set      []string                `yaml:"set"`
+	Replace  *codemod.ReplaceOptions `yaml:"replace"`
+	Verify   string                  `yaml:"verify"`
+	// Args are passed to a script, or to a command as $1, $2 and so on.
+	Args []string `yaml:"args"`
+	// Interpreter runs the script instead of detecting how to run it.
+	Interpreter string `yaml:"interpreter"`
+	// Commit is an optional message for a commit made right after the step.
+	Commit string `yaml:"commit"`
+	// Raw disables templating of the step's patch, commands, arguments,
+	// values and replace options.
+	Raw bool `yaml:"raw"`
+
+	// Assignments are the parsed Set specs.
+	Assignments []keyedit.Assignment `yaml:"-"`
+}
+
+// Load reads and validates a recipe file. Patch, script, Starlark and wasm
+// paths are resolved relative to the directory containing the recipe.
+func Load(path string) (*Recipe, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("recipe file does not exist: %s", path)
+		}
+		return nil, fmt.Errorf("error reading recipe file: %w", err)
+	}
+
+	var r Recipe
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&r); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing recipe %s: %w", path, err)
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	for i := range r.Steps {
+		r.Steps[i].resolvePaths(dir)
+	}
+
+	if err := r.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid recipe %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// Validate checks the recipe steps. Branch and message are validated by the
+// caller, since command line flags may override them.
+func (r *Recipe) Validate() error {
+	if len(r.Steps) == 0 {
+		return fmt.Errorf("recipe has no steps")
+	}
+	for name, in := range r.Inputs {
+		if err := in.validate(); err != nil {
+			return fmt.Errorf("input %q: %w", name, err)
+		}
+	}
+	for i := range r.Steps {
+		if err := r.Steps[i].Validate(); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// CommitsEveryStep reports whether every step that changes files makes its
+// own commit, so no final commit message is needed.
+func (r *Recipe) CommitsEveryStep() bool {
+	for _, step := range r.Steps {
+		if step.Kind() != "verify" && step.Commit == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// CommitsAnyStep reports whether at least one step makes its own commit.
+func (r *Recipe) CommitsAnyStep() bool {
+	return slices.ContainsFunc(r.Steps, func(step Step) bool {
+		return step.Commit != ""
+	})
+}
+
+func (s *Step) resolvePaths(dir string) {
+	if s.Patch != "" && !filepath.IsAbs(s.Patch) {
+		s.Patch = filepath.Join(dir, s.Patch)
+	}
+	if s.Script != "" && !filepath.IsAbs(s.Script) {
+		s.Script = filepath.Join(dir, s.Script)
+	}
+	if s.Starlark != "" && !filepath.IsAbs(s.Starlark) {
+		s.Starlark = filepath.Join(dir, s.Starlark)
+	}
+	if s.Wasm != "" && !filepath.IsAbs(s.Wasm) {
+		s.Wasm = filepath.Join(dir, s.Wasm)
+	}
+}
+
+// Kind returns the name of the step action.
+func (s *Step) Kind() string {
+	switch {
+	case s.Patch != "":
+		return "patch"
+	case s.Script != "":
+		return "script"
+	case s.Command != "":
+		return "command"
+	case s.Starlark != "":
+		return "starlark"
+	case s.Wasm != "":
+		return "wasm"
+	case s.Go != nil:
+		return "go"
+	case len(s.Set) > 0 || len(s.Assignments) > 0:
+		return "set"
+	case s.Replace != nil:
+		return "replace"
+	case s.Verify != "":
+		return "verify"
+	}
+	return ""
+}
+
+// Describe returns the step name, falling back to its kind.
+func (s *Step) Describe() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Kind()
+}
+
+// Validate checks that exactly one action is set and that it is well-formed.
+func (s *Step) Validate() error {
+	actions := 0
+	for _, set := range []bool{
+		s.Patch != "", s.Script != "", s.Command != "", s.Starlark != "", s.Wasm != "",
+		s.Go != nil, len(s.Set) > 0 || len(s.Assignments) > 0, s.Replace != nil, s.Verify != "",
+	} {
+		if set {
+			actions++
+		}
+	}
+	if actions == 0 {
+		return fmt.Errorf("one of patch, script, command, starlark, wasm, go, set, replace, or verify must be specified")
+	}
+	if actions > 1 {
+		return fmt.Errorf("patch, script, command, starlark, wasm, go, set, replace, and verify cannot be used together")
+	}
+	if s.Verify != "" && s.Commit != "" {
+		return fmt.Errorf("verify steps cannot make a commit")
+	}
+	if err := tmpl.Validate("commit message", s.Commit); err != nil {
+		return err
+	}
+	if len(s.Args) > 0 && s.Kind() != "script" && s.Kind() != "command" {
+		return fmt.Errorf("args can only be used with script and command steps")
+	}
+	if s.Interpreter != "" && s.Kind() != "script" {
+		return fmt.Errorf("interpreter can only be used with script steps")
+	}
+	if err := s.validateAction(); err != nil {
+		return err
+	}
+	return s.ValidateTemplates()
+}
+
+func (s *Step) validateAction() error {
+	switch s.Kind() {
+	case "patch":
+		return validation.ValidateFile(s.Patch, "patch")
+	case "script":
+		return validation.ValidateScript(s.Script, s.Interpreter)
+	case "starlark":
+		if err := validation.ValidateFile(s.Starlark, "starlark"); err != nil {
+			return err
+		}
+		return transform.ValidateStarlark(s.Starlark)
+	case "wasm":
+		return validation.ValidateFile(s.Wasm, "wasm")
+	case "go":
+		if s.Go.IsZero() {
+			return fmt.Errorf("go step has no rewrites")
+		}
+		return s.Go.Validate()
+	case "set":
+		if len(s.Set) == 0 {
+			return nil
+		}
+		s.Assignments = s.Assignments[:0]
+		for _, spec := range s.Set {
+			a, err := keyedit.ParseAssignment(spec)
+			if err != nil {
+				return err
+			}
+			s.Assignments = append(s.Assignments, a)
+		}
+	case "replace":
+		return s.Replace.Validate()
+	}
+	return nil
+}
+
+// ValidateTemplates checks that the patch, commands, arguments, values and
+// replace options of the step are valid templates, unless the step is raw.
+func (s *Step) ValidateTemplates() error {
+	if s.Raw {
+		return nil
+	}
+	if err := tmpl.Validate(s.Kind(), s.Command+s.Verify); err != nil {
+		return err
+	}
+	for _, arg := range s.Args {
+		if err := tmpl.Validate("script argument", arg); err != nil {
+			return err
+		}
+	}
+	for _, a := range s.Assignments {
+		if err := tmpl.Validate("value", a.Value); err != nil {
+			return err
+		}
+	}
+	if s.Replace != nil {
+		if err := tmpl.Validate("replace", s.Replace.Pattern+s.Replace.With); err != nil {
+			return err
+		}
+	}
+	if s.Patch != "" {
+		content, err := os.ReadFile(s.Patch)
+		if err != nil {
+			return fmt.Errorf("error reading patch file: %w", err)
+		}
+		if err := tmpl.Validate("patch", string(content)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
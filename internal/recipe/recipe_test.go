@@ -0,0 +1,192 @@
+package recipe
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "fix.patch", "diff --git a/a b/a\n")
+	writeFile(t, dir, "transform.star", "repo.write(\"a\", \"b\")\n")
+	writeFile(t, dir, "transform.wasm", "\x00asm")
+	writeFile(t, dir, "upgrade.yaml", `branch: upgrade-logging
+message: Upgrade logging
+base_branch: main
+push: true
+steps:
+  - name: bump version
+    set: ["package.json:version=2.0.0"]
+    commit: Bump version
+  - patch: fix.patch
+  - replace:
+      files: ["**/*.go"]
+      pattern: 'log\.Printf'
+      with: 'slog.Info'
+  - go:
+      rewrite: ["a.Old(x) -> a.New(x)"]
+  - starlark: transform.star
+  - wasm: transform.wasm
+  - command: go mod tidy
+  - verify: go build ./...
+`)
+
+	r, err := Load(filepath.Join(dir, "upgrade.yaml"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if r.Branch != "upgrade-logging" || r.Message != "Upgrade logging" || r.BaseBranch != "main" || !r.Push {
+		t.Errorf("unexpected recipe options: %+v", r)
+	}
+	if len(r.Steps) != 8 {
+		t.Fatalf("expected 8 steps, got %d", len(r.Steps))
+	}
+
+	wantKinds := []string{"set", "patch", "replace", "go", "starlark", "wasm", "command", "verify"}
+	for i, want := range wantKinds {
+		if got := r.Steps[i].Kind(); got != want {
+			t.Errorf("step %d kind = %q, want %q", i+1, got, want)
+		}
+	}
+	if r.Steps[0].Describe() != "bump version" || r.Steps[1].Describe() != "patch" {
+		t.Errorf("unexpected step descriptions: %q, %q", r.Steps[0].Describe(), r.Steps[1].Describe())
+	}
+	if len(r.Steps[0].Assignments) != 1 || r.Steps[0].Assignments[0].Key() != "version" {
+		t.Errorf("expected parsed set assignment, got %+v", r.Steps[0].Assignments)
+	}
+	if want := filepath.Join(dir, "fix.patch"); r.Steps[1].Patch != want {
+		t.Errorf("expected patch path %q, got %q", want, r.Steps[1].Patch)
+	}
+	if want := filepath.Join(dir, "transform.star"); r.Steps[4].Starlark != want {
+		t.Errorf("expected starlark path %q, got %q", want, r.Steps[4].Starlark)
+	}
+	if want := filepath.Join(dir, "transform.wasm"); r.Steps[5].Wasm != want {
+		t.Errorf("expected wasm path %q, got %q", want, r.Steps[5].Wasm)
+	}
+	if r.CommitsEveryStep() {
+		t.Error("expected CommitsEveryStep to be false")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "no_steps",
+			content: "branch: x\n",
+			wantErr: "no steps",
+		},
+		{
+			name:    "unknown_field",
+			content: "branch: x\nsteps:\n  - comand: ls\n",
+			wantErr: "comand",
+		},
+		{
+			name:    "two_actions",
+			content: "steps:\n  - command: ls\n    verify: ls\n",
+			wantErr: "cannot be used together",
+		},
+		{
+			name:    "empty_step",
+			content: "steps:\n  - name: nothing\n",
+			wantErr: "must be specified",
+		},
+		{
+			name:    "verify_commit",
+			content: "steps:\n  - verify: make test\n    commit: Test\n",
+			wantErr: "cannot make a commit",
+		},
+		{
+			name:    "missing_patch",
+			content: "steps:\n  - patch: missing.patch\n",
+			wantErr: "does not exist",
+		},
+		{
+			name:    "missing_starlark",
+			content: "steps:\n  - starlark: missing.star\n",
+			wantErr: "starlark file does not exist",
+		},
+		{
+			name:    "args_on_verify",
+			content: "steps:\n  - verify: make test\n    args: [x]\n",
+			wantErr: "args can only be used",
+		},
+		{
+			name:    "invalid_input_type",
+			content: "inputs:\n  version:\n    type: float\nsteps:\n  - command: ls\n",
+			wantErr: "unknown type",
+		},
+		{
+			name:    "invalid_command_template",
+			content: "steps:\n  - command: echo {{.Vars.x\n",
+			wantErr: "invalid command template",
+		},
+		{
+			name:    "invalid_set_template",
+			content: "steps:\n  - set: ['values.yaml:tag={{.Vars.x']\n",
+			wantErr: "invalid value template",
+		},
+		{
+			name:    "invalid_replace",
+			content: "steps:\n  - replace:\n      files: ['*']\n      pattern: '('\n",
+			wantErr: "invalid replace pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, dir, "recipe.yaml", tt.content)
+			_, err := Load(filepath.Join(dir, "recipe.yaml"))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	dir := t.TempDir()
+	writeFile(t, dir, "bump.patch", "+version {{.Vars.x\n")
+	writeFile(t, dir, "recipe.yaml", "steps:\n  - patch: bump.patch\n")
+	if _, err := Load(filepath.Join(dir, "recipe.yaml")); err == nil || !strings.Contains(err.Error(), "invalid patch template") {
+		t.Errorf("Load() error = %v, want invalid patch template", err)
+	}
+	writeFile(t, dir, "recipe.yaml", "steps:\n  - patch: bump.patch\n    raw: true\n")
+	if _, err := Load(filepath.Join(dir, "recipe.yaml")); err != nil {
+		t.Errorf("Load() of a raw patch failed: %v", err)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected error for missing recipe file")
+	}
+}
+
+func TestCommitsEveryStep(t *testing.T) {
+	r := Recipe{Steps: []Step{
+		{Command: "make gen", Commit: "Generate"},
+		{Verify: "make test"},
+	}}
+	if !r.CommitsEveryStep() {
+		t.Error("expected CommitsEveryStep to be true when verify steps are the only ones without commits")
+	}
+	if !r.CommitsAnyStep() {
+		t.Error("expected CommitsAnyStep to be true")
+	}
+
+	r.Steps[0].Commit = ""
+	if r.CommitsAnyStep() {
+		t.Error("expected CommitsAnyStep to be false without step commits")
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,118 @@
+package tmpl
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// Data is the data available to templates, such as `{{.RepoName}}` or
+// `{{.Vars.version}}`.
+type Data struct {
+	// RepoName is the base name of the repository directory.
+	RepoName string
+	// RepoPath is the repository path as passed on the command line.
+	RepoPath string
+	// Remote is the URL of the origin remote, empty if there is none.
+	Remote string
+	// BaseBranch is the branch the changes are applied on top of.
+	BaseBranch string
+	// Branch is the rendered campaign branch. It is empty while rendering
+	// the branch name itself.
+	Branch string
+	// Date is the date of the run in the YYYY-MM-DD form.
+	Date string
+	// RunID identifies the cascade run and is shared by all repositories.
+	RunID string
+	// Vars are the user variables passed with --var.
+	Vars map[string]string
+}
+
+// Render executes text as a Go template with data. Referencing a variable
+// that was not provided is an error.
+func Render(name, text string, data Data) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	t, err := parse(name, text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("error rendering %s template: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+// Validate checks that text is a well-formed template.
+func Validate(name, text string) error {
+	_, err := parse(name, text)
+	return err
+}
+
+func parse(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return t, nil
+}
+
+// Env returns the data as CASCADE_* environment variables for scripts and
+// commands. Variables are exported as CASCADE_VAR_<NAME>.
+func (d Data) Env() []string {
+	repoPath := d.RepoPath
+	if absPath, err := filepath.Abs(repoPath); err == nil {
+		repoPath = absPath
+	}
+	env := []string{
+		"CASCADE_REPO_PATH=" + repoPath,
+		"CASCADE_REPO_NAME=" + d.RepoName,
+		"CASCADE_BASE_BRANCH=" + d.BaseBranch,
+		"CASCADE_BRANCH=" + d.Branch,
+		"CASCADE_REMOTE_URL=" + d.Remote,
+		"CASCADE_RUN_ID=" + d.RunID,
+	}
+
+	names := make([]string, 0, len(d.Vars))
+	for name := range d.Vars {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		env = append(env, VarEnvName(name)+"="+d.Vars[name])
+	}
+	return env
+}
+
+// VarEnvName returns the environment variable name for a variable, such as
+// CASCADE_VAR_GO_VERSION for `go-version`.
+func VarEnvName(name string) string {
+	return "CASCADE_VAR_" + strings.ToUpper(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name))
+}
+
+// ParseVars parses `key=value` pairs into a map.
+func ParseVars(specs []string) (map[string]string, error) {
+	vars := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("variable must be of the form 'key=value': %q", spec)
+		}
+		if _, exists := vars[key]; exists {
+			return nil, fmt.Errorf("variable %q is set more than once", key)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
@@ -0,0 +1,85 @@
+package tmpl
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	data := Data{Vars: map[string]string{"version": "1.2.3", "ticket": "OPS-1"}}
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain_text", text: "Update logging", want: "Update logging"},
+		{name: "variables", text: "deps/{{.Vars.ticket}}-{{.Vars.version}}", want: "deps/OPS-1-1.2.3"},
+		{name: "missing_variable", text: "{{.Vars.missing}}", wantErr: true},
+		{name: "malformed", text: "{{.Vars.version", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render("test", tt.text, data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Render(%q) expected error", tt.text)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Render(%q) error: %v", tt.text, err)
+			}
+			if got != tt.want {
+				t.Errorf("Render(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVars(t *testing.T) {
+	vars, err := ParseVars([]string{"version=1.2.3", "query=a=b", "empty="})
+	if err != nil {
+		t.Fatalf("ParseVars error: %v", err)
+	}
+	if vars["version"] != "1.2.3" || vars["query"] != "a=b" || vars["empty"] != "" {
+		t.Errorf("unexpected vars: %v", vars)
+	}
+
+	for _, specs := range [][]string{{"novalue"}, {"=value"}, {"a=1", "a=2"}} {
+		if _, err := ParseVars(specs); err == nil {
+			t.Errorf("ParseVars(%q) expected error", specs)
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	data := Data{
+		RepoName:   "service",
+		RepoPath:   "/src/service",
+		Remote:     "git@example.com:org/service.git",
+		BaseBranch: "main",
+		Branch:     "deps/bump",
+		RunID:      "20260102-150405-abcdef",
+		Vars:       map[string]string{"version": "1.2.3", "go-version": "1.25"},
+	}
+
+	got := data.Env()
+	want := []string{
+		"CASCADE_REPO_PATH=/src/service",
+		"CASCADE_REPO_NAME=service",
+		"CASCADE_BASE_BRANCH=main",
+		"CASCADE_BRANCH=deps/bump",
+		"CASCADE_REMOTE_URL=git@example.com:org/service.git",
+		"CASCADE_RUN_ID=20260102-150405-abcdef",
+		"CASCADE_VAR_GO_VERSION=1.25",
+		"CASCADE_VAR_VERSION=1.2.3",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Env() = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Env()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
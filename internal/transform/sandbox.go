@@ -0,0 +1,72 @@
+package transform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sandbox resolves transformation paths against the repository root and
+// refuses anything that would escape it.
+type sandbox struct {
+	root     string
+	realRoot string
+}
+
+func newSandbox(repoPath string) (*sandbox, error) {
+	root, err := filepath.Abs(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+	return &sandbox{root: root, realRoot: realRoot}, nil
+}
+
+// resolve returns the absolute path for a slash-separated path relative to
+// the repository root. Absolute paths, `..` components, the .git directory
+// and symlinks pointing outside the repository are rejected.
+func (s *sandbox) resolve(name string) (string, error) {
+	rel := filepath.FromSlash(name)
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("path %q is outside the repository", name)
+	}
+	rel = filepath.Clean(rel)
+	if first, _, _ := strings.Cut(filepath.ToSlash(rel), "/"); first == ".git" {
+		return "", fmt.Errorf("path %q is inside the .git directory", name)
+	}
+
+	path := filepath.Join(s.root, rel)
+	real, err := evalExisting(path)
+	if err != nil {
+		return "", err
+	}
+	if real != s.realRoot && !strings.HasPrefix(real, s.realRoot+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q is outside the repository", name)
+	}
+	return path, nil
+}
+
+// evalExisting resolves symlinks in the longest existing prefix of path and
+// appends the rest unchanged.
+func evalExisting(path string) (string, error) {
+	var rest []string
+	for {
+		real, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{real}, rest...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		rest = append([]string{filepath.Base(path)}, rest...)
+		path = parent
+	}
+}
@@ -0,0 +1,265 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+
+	"github.com/vpukhanov/cascade/internal/codemod"
+)
+
+// fileOptions enables the language features scripts are most likely to need
+// on top of the Starlark core.
+var fileOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+	Recursion:       true,
+}
+
+// predeclaredNames are the globals available to every script.
+var predeclaredNames = starlark.StringDict{
+	"repo":      starlark.None,
+	"vars":      starlark.None,
+	"repo_name": starlark.None,
+}
+
+// ValidateStarlark checks that the script parses and only references
+// predeclared names.
+func ValidateStarlark(scriptPath string) error {
+	src, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return fmt.Errorf("error reading starlark file: %w", err)
+	}
+	if _, _, err := starlark.SourceProgramOptions(fileOptions, scriptPath, src, predeclaredNames.Has); err != nil {
+		return fmt.Errorf("invalid starlark file: %w", err)
+	}
+	return nil
+}
+
+// RunStarlark executes a Starlark script against the repository. The script
+// can only touch files inside the repository through the `repo` module and
+// has no access to the network, processes or the environment. The variables
+// are exposed as the `vars` dict. The script is cancelled when it runs longer
+// than the timeout, unless the timeout is zero.
+func RunStarlark(repoPath, scriptPath string, vars map[string]string, timeout time.Duration) error {
+	src, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return fmt.Errorf("error reading starlark file: %w", err)
+	}
+
+	sb, err := newSandbox(repoPath)
+	if err != nil {
+		return fmt.Errorf("error running starlark script: %w", err)
+	}
+
+	varsDict := starlark.NewDict(len(vars))
+	for key, value := range vars {
+		if err := varsDict.SetKey(starlark.String(key), starlark.String(value)); err != nil {
+			return fmt.Errorf("error running starlark script: %w", err)
+		}
+	}
+	varsDict.Freeze()
+
+	var output strings.Builder
+	thread := &starlark.Thread{
+		Name: "cascade",
+		Print: func(_ *starlark.Thread, msg string) {
+			output.WriteString(msg)
+			output.WriteString("\n")
+		},
+		Load: func(_ *starlark.Thread, module string) (starlark.StringDict, error) {
+			return nil, fmt.Errorf("load is not supported: %s", module)
+		},
+	}
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			thread.Cancel(fmt.Sprintf("timed out after %s", timeout))
+		})
+		defer timer.Stop()
+	}
+
+	predeclared := starlark.StringDict{
+		"repo":      sb.module(),
+		"vars":      varsDict,
+		"repo_name": starlark.String(filepath.Base(sb.root)),
+	}
+	if _, err := starlark.ExecFileOptions(fileOptions, thread, scriptPath, src, predeclared); err != nil {
+		var evalErr *starlark.EvalError
+		if errors.As(err, &evalErr) {
+			return fmt.Errorf("error running starlark script: %s\n%s", evalErr.Backtrace(), output.String())
+		}
+		return fmt.Errorf("error running starlark script: %w\n%s", err, output.String())
+	}
+	return nil
+}
+
+func (s *sandbox) module() *starlarkstruct.Module {
+	return &starlarkstruct.Module{
+		Name: "repo",
+		Members: starlark.StringDict{
+			"read":   starlark.NewBuiltin("read", s.read),
+			"write":  starlark.NewBuiltin("write", s.write),
+			"exists": starlark.NewBuiltin("exists", s.exists),
+			"remove": starlark.NewBuiltin("remove", s.remove),
+			"glob":   starlark.NewBuiltin("glob", s.glob),
+			"sub":    starlark.NewBuiltin("sub", s.sub),
+		},
+	}
+}
+
+// read(path) returns the file contents.
+func (s *sandbox) read(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "path", &name); err != nil {
+		return nil, err
+	}
+	path, err := s.resolve(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	return starlark.String(data), nil
+}
+
+// write(path, content) replaces the file contents, creating the file and its
+// parent directories as needed.
+func (s *sandbox) write(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name, content string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "path", &name, "content", &content); err != nil {
+		return nil, err
+	}
+	path, err := s.resolve(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	if err := writeFile(path, content); err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	return starlark.None, nil
+}
+
+// exists(path) reports whether the file or directory exists.
+func (s *sandbox) exists(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "path", &name); err != nil {
+		return nil, err
+	}
+	path, err := s.resolve(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	_, err = os.Stat(path)
+	return starlark.Bool(err == nil), nil
+}
+
+// remove(path) deletes the file.
+func (s *sandbox) remove(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "path", &name); err != nil {
+		return nil, err
+	}
+	path, err := s.resolve(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	return starlark.None, nil
+}
+
+// glob(*patterns) returns the sorted paths of files matching any pattern.
+func (s *sandbox) glob(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if len(kwargs) > 0 {
+		return nil, fmt.Errorf("%s: unexpected keyword arguments", b.Name())
+	}
+	patterns := make([]string, len(args))
+	for i, arg := range args {
+		pattern, ok := starlark.AsString(arg)
+		if !ok {
+			return nil, fmt.Errorf("%s: pattern %d is not a string", b.Name(), i+1)
+		}
+		patterns[i] = pattern
+	}
+
+	matches, err := codemod.Glob(s.root, patterns)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	slices.Sort(matches)
+	values := make([]starlark.Value, 0, len(matches))
+	for _, match := range matches {
+		// Skip symlinks that lead outside the repository.
+		if _, err := s.resolve(match); err != nil {
+			continue
+		}
+		values = append(values, starlark.String(match))
+	}
+	return starlark.NewList(values), nil
+}
+
+// sub(path, pattern, repl, count=-1) replaces regular expression matches in
+// the file and returns the number of replacements. repl may reference groups
+// as `$1`.
+func (s *sandbox) sub(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name, pattern, repl string
+	count := -1
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "path", &name, "pattern", &pattern, "repl", &repl, "count?", &count); err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid pattern: %w", b.Name(), err)
+	}
+	path, err := s.resolve(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+
+	matches := re.FindAllSubmatchIndex(data, count)
+	if len(matches) == 0 {
+		return starlark.MakeInt(0), nil
+	}
+	var out []byte
+	last := 0
+	for _, m := range matches {
+		out = append(out, data[last:m[0]]...)
+		out = re.Expand(out, []byte(repl), data, m)
+		last = m[1]
+	}
+	out = append(out, data[last:]...)
+	if err := writeFile(path, string(out)); err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	return starlark.MakeInt(len(matches)), nil
+}
+
+// writeFile writes content to path, keeping the mode of an existing file.
+func writeFile(path, content string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), mode)
+}
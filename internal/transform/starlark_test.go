@@ -0,0 +1,181 @@
+package transform
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, root, name, content string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, root, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func runScript(t *testing.T, repoPath, script string, vars map[string]string) error {
+	t.Helper()
+	scriptPath := filepath.Join(t.TempDir(), "transform.star")
+	if err := os.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return RunStarlark(repoPath, scriptPath, vars, time.Minute)
+}
+
+func TestRunStarlark(t *testing.T) {
+	repoPath := t.TempDir()
+	writeTestFile(t, repoPath, "main.go", "log.Printf(\"a\")\nlog.Printf(\"b\")\n")
+	writeTestFile(t, repoPath, "pkg/util.go", "log.Println(\"c\")\n")
+	writeTestFile(t, repoPath, "VERSION", "1.0.0\n")
+
+	script := `
+edited = 0
+for path in repo.glob("**/*.go"):
+    edited += repo.sub(path, r"log\.Print(f|ln)", "slog.Info$1")
+if edited != 3:
+    fail("expected 3 replacements, got %d" % edited)
+
+repo.write("VERSION", vars["version"] + "\n")
+repo.write("docs/" + repo_name + ".md", "generated\n")
+if not repo.exists("docs"):
+    fail("docs was not created")
+repo.remove("pkg/util.go")
+`
+	if err := runScript(t, repoPath, script, map[string]string{"version": "2.0.0"}); err != nil {
+		t.Fatalf("RunStarlark failed: %v", err)
+	}
+
+	if got := readTestFile(t, repoPath, "main.go"); got != "slog.Infof(\"a\")\nslog.Infof(\"b\")\n" {
+		t.Errorf("main.go = %q", got)
+	}
+	if got := readTestFile(t, repoPath, "VERSION"); got != "2.0.0\n" {
+		t.Errorf("VERSION = %q", got)
+	}
+	if got := readTestFile(t, repoPath, "docs/"+filepath.Base(repoPath)+".md"); got != "generated\n" {
+		t.Errorf("docs file = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(repoPath, "pkg", "util.go")); !os.IsNotExist(err) {
+		t.Errorf("pkg/util.go was not removed")
+	}
+}
+
+func TestRunStarlarkSubCount(t *testing.T) {
+	repoPath := t.TempDir()
+	writeTestFile(t, repoPath, "a.txt", "x x x\n")
+
+	if err := runScript(t, repoPath, `repo.sub("a.txt", "^x", "y")`+"\n"+`repo.sub("a.txt", "x", "z", count=1)`, nil); err != nil {
+		t.Fatalf("RunStarlark failed: %v", err)
+	}
+	if got := readTestFile(t, repoPath, "a.txt"); got != "y z x\n" {
+		t.Errorf("a.txt = %q, want %q", got, "y z x\n")
+	}
+}
+
+func TestRunStarlarkSandbox(t *testing.T) {
+	outside := t.TempDir()
+	writeTestFile(t, outside, "secret", "secret\n")
+
+	repoPath := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(repoPath, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		script string
+		errMsg string
+	}{
+		{"absolute path", `repo.read("` + filepath.ToSlash(filepath.Join(outside, "secret")) + `")`, "outside the repository"},
+		{"parent directory", `repo.write("../escape", "x")`, "outside the repository"},
+		{"symlink", `repo.read("link/secret")`, "outside the repository"},
+		{"symlink write", `repo.write("link/new", "x")`, "outside the repository"},
+		{"git directory", `repo.write(".git/hooks/pre-commit", "x")`, "inside the .git directory"},
+		{"load", `load("other.star", "x")`, "load is not supported"},
+		{"fail", `print("context")` + "\n" + `fail("boom")`, "boom"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runScript(t, repoPath, tt.script, nil)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("error %q does not contain %q", err, tt.errMsg)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(outside, "new")); !os.IsNotExist(err) {
+		t.Error("file was written outside the repository")
+	}
+}
+
+func TestRunStarlarkGlobSkipsEscapingSymlinks(t *testing.T) {
+	outside := t.TempDir()
+	writeTestFile(t, outside, "secret.txt", "secret\n")
+
+	repoPath := t.TempDir()
+	writeTestFile(t, repoPath, "a.txt", "a\n")
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(repoPath, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	script := `
+files = repo.glob("*.txt")
+if files != ["a.txt"]:
+    fail("unexpected files: %s" % files)
+`
+	if err := runScript(t, repoPath, script, nil); err != nil {
+		t.Fatalf("RunStarlark failed: %v", err)
+	}
+}
+
+func TestRunStarlarkTimeout(t *testing.T) {
+	scriptPath := filepath.Join(t.TempDir(), "loop.star")
+	if err := os.WriteFile(scriptPath, []byte("while True:\n    pass\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := RunStarlark(t.TempDir(), scriptPath, nil, 50*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected error for a looping script, got nil")
+	}
+	if !strings.Contains(err.Error(), "timed out after 50ms") {
+		t.Errorf("expected timeout error, got: %v", err)
+	}
+}
+
+func TestValidateStarlark(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.star")
+	writeTestFile(t, dir, "valid.star", "repo.write(\"a\", vars.get(\"a\", repo_name))\n")
+	if err := ValidateStarlark(valid); err != nil {
+		t.Errorf("ValidateStarlark(valid) failed: %v", err)
+	}
+
+	writeTestFile(t, dir, "syntax.star", "def broken(:\n")
+	if err := ValidateStarlark(filepath.Join(dir, "syntax.star")); err == nil {
+		t.Error("expected error for syntax error, got nil")
+	}
+
+	writeTestFile(t, dir, "undefined.star", "os.system(\"rm -rf /\")\n")
+	if err := ValidateStarlark(filepath.Join(dir, "undefined.star")); err == nil {
+		t.Error("expected error for undefined name, got nil")
+	}
+}
@@ -0,0 +1,190 @@
+package transform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/experimental/sysfs"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+
+	"github.com/vpukhanov/cascade/internal/tmpl"
+)
+
+// RunWasm runs a WASI command module against the repository. The repository
+// is mounted at / as the only filesystem, and the module has no access to the
+// network, host processes or the host environment. Variables are passed as
+// CASCADE_VAR_<NAME> environment variables. The module is stopped when it
+// runs longer than the timeout, unless the timeout is zero.
+func RunWasm(repoPath, modulePath string, vars map[string]string, timeout time.Duration) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	binary, err := os.ReadFile(modulePath)
+	if err != nil {
+		return fmt.Errorf("error reading wasm module: %w", err)
+	}
+	sb, err := newSandbox(repoPath)
+	if err != nil {
+		return fmt.Errorf("error running wasm module: %w", err)
+	}
+
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	defer r.Close(ctx)
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+
+	compiled, err := r.CompileModule(ctx, binary)
+	if err != nil {
+		return fmt.Errorf("invalid wasm module: %w", err)
+	}
+
+	var output strings.Builder
+	fsConfig := wazero.NewFSConfig().(sysfs.FSConfig).WithSysFSMount(&repoFS{FS: sysfs.DirFS(sb.root), sb: sb}, "/")
+	config := wazero.NewModuleConfig().
+		WithName(filepath.Base(modulePath)).
+		WithArgs(filepath.Base(modulePath)).
+		WithFSConfig(fsConfig).
+		WithStdout(&output).
+		WithStderr(&output)
+	for name, value := range vars {
+		config = config.WithEnv(tmpl.VarEnvName(name), value)
+	}
+
+	mod, err := r.InstantiateModule(ctx, compiled, config)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("wasm module timed out after %s\n%s", timeout, output.String())
+		}
+		var exitErr *sys.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("wasm module exited with code %d\n%s", exitErr.ExitCode(), output.String())
+		}
+		return fmt.Errorf("error running wasm module: %w\n%s", err, output.String())
+	}
+	return mod.Close(ctx)
+}
+
+// repoFS confines a directory mount to the repository. wazero's own directory
+// mounts allow escaping through `..` lookups and symlinks, so every path is
+// checked by the sandbox first. Creating links is not permitted.
+type repoFS struct {
+	experimentalsys.FS
+	sb *sandbox
+}
+
+// check returns the path relative to the mount, or EPERM if it leaves the
+// repository.
+func (f *repoFS) check(path string) (string, experimentalsys.Errno) {
+	rel := strings.TrimLeft(path, "/")
+	if rel == "" {
+		return ".", 0
+	}
+	if _, err := f.sb.resolve(rel); err != nil {
+		return "", experimentalsys.EPERM
+	}
+	return rel, 0
+}
+
+func (f *repoFS) OpenFile(path string, flag experimentalsys.Oflag, perm fs.FileMode) (experimentalsys.File, experimentalsys.Errno) {
+	rel, errno := f.check(path)
+	if errno != 0 {
+		return nil, errno
+	}
+	return f.FS.OpenFile(rel, flag, perm)
+}
+
+func (f *repoFS) Lstat(path string) (sys.Stat_t, experimentalsys.Errno) {
+	rel, errno := f.check(path)
+	if errno != 0 {
+		return sys.Stat_t{}, errno
+	}
+	return f.FS.Lstat(rel)
+}
+
+func (f *repoFS) Stat(path string) (sys.Stat_t, experimentalsys.Errno) {
+	rel, errno := f.check(path)
+	if errno != 0 {
+		return sys.Stat_t{}, errno
+	}
+	return f.FS.Stat(rel)
+}
+
+func (f *repoFS) Mkdir(path string, perm fs.FileMode) experimentalsys.Errno {
+	rel, errno := f.check(path)
+	if errno != 0 {
+		return errno
+	}
+	return f.FS.Mkdir(rel, perm)
+}
+
+func (f *repoFS) Chmod(path string, perm fs.FileMode) experimentalsys.Errno {
+	rel, errno := f.check(path)
+	if errno != 0 {
+		return errno
+	}
+	return f.FS.Chmod(rel, perm)
+}
+
+func (f *repoFS) Rename(from, to string) experimentalsys.Errno {
+	relFrom, errno := f.check(from)
+	if errno != 0 {
+		return errno
+	}
+	relTo, errno := f.check(to)
+	if errno != 0 {
+		return errno
+	}
+	return f.FS.Rename(relFrom, relTo)
+}
+
+func (f *repoFS) Rmdir(path string) experimentalsys.Errno {
+	rel, errno := f.check(path)
+	if errno != 0 {
+		return errno
+	}
+	return f.FS.Rmdir(rel)
+}
+
+func (f *repoFS) Unlink(path string) experimentalsys.Errno {
+	rel, errno := f.check(path)
+	if errno != 0 {
+		return errno
+	}
+	return f.FS.Unlink(rel)
+}
+
+func (f *repoFS) Link(_, _ string) experimentalsys.Errno {
+	return experimentalsys.EPERM
+}
+
+func (f *repoFS) Symlink(_, _ string) experimentalsys.Errno {
+	return experimentalsys.EPERM
+}
+
+func (f *repoFS) Readlink(path string) (string, experimentalsys.Errno) {
+	rel, errno := f.check(path)
+	if errno != 0 {
+		return "", errno
+	}
+	return f.FS.Readlink(rel)
+}
+
+func (f *repoFS) Utimens(path string, atim, mtim int64) experimentalsys.Errno {
+	rel, errno := f.check(path)
+	if errno != 0 {
+		return errno
+	}
+	return f.FS.Utimens(rel, atim, mtim)
+}
@@ -0,0 +1,181 @@
+package transform
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/experimental/sysfs"
+)
+
+const wasmTestProgram = `package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+func main() {
+	if os.Getenv("CASCADE_VAR_FAIL") != "" {
+		fmt.Println("failing on purpose")
+		os.Exit(2)
+	}
+	if os.Getenv("CASCADE_VAR_LOOP") != "" {
+		for {
+		}
+	}
+	data, err := os.ReadFile("/README.md")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile("/README.md", bytes.ToUpper(data), 0644); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile("/VERSION", []byte(os.Getenv("CASCADE_VAR_VERSION")), 0644); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if _, err := os.ReadFile("/link/secret"); err == nil {
+		fmt.Println("read a file outside the repository")
+		os.Exit(1)
+	}
+}
+`
+
+var (
+	buildWasmOnce sync.Once
+	wasmModule    string
+	wasmBuildErr  error
+)
+
+// buildTestWasm compiles the test program for wasip1 with the local Go
+// toolchain, skipping the test if that is not possible.
+func buildTestWasm(t *testing.T) string {
+	t.Helper()
+	buildWasmOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "cascade-wasm")
+		if err != nil {
+			wasmBuildErr = err
+			return
+		}
+		if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(wasmTestProgram), 0644); err != nil {
+			wasmBuildErr = err
+			return
+		}
+		wasmModule = filepath.Join(dir, "transform.wasm")
+		cmd := exec.Command("go", "build", "-o", wasmModule, "main.go")
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm", "GO111MODULE=off")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			wasmBuildErr = fmt.Errorf("%w\n%s", err, output)
+		}
+	})
+	if wasmBuildErr != nil {
+		t.Skipf("cannot build wasip1 test module: %v", wasmBuildErr)
+	}
+	return wasmModule
+}
+
+func TestRunWasm(t *testing.T) {
+	module := buildTestWasm(t)
+
+	outside := t.TempDir()
+	writeTestFile(t, outside, "secret", "secret\n")
+
+	repoPath := t.TempDir()
+	writeTestFile(t, repoPath, "README.md", "# test\n")
+	if err := os.Symlink(outside, filepath.Join(repoPath, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunWasm(repoPath, module, map[string]string{"version": "2.0.0"}, time.Minute); err != nil {
+		t.Fatalf("RunWasm failed: %v", err)
+	}
+	if got := readTestFile(t, repoPath, "README.md"); got != "# TEST\n" {
+		t.Errorf("README.md = %q, want %q", got, "# TEST\n")
+	}
+	if got := readTestFile(t, repoPath, "VERSION"); got != "2.0.0" {
+		t.Errorf("VERSION = %q, want %q", got, "2.0.0")
+	}
+
+	err := RunWasm(repoPath, module, map[string]string{"fail": "1"}, time.Minute)
+	if err == nil {
+		t.Fatal("expected error for failing module, got nil")
+	}
+	if !strings.Contains(err.Error(), "exited with code 2") || !strings.Contains(err.Error(), "failing on purpose") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRunWasmTimeout(t *testing.T) {
+	module := buildTestWasm(t)
+
+	err := RunWasm(t.TempDir(), module, map[string]string{"loop": "1"}, time.Second)
+	if err == nil {
+		t.Fatal("expected error for a looping module, got nil")
+	}
+	if !strings.Contains(err.Error(), "timed out after 1s") {
+		t.Errorf("expected timeout error, got: %v", err)
+	}
+}
+
+func TestRunWasmInvalidModule(t *testing.T) {
+	module := filepath.Join(t.TempDir(), "invalid.wasm")
+	if err := os.WriteFile(module, []byte("not wasm"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := RunWasm(t.TempDir(), module, nil, time.Minute)
+	if err == nil || !strings.Contains(err.Error(), "invalid wasm module") {
+		t.Errorf("expected invalid module error, got %v", err)
+	}
+}
+
+func TestRepoFS(t *testing.T) {
+	outside := t.TempDir()
+	writeTestFile(t, outside, "secret", "secret\n")
+
+	repoPath := t.TempDir()
+	writeTestFile(t, repoPath, "a.txt", "a\n")
+	if err := os.Symlink(outside, filepath.Join(repoPath, "link")); err != nil {
+		t.Fatal(err)
+	}
+	sb, err := newSandbox(repoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := &repoFS{FS: sysfs.DirFS(repoPath), sb: sb}
+
+	if _, errno := fsys.Stat("a.txt"); errno != 0 {
+		t.Errorf("Stat(a.txt) = %v, want success", errno)
+	}
+	if _, errno := fsys.Stat("/"); errno != 0 {
+		t.Errorf("Stat(/) = %v, want success", errno)
+	}
+
+	for _, path := range []string{"../escape", "sub/../../escape", "link/secret", ".git/config"} {
+		if f, errno := fsys.OpenFile(path, experimentalsys.O_RDWR|experimentalsys.O_CREAT, 0644); errno != experimentalsys.EPERM {
+			if f != nil {
+				f.Close()
+			}
+			t.Errorf("OpenFile(%q) = %v, want EPERM", path, errno)
+		}
+	}
+	if errno := fsys.Rename("a.txt", "../a.txt"); errno != experimentalsys.EPERM {
+		t.Errorf("Rename outside = %v, want EPERM", errno)
+	}
+	if errno := fsys.Symlink("/etc/passwd", "passwd"); errno != experimentalsys.EPERM {
+		t.Errorf("Symlink = %v, want EPERM", errno)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(repoPath), "escape")); !os.IsNotExist(err) {
+		t.Error("file was created outside the repository")
+	}
+}
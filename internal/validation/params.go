@@ -22,10 +22,18 @@ func ValidateFile(path string, fileType string) error {
 		return fmt.Errorf("%s file is a directory: %s", fileType, path)
 	}
 
-	if fileType == "script" && info.Mode()&0111 == 0 {
-		return fmt.Errorf("script file is not executable: %s", path)
-	}
+	return nil
+}
 
+// ValidateScript checks that the script exists and that cascade knows how to
+// run it, either directly or through an interpreter.
+func ValidateScript(path string, interpreter string) error {
+	if err := ValidateFile(path, "script"); err != nil {
+		return err
+	}
+	if _, err := git.ScriptCommand(path, interpreter); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -73,3 +81,19 @@ func ValidateBranchName(name string) error {
 
 	return nil
 }
+
+// ValidateRemoteName checks if the remote name is valid. Remote names follow
+// the rules of branch names, and cannot start with '-' to not be taken for
+// an option.
+func ValidateRemoteName(name string) error {
+	if name == "" {
+		return fmt.Errorf("remote name cannot be empty")
+	}
+	if strings.HasPrefix(name, "-") {
+		return fmt.Errorf("remote name cannot start with '-'")
+	}
+	if err := ValidateBranchName(name); err != nil {
+		return fmt.Errorf("invalid remote name: %w", err)
+	}
+	return nil
+}
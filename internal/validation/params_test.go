@@ -36,7 +36,6 @@ func TestValidateFile(t *testing.T) {
 		{"valid script file", executableFile, "script", false},
 		{"non-existent file", filepath.Join(tmpDir, "nonexistent"), "patch", true},
 		{"directory as file", tmpDir, "patch", true},
-		{"non-executable script", regularFile, "script", true},
 	}
 
 	for _, tt := range tests {
@@ -49,6 +48,51 @@ func TestValidateFile(t *testing.T) {
 	}
 }
 
+func TestValidateScript(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]struct {
+		content string
+		mode    os.FileMode
+	}{
+		"executable":   {"echo test", 0755},
+		"shebang":      {"#!/bin/sh\necho test", 0644},
+		"script.py":    {"print('test')", 0644},
+		"main.go":      {"package main", 0644},
+		"regular.txt":  {"test", 0644},
+		"no-extension": {"echo test", 0644},
+	}
+	for name, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(f.content), f.mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		file        string
+		interpreter string
+		wantErr     bool
+	}{
+		{"executable script", "executable", "", false},
+		{"shebang line", "shebang", "", false},
+		{"known extension", "script.py", "", false},
+		{"go file", "main.go", "", false},
+		{"explicit interpreter", "regular.txt", "bash", false},
+		{"unknown script", "regular.txt", "", true},
+		{"no shebang or extension", "no-extension", "", true},
+		{"missing script", "missing.sh", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateScript(filepath.Join(dir, tt.file), tt.interpreter)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateScript() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestValidateGitRepo(t *testing.T) {
 	// Create a temporary directory for test repos
 	tmpDir, err := os.MkdirTemp("", "cascade-test-*")
@@ -126,6 +170,30 @@ func TestValidateBranchName(t *testing.T) {
 	}
 }
 
+func TestValidateRemoteName(t *testing.T) {
+	tests := []struct {
+		name    string
+		remote  string
+		wantErr bool
+	}{
+		{"valid remote name", "origin", false},
+		{"valid with hyphens", "my-fork", false},
+		{"empty", "", true},
+		{"starts with hyphen", "-fork", true},
+		{"contains space", "my fork", true},
+		{"contains colon", "git@host:fork", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRemoteName(tt.remote)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRemoteName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 // Helper function to initialize a git repository
 func runGitInit(dir string) error {
 	cmd := exec.Command("git", "init")
@@ -1,10 +1,16 @@
 package tests
 
 import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
 
@@ -18,6 +24,7 @@ func TestIntegrationApply(t *testing.T) {
 
 	// Create test directory
 	testDir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
 
 	// Create test repositories
 	repo1Path := filepath.Join(testDir, "repo1")
@@ -28,6 +35,14 @@ func TestIntegrationApply(t *testing.T) {
 	// Reset flags before each test
 	resetFlags := func() {
 		cmd.ResetFlags()
+		cmd.ResetRemoteFlags()
+		cmd.ResetRetryFlags()
+		cmd.ResetStatusFlags()
+		cmd.ResetPRsFlags()
+		cmd.ResetMergeFlags()
+		cmd.ResetCleanupFlags()
+		cmd.ResetSyncFlags()
+		cmd.ResetRunsFlags()
 	}
 
 	t.Run("apply patch to multiple repositories", func(t *testing.T) {
@@ -175,6 +190,310 @@ echo "modified content" > modified.txt
 		}
 	})
 
+	t.Run("pass environment and arguments to commands", func(t *testing.T) {
+		resetFlags()
+		command := `printf "%s %s %s %s" "$CASCADE_REPO_NAME" "$CASCADE_BRANCH" "$CASCADE_VAR_TICKET" "$1" > env.txt`
+
+		os.Args = []string{
+			"cascade",
+			"apply",
+			"--command", command,
+			"--script-arg", "{{.RepoName}}-arg",
+			"--var", "ticket=OPS-1",
+			"--branch", "feature/test-env",
+			"--message", "Add env.txt",
+			repo1Path,
+			repo2Path,
+		}
+
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+
+		for _, repoPath := range []string{repo1Path, repo2Path} {
+			content, err := os.ReadFile(filepath.Join(repoPath, "env.txt"))
+			if err != nil {
+				t.Fatalf("env.txt not found in %s", repoPath)
+			}
+			name := filepath.Base(repoPath)
+			if want := name + " feature/test-env OPS-1 " + name + "-arg"; string(content) != want {
+				t.Errorf("Expected content %q, got %q in %s", want, string(content), repoPath)
+			}
+		}
+	})
+
+	t.Run("write transcripts to the run log directory", func(t *testing.T) {
+		resetFlags()
+		logDir := filepath.Join(testDir, "logs")
+
+		os.Args = []string{
+			"cascade",
+			"apply",
+			"--command", "echo generated > log.txt",
+			"--branch", "feature/test-log-dir",
+			"--message", "Add log.txt",
+			"--log-dir", logDir,
+			repo1Path,
+			repo2Path,
+		}
+
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+
+		entries, err := os.ReadDir(logDir)
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("Expected a single run directory in %s, got %v (%v)", logDir, entries, err)
+		}
+		runPath := filepath.Join(logDir, entries[0].Name())
+
+		index, err := os.ReadFile(filepath.Join(runPath, "index.txt"))
+		if err != nil {
+			t.Fatalf("index.txt not found: %v", err)
+		}
+		for _, want := range []string{"repositories: 2, failed: 0", "ok   " + repo1Path + " -> 01-repo1.log"} {
+			if !strings.Contains(string(index), want) {
+				t.Errorf("index.txt does not contain %q:\n%s", want, index)
+			}
+		}
+
+		transcript, err := os.ReadFile(filepath.Join(runPath, "02-repo2.log"))
+		if err != nil {
+			t.Fatalf("transcript not found: %v", err)
+		}
+		for _, want := range []string{"$ git checkout -B feature/test-log-dir", "$ git commit -m Add log.txt", "exit code: 0", "result: ok"} {
+			if !strings.Contains(string(transcript), want) {
+				t.Errorf("transcript does not contain %q:\n%s", want, transcript)
+			}
+		}
+	})
+
+	t.Run("write JSON log events with git commands", func(t *testing.T) {
+		resetFlags()
+
+		os.Args = []string{
+			"cascade",
+			"apply",
+			"--command", "echo json > json.txt",
+			"--branch", "feature/test-json-log",
+			"--message", "Add json.txt",
+			"--log-format", "json",
+			repo1Path,
+		}
+
+		stdout := captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+		_, logPath, found := strings.Cut(stdout, "Output log: ")
+		if !found {
+			t.Fatalf("Expected log path in output:\n%s", stdout)
+		}
+		logPath = strings.TrimSpace(logPath)
+		defer os.Remove(logPath)
+
+		data, err := os.ReadFile(logPath)
+		if err != nil {
+			t.Fatalf("Failed to read log: %v", err)
+		}
+		var commands []string
+		var results []string
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var event struct {
+				Event   string
+				Status  string
+				Command *struct{ Args []string }
+			}
+			if err := json.Unmarshal([]byte(line), &event); err != nil {
+				t.Fatalf("Invalid JSON event %q: %v", line, err)
+			}
+			if event.Command != nil {
+				commands = append(commands, strings.Join(event.Command.Args, " "))
+			}
+			if event.Event == "result" {
+				results = append(results, event.Status)
+			}
+		}
+		if !slices.Contains(commands, "git checkout -B feature/test-json-log") || !slices.Contains(commands, "git commit -m Add json.txt") {
+			t.Errorf("Expected git commands in the log, got %q", commands)
+		}
+		if strings.Join(results, " ") != "ok" {
+			t.Errorf("Expected a single ok result, got %q", results)
+		}
+	})
+
+	t.Run("set structured keys in multiple repositories", func(t *testing.T) {
+		resetFlags()
+		for _, repoPath := range []string{repo1Path, repo2Path} {
+			values := "# Chart values\nimage:\n  tag: \"1.0.0\" # pinned\n"
+			if err := os.WriteFile(filepath.Join(repoPath, "values.yaml"), []byte(values), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		os.Args = []string{
+			"cascade",
+			"apply",
+			"--set", "values.yaml:image.tag=1.2.3",
+			"--branch", "feature/test-set",
+			"--message", "Bump image tag",
+			repo1Path,
+			repo2Path,
+		}
+
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+
+		for _, repoPath := range []string{repo1Path, repo2Path} {
+			content, err := os.ReadFile(filepath.Join(repoPath, "values.yaml"))
+			if err != nil {
+				t.Fatalf("values.yaml not found in %s", repoPath)
+			}
+			if want := "# Chart values\nimage:\n  tag: \"1.2.3\" # pinned\n"; string(content) != want {
+				t.Errorf("Expected values.yaml content %q, got %q in %s", want, string(content), repoPath)
+			}
+			if msg := getLastCommitMessage(t, repoPath); msg != "Bump image tag" {
+				t.Errorf("Expected commit message 'Bump image tag', got '%s' in %s", msg, repoPath)
+			}
+		}
+	})
+
+	t.Run("apply starlark script to multiple repositories", func(t *testing.T) {
+		resetFlags()
+		scriptPath := filepath.Join(testDir, "transform.star")
+		script := `for path in repo.glob("**/*.md"):
+    repo.sub(path, "Test Repository", "Transformed Repository")
+repo.write("VERSION", vars["version"] + "\n")
+`
+		if err := os.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		os.Args = []string{
+			"cascade",
+			"apply",
+			"--starlark", scriptPath,
+			"--var", "version=2.0.0",
+			"--branch", "feature/test-starlark",
+			"--message", "Apply starlark transform",
+			repo1Path,
+			repo2Path,
+		}
+
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+
+		for _, repoPath := range []string{repo1Path, repo2Path} {
+			content, err := os.ReadFile(filepath.Join(repoPath, "VERSION"))
+			if err != nil {
+				t.Fatalf("VERSION not found in %s", repoPath)
+			}
+			if string(content) != "2.0.0\n" {
+				t.Errorf("Expected VERSION content '2.0.0', got %q in %s", string(content), repoPath)
+			}
+			readme, err := os.ReadFile(filepath.Join(repoPath, "README.md"))
+			if err != nil {
+				t.Fatalf("README.md not found in %s", repoPath)
+			}
+			if !strings.Contains(string(readme), "Transformed Repository") {
+				t.Errorf("Expected README.md to be transformed, got %q in %s", string(readme), repoPath)
+			}
+			if msg := getLastCommitMessage(t, repoPath); msg != "Apply starlark transform" {
+				t.Errorf("Expected commit message 'Apply starlark transform', got '%s' in %s", msg, repoPath)
+			}
+		}
+	})
+
+	t.Run("apply recipe with multiple steps", func(t *testing.T) {
+		resetFlags()
+		recipeDir := filepath.Join(testDir, "recipe")
+		if err := os.MkdirAll(recipeDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		recipeContent := `branch: feature/test-recipe
+message: Finish recipe
+steps:
+  - command: printf "first" > first.txt
+    commit: Add first.txt
+  - replace:
+      files: ["first.txt"]
+      pattern: first
+      with: second
+  - verify: grep -q second first.txt
+`
+		recipeFile := filepath.Join(recipeDir, "recipe.yaml")
+		if err := os.WriteFile(recipeFile, []byte(recipeContent), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		os.Args = []string{
+			"cascade",
+			"apply",
+			"--recipe", recipeFile,
+			repo1Path,
+			repo2Path,
+		}
+
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+
+		for _, repoPath := range []string{repo1Path, repo2Path} {
+			if branch := getCurrentBranch(t, repoPath); branch != "feature/test-recipe" {
+				t.Errorf("Expected branch feature/test-recipe, got %s in %s", branch, repoPath)
+			}
+			content, err := os.ReadFile(filepath.Join(repoPath, "first.txt"))
+			if err != nil {
+				t.Errorf("first.txt not found in %s", repoPath)
+			} else if string(content) != "second" {
+				t.Errorf("Expected content 'second', got '%s' in %s", string(content), repoPath)
+			}
+			if msg := getLastCommitMessage(t, repoPath); msg != "Finish recipe" {
+				t.Errorf("Expected commit message 'Finish recipe', got '%s' in %s", msg, repoPath)
+			}
+			cmd := exec.Command("git", "log", "-2", "--pretty=%s")
+			cmd.Dir = repoPath
+			output, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("git log failed: %v\n%s", err, output)
+			}
+			if !strings.Contains(string(output), "Add first.txt") {
+				t.Errorf("Expected step commit 'Add first.txt' in %s, got:\n%s", repoPath, output)
+			}
+		}
+	})
+
+	t.Run("render branch and message templates per repository", func(t *testing.T) {
+		resetFlags()
+		os.Args = []string{
+			"cascade",
+			"apply",
+			"--command", `printf "templated" > templated.txt`,
+			"--branch", "tpl/{{.RepoName}}-{{.Vars.ticket}}",
+			"--message", "chore({{.RepoName}}): apply {{.Vars.ticket}}",
+			"--var", "ticket=OPS-7",
+			repo1Path,
+			repo2Path,
+		}
+
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+
+		for _, repoPath := range []string{repo1Path, repo2Path} {
+			name := filepath.Base(repoPath)
+			if branch := getCurrentBranch(t, repoPath); branch != "tpl/"+name+"-OPS-7" {
+				t.Errorf("Expected branch tpl/%s-OPS-7, got %s in %s", name, branch, repoPath)
+			}
+			if msg := getLastCommitMessage(t, repoPath); msg != "chore("+name+"): apply OPS-7" {
+				t.Errorf("Expected rendered commit message, got '%s' in %s", msg, repoPath)
+			}
+		}
+	})
+
 	t.Run("apply changes to specific base branch", func(t *testing.T) {
 		resetFlags()
 
@@ -445,6 +764,8 @@ index 0000000..9daeafb
 			t.Fatal(err)
 		}
 
+		// The default opener is used when no browser is configured
+		t.Setenv("BROWSER", "")
 		oldPath := os.Getenv("PATH")
 		if err := os.Setenv("PATH", openDir+string(os.PathListSeparator)+oldPath); err != nil {
 			t.Fatal(err)
@@ -470,12 +791,82 @@ index 0000000..9daeafb
 			t.Fatalf("Execute failed: %v", err)
 		}
 
+		// The opener gets the summary page, which links the pull request
 		content, err := os.ReadFile(markerPath)
 		if err != nil {
 			t.Fatalf("expected browser opener to be called: %v", err)
 		}
-		if strings.TrimSpace(string(content)) != "https://example.com/pull/1" {
-			t.Errorf("expected opener to receive URL, got %q", strings.TrimSpace(string(content)))
+		pageURL := strings.TrimSpace(string(content))
+		if !strings.HasPrefix(pageURL, "file://") || !strings.HasSuffix(pageURL, "/summary.html") {
+			t.Fatalf("expected opener to receive the summary page, got %q", pageURL)
+		}
+		page, err := os.ReadFile(strings.TrimPrefix(pageURL, "file://"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(page), `<a href="https://example.com/pull/1">`) {
+			t.Errorf("expected the summary to link the pull request, got:\n%s", page)
+		}
+		if !strings.Contains(string(page), "1 file changed, 1 insertion(&#43;)") {
+			t.Errorf("expected the summary to show the diffstat, got:\n%s", page)
+		}
+	})
+
+	t.Run("open the summary with a browser command", func(t *testing.T) {
+		resetFlags()
+		browserRepo := filepath.Join(testDir, "browser-repo")
+		browserRemote := filepath.Join(testDir, "ssh", "group", "browser-repo.git")
+		createTestRepo(t, browserRepo)
+		if err := os.MkdirAll(browserRemote, 0755); err != nil {
+			t.Fatal(err)
+		}
+		runGitCmd(t, browserRemote, "init", "--bare", "-b", "main")
+		// origin looks like GitLab, so the merge request URL is built from it
+		runGitCmd(t, browserRepo, "remote", "add", "origin", "git@gitlab.com:group/browser-repo.git")
+		serveOverSSH(t, filepath.Join(testDir, "ssh"))
+
+		markerPath := filepath.Join(testDir, "browser-called")
+		browserPath := filepath.Join(testDir, "browser.sh")
+		if err := os.WriteFile(browserPath, []byte("#!/bin/sh\necho \"$@\" > \""+markerPath+"\"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		logDir := filepath.Join(testDir, "browser-logs")
+
+		os.Args = []string{
+			"cascade",
+			"apply",
+			"--command", "echo browser > browser.txt",
+			"--branch", "feature/test-browser",
+			"--message", "Add browser.txt",
+			"--push",
+			"--open-remote-url",
+			"--browser", browserPath + " --new-window",
+			"--log-dir", logDir,
+			browserRepo,
+		}
+		stdout := captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+
+		content, err := os.ReadFile(markerPath)
+		if err != nil {
+			t.Fatalf("expected the browser command to be called: %v", err)
+		}
+		args := strings.Fields(string(content))
+		if len(args) != 2 || args[0] != "--new-window" || !strings.HasPrefix(args[1], "file://"+logDir) {
+			t.Fatalf("expected the browser to open the summary in the run log, got %q", content)
+		}
+		if !strings.Contains(stdout, "Summary: "+strings.TrimPrefix(args[1], "file://")) {
+			t.Errorf("expected the summary path in the output, got:\n%s", stdout)
+		}
+		markdown, err := os.ReadFile(filepath.Join(filepath.Dir(strings.TrimPrefix(args[1], "file://")), "summary.md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(markdown), "https://gitlab.com/group/browser-repo/-/merge_requests/new?") {
+			t.Errorf("expected the merge request link in the Markdown summary, got:\n%s", markdown)
 		}
 	})
 
@@ -569,6 +960,149 @@ index 0000000..9daeafb
 		}
 	})
 
+	t.Run("update an existing campaign branch", func(t *testing.T) {
+		resetFlags()
+		updateRepo := filepath.Join(testDir, "update-repo")
+		remoteRepo := filepath.Join(testDir, "update-remote.git")
+		createTestRepo(t, updateRepo)
+		if err := os.MkdirAll(remoteRepo, 0755); err != nil {
+			t.Fatal(err)
+		}
+		runGitCmd(t, remoteRepo, "init", "--bare", "-b", "main")
+		runGitCmd(t, updateRepo, "remote", "add", "origin", remoteRepo)
+		runGitCmd(t, updateRepo, "push", "-u", "origin", "main")
+
+		apply := func(update string, content string, message string) {
+			t.Helper()
+			resetFlags()
+			os.Args = []string{
+				"cascade",
+				"apply",
+				"--command", "printf '" + content + "' > update.txt",
+				"--branch", "feature/test-update",
+				"--base-branch", "main",
+				"--message", message,
+				"--push",
+			}
+			if update != "" {
+				os.Args = append(os.Args, "--update", update)
+			}
+			os.Args = append(os.Args, updateRepo)
+			captureStdout(t, func() {
+				if err := cmd.Execute(); err != nil {
+					t.Fatalf("Execute failed: %v", err)
+				}
+			})
+		}
+		remoteLog := func() string {
+			return runGitOutput(t, remoteRepo, "log", "--format=%s", "main..feature/test-update")
+		}
+
+		apply("", "first", "Add update.txt")
+		apply("commit", "second", "Follow up on update.txt")
+		if log := remoteLog(); log != "Follow up on update.txt\nAdd update.txt\n" {
+			t.Errorf("Expected a follow-up commit on the pushed branch, got:\n%s", log)
+		}
+
+		apply("amend", "third", "Update update.txt")
+		if log := remoteLog(); log != "Update update.txt\nAdd update.txt\n" {
+			t.Errorf("Expected the last commit to be amended and force-pushed, got:\n%s", log)
+		}
+
+		apply("regenerate", "fourth", "Regenerate update.txt")
+		if log := remoteLog(); log != "Regenerate update.txt\n" {
+			t.Errorf("Expected the branch to be regenerated from main, got:\n%s", log)
+		}
+		if content := runGitOutput(t, remoteRepo, "show", "feature/test-update:update.txt"); content != "fourth" {
+			t.Errorf("Expected the regenerated content, got %q", content)
+		}
+	})
+
+	t.Run("protect existing campaign branches", func(t *testing.T) {
+		resetFlags()
+		existingRepo := filepath.Join(testDir, "existing-repo")
+		createTestRepo(t, existingRepo)
+		runGitCmd(t, existingRepo, "checkout", "-b", "feature/test-existing")
+		if err := os.WriteFile(filepath.Join(existingRepo, "work.txt"), []byte("work"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		runGitCmd(t, existingRepo, "add", ".")
+		runGitCmd(t, existingRepo, "commit", "-m", "Work in progress")
+		runGitCmd(t, existingRepo, "checkout", "main")
+		head := runGitOutput(t, existingRepo, "rev-parse", "feature/test-existing")
+
+		apply := func(policy string) string {
+			t.Helper()
+			resetFlags()
+			os.Args = []string{
+				"cascade",
+				"apply",
+				"--command", "echo new > new.txt",
+				"--branch", "feature/test-existing",
+				"--message", "Add new.txt",
+			}
+			if policy != "" {
+				os.Args = append(os.Args, "--on-existing", policy)
+			}
+			os.Args = append(os.Args, existingRepo)
+			return captureStdout(t, func() {
+				if err := cmd.Execute(); err != nil {
+					t.Fatalf("Execute failed: %v", err)
+				}
+			})
+		}
+
+		if stdout := apply(""); !strings.Contains(stdout, "fail "+existingRepo) {
+			t.Errorf("Expected the existing branch to fail the repository, got:\n%s", stdout)
+		}
+		if stdout := apply("skip"); !strings.Contains(stdout, "ok   "+existingRepo+" (skipped existing branch)") {
+			t.Errorf("Expected the existing branch to be skipped, got:\n%s", stdout)
+		}
+		if got := runGitOutput(t, existingRepo, "rev-parse", "feature/test-existing"); got != head {
+			t.Errorf("Expected the existing branch to be kept at %s, got %s", head, got)
+		}
+
+		if stdout := apply("reset"); !strings.Contains(stdout, "(reset existing branch)") {
+			t.Errorf("Expected the existing branch to be reset, got:\n%s", stdout)
+		}
+		if log := runGitOutput(t, existingRepo, "log", "--format=%s", "main..feature/test-existing"); log != "Add new.txt\n" {
+			t.Errorf("Expected the branch to be recreated from main, got:\n%s", log)
+		}
+	})
+
+	t.Run("check branches pushed since the last fetch", func(t *testing.T) {
+		resetFlags()
+		originRepo := filepath.Join(testDir, "stale-origin")
+		createTestRepo(t, originRepo)
+		staleRepo := filepath.Join(testDir, "stale-clone")
+		runGitCmd(t, testDir, "clone", originRepo, staleRepo)
+		runGitCmd(t, staleRepo, "config", "user.email", "test@example.com")
+		runGitCmd(t, staleRepo, "config", "user.name", "Test User")
+
+		// Someone pushes the campaign branch after the clone was fetched
+		runGitCmd(t, originRepo, "branch", "feature/test-stale")
+
+		os.Args = []string{
+			"cascade",
+			"apply",
+			"--command", "echo new > new.txt",
+			"--branch", "feature/test-stale",
+			"--message", "Add new.txt",
+			staleRepo,
+		}
+		stdout := captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+		if !strings.Contains(stdout, "fail "+staleRepo) {
+			t.Errorf("Expected the branch on origin to fail the repository, got:\n%s", stdout)
+		}
+		if got := runGitOutput(t, staleRepo, "branch", "--list", "feature/test-stale"); got != "" {
+			t.Errorf("Expected no campaign branch to be created, got %q", got)
+		}
+	})
+
 	t.Run("fail on invalid repository", func(t *testing.T) {
 		resetFlags()
 		invalidRepo := filepath.Join(testDir, "not-a-repo")
@@ -592,26 +1126,762 @@ index 0000000..9daeafb
 		}
 	})
 
-	t.Run("fail on non-executable script", func(t *testing.T) {
+	t.Run("run non-executable script through its shebang line", func(t *testing.T) {
 		resetFlags()
-		nonExecutableScript := filepath.Join(testDir, "non-executable.sh")
-		if err := os.WriteFile(nonExecutableScript, []byte("#!/bin/sh\necho test"), 0644); err != nil {
+		shebangScript := filepath.Join(testDir, "non-executable.sh")
+		if err := os.WriteFile(shebangScript, []byte("#!/bin/sh\nprintf \"shebang content\" > shebang.txt"), 0644); err != nil {
 			t.Fatal(err)
 		}
 
-		// Set up command line arguments
 		os.Args = []string{
 			"cascade",
 			"apply",
-			"--script", nonExecutableScript,
-			"--branch", "feature/test",
-			"--message", "Test",
+			"--script", shebangScript,
+			"--branch", "feature/test-shebang",
+			"--message", "Add shebang.txt",
 			repo1Path,
 		}
 
-		// Run the command and expect error
-		if err := cmd.Execute(); err == nil {
-			t.Error("Expected error when using non-executable script")
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+
+		content, err := os.ReadFile(filepath.Join(repo1Path, "shebang.txt"))
+		if err != nil {
+			t.Fatalf("shebang.txt not found in %s", repo1Path)
+		}
+		if string(content) != "shebang content" {
+			t.Errorf("Expected content 'shebang content', got '%s'", string(content))
+		}
+	})
+
+	t.Run("fail on script without a known interpreter", func(t *testing.T) {
+		resetFlags()
+		nonExecutableScript := filepath.Join(testDir, "non-executable")
+		if err := os.WriteFile(nonExecutableScript, []byte("echo test"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		// Set up command line arguments
+		os.Args = []string{
+			"cascade",
+			"apply",
+			"--script", nonExecutableScript,
+			"--branch", "feature/test",
+			"--message", "Test",
+			repo1Path,
+		}
+
+		// Run the command and expect error
+		if err := cmd.Execute(); err == nil {
+			t.Error("Expected error when using a script without a known interpreter")
+		}
+	})
+
+	t.Run("list, show and rerun past runs", func(t *testing.T) {
+		resetFlags()
+		okRepo := filepath.Join(testDir, "runs-ok")
+		failRepo := filepath.Join(testDir, "runs-fail")
+		createTestRepo(t, okRepo)
+		createTestRepo(t, failRepo)
+
+		os.Args = []string{
+			"cascade",
+			"apply",
+			"--command", `echo run >> runs.txt && test "$CASCADE_REPO_NAME" = runs-ok`,
+			"--branch", "feature/test-runs",
+			"--message", "Add runs.txt",
+			// The failed run leaves its branch behind for the rerun to reset
+			"--on-existing", "reset",
+			okRepo,
+			failRepo,
+		}
+		captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+
+		resetFlags()
+		os.Args = []string{"cascade", "runs", "list"}
+		list := captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("runs list failed: %v", err)
+			}
+		})
+		lines := strings.Split(strings.TrimSpace(list), "\n")
+		if len(lines) < 2 || !strings.HasPrefix(lines[0], "ID") {
+			t.Fatalf("Unexpected runs list:\n%s", list)
+		}
+		fields := strings.Fields(lines[1])
+		runID := fields[0]
+		if fields[len(fields)-3] != "feature/test-runs" || fields[len(fields)-2] != "2" || fields[len(fields)-1] != "1" {
+			t.Errorf("Unexpected latest run: %s", lines[1])
+		}
+
+		resetFlags()
+		os.Args = []string{"cascade", "runs", "show", runID}
+		show := captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("runs show failed: %v", err)
+			}
+		})
+		commit := strings.TrimSpace(runGitOutput(t, okRepo, "rev-parse", "HEAD"))
+		for _, want := range []string{"Run:       " + runID, "ok   " + okRepo, "commit: " + commit, "fail " + failRepo, "error:  command execution failed"} {
+			if !strings.Contains(show, want) {
+				t.Errorf("runs show output does not contain %q:\n%s", want, show)
+			}
+		}
+
+		resetFlags()
+		os.Args = []string{"cascade", "runs", "rerun", runID[:len(runID)-2], "--failed"}
+		captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("runs rerun failed: %v", err)
+			}
+		})
+
+		for repoPath, want := range map[string]string{okRepo: "run\n", failRepo: "run\nrun\n"} {
+			content, err := os.ReadFile(filepath.Join(repoPath, "runs.txt"))
+			if err != nil {
+				t.Fatalf("runs.txt not found in %s", repoPath)
+			}
+			if string(content) != want {
+				t.Errorf("Expected %q in %s, got %q", want, repoPath, string(content))
+			}
+		}
+	})
+
+	t.Run("report campaign branch status", func(t *testing.T) {
+		resetFlags()
+		base := getCurrentBranch(t, repo1Path)
+		runGitCmd(t, repo1Path, "checkout", "-B", "feature/test-status")
+		if err := os.WriteFile(filepath.Join(repo1Path, "status.txt"), []byte("status"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		runGitCmd(t, repo1Path, "add", ".")
+		runGitCmd(t, repo1Path, "commit", "-m", "Add status.txt")
+		runGitCmd(t, repo1Path, "checkout", base)
+
+		os.Args = []string{
+			"cascade",
+			"status",
+			"--branch", "feature/test-status",
+			"--base-branch", base,
+			repo1Path,
+			repo2Path,
+		}
+		stdout := captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+
+		lines := strings.Split(strings.TrimSpace(stdout), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("Expected a header and 2 rows, got:\n%s", stdout)
+		}
+		repo1Fields := strings.Fields(lines[1])
+		if want := []string{repo1Path, "feature/test-status", "yes", "no", "1", "0", "no", "no"}; strings.Join(repo1Fields[:8], " ") != strings.Join(want, " ") {
+			t.Errorf("Unexpected status row %q", lines[1])
+		}
+		if !strings.Contains(lines[1], "Add status.txt") {
+			t.Errorf("Expected last commit in status row %q", lines[1])
+		}
+		if repo2Fields := strings.Fields(lines[2]); strings.Join(repo2Fields[2:], " ") != "no no - - no no -" {
+			t.Errorf("Unexpected status row %q", lines[2])
+		}
+	})
+
+	t.Run("report pull requests from a code host API", func(t *testing.T) {
+		resetFlags()
+		prRepo := filepath.Join(testDir, "prs-repo")
+		createTestRepo(t, prRepo)
+		runGitCmd(t, prRepo, "remote", "add", "origin", "git@github.com:org/prs-repo.git")
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			responses := map[string]string{
+				"/repos/org/prs-repo/pulls":                   `[{"number": 5}]`,
+				"/repos/org/prs-repo/pulls/5":                 `{"number": 5, "html_url": "https://github.com/org/prs-repo/pull/5", "state": "closed", "merged": true, "head": {"sha": "sha5"}}`,
+				"/repos/org/prs-repo/pulls/5/reviews":         `[]`,
+				"/repos/org/prs-repo/commits/sha5/check-runs": `{"check_runs": []}`,
+				"/repos/org/prs-repo/commits/sha5/status":     `{"statuses": [{"context": "ci", "state": "failure"}]}`,
+			}
+			response, ok := responses[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write([]byte(response))
+		}))
+		defer server.Close()
+
+		os.Args = []string{
+			"cascade",
+			"prs",
+			"--branch", "feature/test-prs",
+			"--api-url", server.URL,
+			"--json",
+			prRepo,
+		}
+		stdout := captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+
+		var results []struct {
+			Repo        string
+			PullRequest *struct {
+				Number       int
+				State        string
+				Checks       string
+				FailedChecks []string `json:"failed_checks"`
+			} `json:"pull_request"`
+		}
+		if err := json.Unmarshal([]byte(stdout), &results); err != nil {
+			t.Fatalf("Invalid JSON output: %v\n%s", err, stdout)
+		}
+		if len(results) != 1 || results[0].PullRequest == nil {
+			t.Fatalf("Expected a pull request, got:\n%s", stdout)
+		}
+		pr := results[0].PullRequest
+		if pr.Number != 5 || pr.State != "merged" || pr.Checks != "failure" || strings.Join(pr.FailedChecks, ",") != "ci" {
+			t.Errorf("Unexpected pull request %+v", *pr)
+		}
+	})
+
+	t.Run("merge ready pull requests through a code host API", func(t *testing.T) {
+		resetFlags()
+		mergeRepo := filepath.Join(testDir, "merge-repo")
+		createTestRepo(t, mergeRepo)
+		runGitCmd(t, mergeRepo, "remote", "add", "origin", "git@github.com:org/merge-repo.git")
+
+		var mergeRequest string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPut && r.URL.Path == "/repos/org/merge-repo/pulls/7/merge" {
+				body, _ := io.ReadAll(r.Body)
+				mergeRequest = string(body)
+				_, _ = w.Write([]byte(`{"merged": true}`))
+				return
+			}
+			responses := map[string]string{
+				"/repos/org/merge-repo/pulls":                   `[{"number": 7}]`,
+				"/repos/org/merge-repo/pulls/7":                 `{"number": 7, "state": "open", "mergeable": true, "mergeable_state": "clean", "head": {"sha": "sha7"}}`,
+				"/repos/org/merge-repo/pulls/7/reviews":         `[{"user": {"login": "alice"}, "state": "APPROVED"}]`,
+				"/repos/org/merge-repo/commits/sha7/check-runs": `{"check_runs": [{"name": "test", "status": "completed", "conclusion": "success"}]}`,
+				"/repos/org/merge-repo/commits/sha7/status":     `{"statuses": []}`,
+			}
+			response, ok := responses[r.URL.Path]
+			if !ok || r.Method != http.MethodGet {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write([]byte(response))
+		}))
+		defer server.Close()
+
+		os.Args = []string{
+			"cascade",
+			"merge",
+			"--branch", "feature/test-merge",
+			"--method", "squash",
+			"--api-url", server.URL,
+			mergeRepo,
+		}
+		stdout := captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+
+		if !strings.Contains(stdout, "#7") || !strings.Contains(stdout, "merged") {
+			t.Errorf("Expected the pull request to be merged, got:\n%s", stdout)
+		}
+		if mergeRequest != `{"merge_method":"squash","sha":"sha7"}` {
+			t.Errorf("Unexpected merge request %q", mergeRequest)
+		}
+	})
+
+	t.Run("clean up merged campaign branches", func(t *testing.T) {
+		resetFlags()
+		cleanupRepo := filepath.Join(testDir, "cleanup-repo")
+		remoteRepo := filepath.Join(testDir, "cleanup-remote.git")
+		createTestRepo(t, cleanupRepo)
+		if err := os.MkdirAll(remoteRepo, 0755); err != nil {
+			t.Fatal(err)
+		}
+		runGitCmd(t, remoteRepo, "init", "--bare", "-b", "main")
+		runGitCmd(t, cleanupRepo, "remote", "add", "origin", remoteRepo)
+		runGitCmd(t, cleanupRepo, "push", "origin", "main")
+
+		// The campaign branch is pushed, merged into main and still checked out
+		runGitCmd(t, cleanupRepo, "checkout", "-b", "feature/test-cleanup")
+		if err := os.WriteFile(filepath.Join(cleanupRepo, "cleanup.txt"), []byte("cleanup"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		runGitCmd(t, cleanupRepo, "add", ".")
+		runGitCmd(t, cleanupRepo, "commit", "-m", "Add cleanup.txt")
+		runGitCmd(t, cleanupRepo, "push", "origin", "feature/test-cleanup")
+		runGitCmd(t, cleanupRepo, "push", "origin", "feature/test-cleanup:main")
+
+		os.Args = []string{
+			"cascade",
+			"cleanup",
+			"--branch", "feature/test-cleanup",
+			cleanupRepo,
+		}
+		stdout := captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+
+		if !strings.Contains(stdout, "deleted") || !strings.Contains(stdout, "merged into main") {
+			t.Errorf("Expected the branch to be deleted, got:\n%s", stdout)
+		}
+		if branch := getCurrentBranch(t, cleanupRepo); branch != "main" {
+			t.Errorf("Expected main to be checked out, got %q", branch)
+		}
+		if branches := runGitOutput(t, cleanupRepo, "branch", "--list", "feature/test-cleanup"); branches != "" {
+			t.Errorf("Expected the local branch to be deleted, got %q", branches)
+		}
+		if branches := runGitOutput(t, remoteRepo, "branch", "--list", "feature/test-cleanup"); branches != "" {
+			t.Errorf("Expected the remote branch to be deleted, got %q", branches)
+		}
+
+		// The local branch is merged, but the remote one has a commit that
+		// is not, which must survive the cleanup
+		runGitCmd(t, cleanupRepo, "checkout", "-b", "feature/test-review")
+		if err := os.WriteFile(filepath.Join(cleanupRepo, "review.txt"), []byte("review"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		runGitCmd(t, cleanupRepo, "add", ".")
+		runGitCmd(t, cleanupRepo, "commit", "-m", "Review fix")
+		runGitCmd(t, cleanupRepo, "push", "origin", "feature/test-review")
+		reviewCommit := strings.TrimSpace(runGitOutput(t, cleanupRepo, "rev-parse", "HEAD"))
+		runGitCmd(t, cleanupRepo, "checkout", "main")
+		runGitCmd(t, cleanupRepo, "branch", "-f", "feature/test-review", "main")
+
+		resetFlags()
+		os.Args = []string{"cascade", "cleanup", "--branch", "feature/test-review", cleanupRepo}
+		stdout = captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+		if head := strings.TrimSpace(runGitOutput(t, remoteRepo, "rev-parse", "feature/test-review")); head != reviewCommit {
+			t.Errorf("Expected the unmerged remote branch to be kept at %s, got %q\n%s", reviewCommit, head, stdout)
+		}
+	})
+
+	t.Run("rebase campaign branches onto the latest base branch", func(t *testing.T) {
+		resetFlags()
+		// Both repositories get a new commit on main after the campaign
+		// branch was pushed, the second one in the file the branch changes
+		var syncRepos []string
+		for i, name := range []string{"sync-clean", "sync-conflict"} {
+			repoPath := filepath.Join(testDir, name)
+			remoteRepo := filepath.Join(testDir, name+".git")
+			createTestRepo(t, repoPath)
+			if err := os.MkdirAll(remoteRepo, 0755); err != nil {
+				t.Fatal(err)
+			}
+			runGitCmd(t, remoteRepo, "init", "--bare", "-b", "main")
+			runGitCmd(t, repoPath, "remote", "add", "origin", remoteRepo)
+			runGitCmd(t, repoPath, "push", "-u", "origin", "main")
+
+			runGitCmd(t, repoPath, "checkout", "-b", "feature/test-sync")
+			if err := os.WriteFile(filepath.Join(repoPath, "README.md"), []byte("# Campaign"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			runGitCmd(t, repoPath, "commit", "-am", "Update README")
+			runGitCmd(t, repoPath, "push", "-u", "origin", "feature/test-sync")
+
+			runGitCmd(t, repoPath, "checkout", "main")
+			baseFile := "base.txt"
+			if i == 1 {
+				baseFile = "README.md"
+			}
+			if err := os.WriteFile(filepath.Join(repoPath, baseFile), []byte("# Base"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			runGitCmd(t, repoPath, "add", ".")
+			runGitCmd(t, repoPath, "commit", "-m", "Move main forward")
+			runGitCmd(t, repoPath, "push", "origin", "main")
+			syncRepos = append(syncRepos, repoPath)
+		}
+
+		os.Args = append([]string{
+			"cascade",
+			"sync",
+			"--branch", "feature/test-sync",
+		}, syncRepos...)
+		stdout := captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+
+		if !strings.Contains(stdout, "rebased") || !strings.Contains(stdout, "conflicts in README.md") {
+			t.Errorf("Expected one rebase and one conflict, got:\n%s", stdout)
+		}
+		if !strings.Contains(stdout, "1 repositories have conflicts") {
+			t.Errorf("Expected the conflicting repository to be listed, got:\n%s", stdout)
+		}
+
+		// The rebased branch is pushed on top of main
+		remoteRepo := syncRepos[0] + ".git"
+		if subjects := runGitOutput(t, remoteRepo, "log", "--format=%s", "feature/test-sync"); !strings.HasPrefix(subjects, "Update README\nMove main forward\n") {
+			t.Errorf("Expected the campaign commit on top of main, got:\n%s", subjects)
+		}
+		for _, repoPath := range syncRepos {
+			if branch := getCurrentBranch(t, repoPath); branch != "main" {
+				t.Errorf("Expected %s to be back on main, got %q", repoPath, branch)
+			}
+			if status := runGitOutput(t, repoPath, "status", "--porcelain"); status != "" {
+				t.Errorf("Expected a clean working tree in %s, got %q", repoPath, status)
+			}
+		}
+	})
+
+	t.Run("keep commits others pushed to a campaign branch", func(t *testing.T) {
+		resetFlags()
+		// A reviewer pushes a fix to the campaign branch synced above, then
+		// main moves forward again
+		repoPath := filepath.Join(testDir, "sync-clean")
+		remoteRepo := repoPath + ".git"
+		reviewerClone := filepath.Join(testDir, "sync-reviewer")
+		runGitCmd(t, testDir, "clone", "-b", "feature/test-sync", remoteRepo, reviewerClone)
+		runGitCmd(t, reviewerClone, "config", "user.email", "reviewer@example.com")
+		runGitCmd(t, reviewerClone, "config", "user.name", "Reviewer")
+		runGitCmd(t, reviewerClone, "commit", "--allow-empty", "-m", "Review fix")
+		runGitCmd(t, reviewerClone, "push", "origin", "feature/test-sync")
+
+		runGitCmd(t, repoPath, "commit", "--allow-empty", "-m", "Move main further")
+		runGitCmd(t, repoPath, "push", "origin", "main")
+
+		os.Args = []string{
+			"cascade",
+			"sync",
+			"--branch", "feature/test-sync",
+			repoPath,
+		}
+		stdout := captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+
+		if !strings.Contains(stdout, "diverged") {
+			t.Errorf("Expected the branch to be reported as diverged, got:\n%s", stdout)
+		}
+		if subject := strings.TrimSpace(runGitOutput(t, remoteRepo, "log", "-1", "--format=%s", "feature/test-sync")); subject != "Review fix" {
+			t.Errorf("Expected the review fix to stay on the remote branch, got %q", subject)
+		}
+	})
+
+	t.Run("push campaign branches to a fork", func(t *testing.T) {
+		resetFlags()
+		forkRepo := filepath.Join(testDir, "fork-repo")
+		createTestRepo(t, forkRepo)
+		// The fork URL template resolves to a bare repository named after
+		// the repository
+		forksDir := filepath.Join(testDir, "forks")
+		if err := os.MkdirAll(filepath.Join(forksDir, "fork-repo.git"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		runGitCmd(t, filepath.Join(forksDir, "fork-repo.git"), "init", "--bare", "-b", "main")
+
+		os.Args = []string{
+			"cascade",
+			"apply",
+			"--command", "echo fork > fork.txt",
+			"--branch", "feature/test-fork",
+			"--message", "Add fork.txt",
+			"--push",
+			"--fork-url", filepath.Join(forksDir, "{{.RepoName}}.git"),
+			forkRepo,
+		}
+		stdout := captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+		if !strings.Contains(stdout, "ok   "+forkRepo) {
+			t.Errorf("Expected the repository to succeed, got:\n%s", stdout)
+		}
+
+		if url := runGitOutput(t, forkRepo, "remote", "get-url", "fork"); strings.TrimSpace(url) != filepath.Join(forksDir, "fork-repo.git") {
+			t.Errorf("Expected the fork remote to be added, got %q", url)
+		}
+		if subject := runGitOutput(t, filepath.Join(forksDir, "fork-repo.git"), "log", "-1", "--format=%s", "feature/test-fork"); subject != "Add fork.txt\n" {
+			t.Errorf("Expected the campaign branch on the fork, got %q", subject)
+		}
+		if upstream := runGitOutput(t, forkRepo, "rev-parse", "--abbrev-ref", "feature/test-fork@{upstream}"); upstream != "fork/feature/test-fork\n" {
+			t.Errorf("Expected the branch to track the fork, got %q", upstream)
+		}
+
+		// Status looks for the campaign branch on the fork
+		resetFlags()
+		runGitCmd(t, forkRepo, "checkout", "main")
+		os.Args = []string{
+			"cascade",
+			"status",
+			"--branch", "feature/test-fork",
+			"--fork-url", filepath.Join(forksDir, "{{.RepoName}}.git"),
+			forkRepo,
+		}
+		stdout = captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+		if row := strings.Join(strings.Fields(stdout), " "); !strings.Contains(row, forkRepo+" feature/test-fork yes yes 1 0") {
+			t.Errorf("Expected the branch to be found on the fork, got:\n%s", stdout)
+		}
+
+		// A clone that never fetched the fork still finds the branch there
+		resetFlags()
+		runGitCmd(t, forkRepo, "branch", "-D", "feature/test-fork")
+		runGitCmd(t, forkRepo, "remote", "remove", "fork")
+		os.Args = []string{
+			"cascade",
+			"apply",
+			"--command", "echo fork > fork.txt",
+			"--branch", "feature/test-fork",
+			"--message", "Add fork.txt",
+			"--push",
+			"--fork-url", filepath.Join(forksDir, "{{.RepoName}}.git"),
+			forkRepo,
+		}
+		stdout = captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+		match := regexp.MustCompile(`Error details: (\S+)`).FindStringSubmatch(stdout)
+		if match == nil {
+			t.Fatalf("Expected the repository to fail, got:\n%s", stdout)
+		}
+		if logContent, err := os.ReadFile(match[1]); err != nil || !strings.Contains(string(logContent), "already exists on fork") {
+			t.Errorf("Expected the existing branch on the fork to be found, got:\n%s", logContent)
+		}
+	})
+
+	t.Run("build pull request URL from the remote URL", func(t *testing.T) {
+		resetFlags()
+		urlRepo := filepath.Join(testDir, "pr-url-repo")
+		urlRemote := filepath.Join(testDir, "ssh", "org", "pr-url-repo.git")
+		createTestRepo(t, urlRepo)
+		if err := os.MkdirAll(urlRemote, 0755); err != nil {
+			t.Fatal(err)
+		}
+		runGitCmd(t, urlRemote, "init", "--bare", "-b", "main")
+		// origin looks like GitHub, but is the local repository that prints
+		// no URL
+		runGitCmd(t, urlRepo, "remote", "add", "origin", "git@github.com:org/pr-url-repo.git")
+		serveOverSSH(t, filepath.Join(testDir, "ssh"))
+
+		os.Args = []string{
+			"cascade",
+			"apply",
+			"--command", "echo url > url.txt",
+			"--branch", "feature/test-pr-url",
+			"--message", "Add url.txt",
+			"--push",
+			urlRepo,
+		}
+		captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+		if subject := runGitOutput(t, urlRemote, "log", "-1", "--format=%s", "feature/test-pr-url"); subject != "Add url.txt\n" {
+			t.Fatalf("Expected the branch to be pushed, got %q", subject)
+		}
+
+		resetFlags()
+		os.Args = []string{"cascade", "runs", "list"}
+		list := captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("runs list failed: %v", err)
+			}
+		})
+		lines := strings.Split(strings.TrimSpace(list), "\n")
+		if len(lines) < 2 {
+			t.Fatalf("Unexpected runs list:\n%s", list)
+		}
+		resetFlags()
+		os.Args = []string{"cascade", "runs", "show", strings.Fields(lines[1])[0]}
+		show := captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("runs show failed: %v", err)
+			}
+		})
+		if !strings.Contains(show, "pr:     https://github.com/org/pr-url-repo/compare/main...feature/test-pr-url?expand=1") {
+			t.Errorf("Expected the compare URL in the run, got:\n%s", show)
+		}
+	})
+
+	t.Run("retry pushes after transient errors", func(t *testing.T) {
+		resetFlags()
+		retryRepo := filepath.Join(testDir, "retry-repo")
+		retryRemote := filepath.Join(testDir, "retry-repo.git")
+		createTestRepo(t, retryRepo)
+		if err := os.MkdirAll(retryRemote, 0755); err != nil {
+			t.Fatal(err)
+		}
+		runGitCmd(t, retryRemote, "init", "--bare", "-b", "main")
+		// The remote is unavailable for the first push
+		hook := "#!/bin/sh\nif [ ! -f attempted ]; then touch attempted; echo 'Service Unavailable'; exit 1; fi\n"
+		if err := os.WriteFile(filepath.Join(retryRemote, "hooks", "pre-receive"), []byte(hook), 0755); err != nil {
+			t.Fatal(err)
+		}
+		runGitCmd(t, retryRepo, "remote", "add", "origin", retryRemote)
+
+		os.Args = []string{
+			"cascade",
+			"apply",
+			"--command", "echo retry > retry.txt",
+			"--branch", "feature/test-retry",
+			"--message", "Add retry.txt",
+			"--push",
+			"--retry-delay", "10ms",
+			"--log-format", "json",
+			retryRepo,
+		}
+		stdout := captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+		if !strings.Contains(stdout, "ok   "+retryRepo) {
+			t.Fatalf("Expected the push to succeed on retry, got:\n%s", stdout)
+		}
+
+		// The log records both attempts of the push
+		match := regexp.MustCompile(`Output log: (\S+)`).FindStringSubmatch(stdout)
+		if match == nil {
+			t.Fatalf("Expected the log path, got:\n%s", stdout)
+		}
+		data, err := os.ReadFile(match[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var attempts []string
+		for line := range strings.SplitSeq(strings.TrimSpace(string(data)), "\n") {
+			if strings.Contains(line, `"push"`) {
+				attempts = append(attempts, line)
+			}
+		}
+		if len(attempts) != 2 || !strings.Contains(attempts[0], `"attempt":1,"retry_delay_ms"`) || !strings.Contains(attempts[1], `"attempt":2`) {
+			t.Errorf("Expected two logged push attempts, got:\n%s", strings.Join(attempts, "\n"))
+		}
+
+		// The text log records the attempts too
+		resetFlags()
+		if err := os.Remove(filepath.Join(retryRemote, "attempted")); err != nil {
+			t.Fatal(err)
+		}
+		os.Args = []string{
+			"cascade",
+			"apply",
+			"--command", "echo retry > retry-text.txt",
+			"--branch", "feature/test-retry-text",
+			"--message", "Add retry-text.txt",
+			"--push",
+			"--retry-delay", "10ms",
+			retryRepo,
+		}
+		stdout = captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+		match = regexp.MustCompile(`Output log: (\S+)`).FindStringSubmatch(stdout)
+		if match == nil {
+			t.Fatalf("Expected a text log for the retried push, got:\n%s", stdout)
+		}
+		if data, err = os.ReadFile(match[1]); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "attempt: 1, exit code: 1, retrying in") || !strings.Contains(string(data), "attempt: 2, exit code: 0") {
+			t.Errorf("Expected both push attempts in the text log, got:\n%s", data)
+		}
+	})
+
+	t.Run("push changes for review to gerrit", func(t *testing.T) {
+		resetFlags()
+		gerritRepo := filepath.Join(testDir, "gerrit-repo")
+		gerritRemote := filepath.Join(testDir, "gerrit-repo.git")
+		createTestRepo(t, gerritRepo)
+		if err := os.MkdirAll(gerritRemote, 0755); err != nil {
+			t.Fatal(err)
+		}
+		runGitCmd(t, gerritRemote, "init", "--bare", "-b", "main")
+		runGitCmd(t, gerritRemote, "config", "receive.advertisePushOptions", "true")
+		// The hook answers like Gerrit, listing the change before a hint
+		hook := "#!/bin/sh\necho \"SUCCESS\"\necho \"  https://review.example.com/c/gerrit-repo/+/7 Add review.txt [NEW]\"\necho \"Hint: https://review.example.com/Documentation/user-upload.html\"\n"
+		if err := os.WriteFile(filepath.Join(gerritRemote, "hooks", "pre-receive"), []byte(hook), 0755); err != nil {
+			t.Fatal(err)
+		}
+		runGitCmd(t, gerritRepo, "remote", "add", "origin", gerritRemote)
+		runGitCmd(t, gerritRepo, "push", "origin", "main")
+
+		os.Args = []string{
+			"cascade",
+			"apply",
+			"--command", "echo review > review.txt",
+			"--branch", "feature/test-gerrit",
+			"--message", "Add review.txt",
+			"--push",
+			"--review-system", "gerrit",
+			"--topic", "test-{{.RepoName}}",
+			gerritRepo,
+		}
+		stdout := captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+		if !strings.Contains(stdout, "ok   "+gerritRepo) {
+			t.Errorf("Expected the repository to succeed, got:\n%s", stdout)
+		}
+
+		body := runGitOutput(t, gerritRepo, "log", "-1", "--format=%B", "feature/test-gerrit")
+		if !regexp.MustCompile(`\n\nChange-Id: I[0-9a-f]{40}\n`).MatchString(body) {
+			t.Errorf("Expected a Change-Id trailer, got %q", body)
+		}
+		head := runGitOutput(t, gerritRepo, "rev-parse", "feature/test-gerrit")
+		if ref := runGitOutput(t, gerritRemote, "rev-parse", "refs/for/main"); ref != head {
+			t.Errorf("Expected refs/for/main at %s, got %s", head, ref)
+		}
+		if refs := runGitOutput(t, gerritRemote, "branch", "--list", "feature/test-gerrit"); refs != "" {
+			t.Errorf("Expected no campaign branch on the remote, got %q", refs)
+		}
+
+		// The run history links the review of the change
+		resetFlags()
+		os.Args = []string{"cascade", "runs", "list"}
+		list := captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("runs list failed: %v", err)
+			}
+		})
+		lines := strings.Split(strings.TrimSpace(list), "\n")
+		if len(lines) < 2 {
+			t.Fatalf("Unexpected runs list:\n%s", list)
+		}
+		resetFlags()
+		os.Args = []string{"cascade", "runs", "show", strings.Fields(lines[1])[0]}
+		show := captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("runs show failed: %v", err)
+			}
+		})
+		if !strings.Contains(show, "pr:     https://review.example.com/c/gerrit-repo/+/7") {
+			t.Errorf("Expected the review URL in the run, got:\n%s", show)
 		}
 	})
 }
@@ -679,6 +1949,20 @@ func getLastCommitMessage(t *testing.T, repoPath string) string {
 	return strings.TrimSpace(string(output))
 }
 
+// serveOverSSH makes git reach remotes like git@host:org/repo.git at
+// root/org/repo.git instead of connecting to the host.
+func serveOverSSH(t *testing.T, root string) {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "ssh.sh")
+	// The last argument is the command run on the host, like
+	// git-upload-pack 'org/repo.git'
+	content := "#!/bin/sh\nfor last; do :; done\ncd '" + root + "' && exec sh -c \"$last\"\n"
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GIT_SSH_COMMAND", script)
+}
+
 func runGitCmd(t *testing.T, dir string, args ...string) {
 	cmd := exec.Command("git", args...)
 	cmd.Dir = dir
@@ -686,3 +1970,33 @@ func runGitCmd(t *testing.T, dir string, args ...string) {
 		t.Fatalf("Failed to run git %v: %v\n%s", args, err, output)
 	}
 }
+
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldStdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = oldStdout }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+func runGitOutput(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Failed to run git %v: %v", args, err)
+	}
+	return string(output)
+}